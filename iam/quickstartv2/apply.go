@@ -0,0 +1,120 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrPolicyConflict is returned by ApplyChangesWithEtag when the policy no
+// longer has the expected etag.
+var ErrPolicyConflict = errors.New("policy etag does not match")
+
+// ValidateChangeSet checks the operation, role and member of every change.
+// All problems are reported together in a *ValidationError.
+func ValidateChangeSet(changes ChangeSet) error {
+	var problems []string
+	for i, c := range changes.Changes {
+		if c.Op != OpAdd && c.Op != OpRemove {
+			problems = append(problems, fmt.Sprintf("change %d: unknown op %q", i, c.Op))
+		}
+		if err := ValidateRole(c.Role); err != nil {
+			problems = append(problems, fmt.Sprintf("change %d: %v", i, err))
+		}
+		if err := ValidateMember(c.Member); err != nil {
+			problems = append(problems, fmt.Sprintf("change %d: %v", i, err))
+		}
+	}
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// applyChange applies c to policy, reporting whether the policy changed.
+func applyChange(policy *Policy, c Change) bool {
+	if c.Op == OpRemove {
+		return deleteMember(policy, c.Member, c.Role, c.Condition)
+	}
+	return addMember(policy, c.Member, c.Role, c.Condition)
+}
+
+// ApplyChanges applies every change in changes to projectID in a single
+// read-modify-write, so either all of them are written or none are. The
+// change set is validated before the policy is fetched. Changes that are
+// already in effect are skipped. It returns the resulting policy.
+func ApplyChanges(ctx context.Context, svc *PolicyManager, projectID string, changes ChangeSet) (*Policy, error) {
+	if err := ValidateChangeSet(changes); err != nil {
+		return nil, err
+	}
+	policy, _, err := svc.modifyPolicy(ctx, projectID, func(policy *Policy) error {
+		for _, c := range changes.Changes {
+			applyChange(policy, c)
+		}
+		return nil
+	})
+	return policy, err
+}
+
+// ApplyChangesWithEtag applies changes to projectID like ApplyChanges, but
+// only if the policy's current etag is etag: a compare-and-swap for callers
+// that already hold a recent policy. If the policy has changed, nothing is
+// written and ErrPolicyConflict is returned. A write that loses a race with
+// a concurrent change fails the same way rather than being reapplied.
+func ApplyChangesWithEtag(ctx context.Context, svc *PolicyManager, projectID string, changes ChangeSet, etag string) (*Policy, error) {
+	if err := ValidateChangeSet(changes); err != nil {
+		return nil, err
+	}
+	policy, _, err := svc.modifyPolicy(ctx, projectID, func(policy *Policy) error {
+		if policy.Etag != etag {
+			return fmt.Errorf("%w: got %q, want %q", ErrPolicyConflict, policy.Etag, etag)
+		}
+		for _, c := range changes.Changes {
+			applyChange(policy, c)
+		}
+		return nil
+	})
+	return policy, err
+}
+
+// ExplainChange describes, without changing policy, what applying c to
+// policy would do, such as "binding will be created" or "member already has
+// role, no change".
+func ExplainChange(policy *Policy, c Change) string {
+	b := GetConditionalBinding(policy, c.Role, c.Condition)
+	switch c.Op {
+	case OpAdd:
+		switch {
+		case b == nil:
+			return "binding will be created"
+		case containsString(b.Members, c.Member):
+			return "member already has role, no change"
+		}
+		return "member will be added to the existing binding"
+	case OpRemove:
+		switch {
+		case b == nil:
+			return "no binding for role, no change"
+		case !containsString(b.Members, c.Member):
+			return "member does not have role, no change"
+		case len(b.Members) == 1:
+			return "member will be removed and the empty binding deleted"
+		}
+		return "member will be removed from the binding"
+	}
+	return fmt.Sprintf("unknown op %q, no change", c.Op)
+}
@@ -0,0 +1,123 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestApplyChanges(t *testing.T) {
+	ctx := context.Background()
+	target := newFakeTarget()
+	target.put("my-project", &Policy{Bindings: []*Binding{
+		{Role: "roles/storage.objectViewer", Members: []string{"user:bob@example.com", "user:carol@example.com"}},
+	}})
+	target.setErrs = []error{conflictErr}
+	m := newTestManager(t, target)
+
+	changes := ChangeSet{Changes: []Change{
+		{Op: OpAdd, Role: "roles/logging.logWriter", Member: "user:alice@example.com"},
+		{Op: OpRemove, Role: "roles/storage.objectViewer", Member: "user:bob@example.com"},
+	}}
+	policy, err := ApplyChanges(ctx, m, "my-project", changes)
+	if err != nil {
+		t.Fatalf("ApplyChanges: %v", err)
+	}
+	if target.sets != 2 {
+		t.Errorf("ApplyChanges: got %d SetPolicy calls, want 2 (one conflict, one write)", target.sets)
+	}
+
+	if diff := cmp.Diff([]string{"user:alice@example.com"}, policyMembers(policy, "roles/logging.logWriter")); diff != "" {
+		t.Errorf("roles/logging.logWriter members: got diff (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"user:carol@example.com"}, policyMembers(policy, "roles/storage.objectViewer")); diff != "" {
+		t.Errorf("roles/storage.objectViewer members: got diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestApplyChangesInvalid(t *testing.T) {
+	ctx := context.Background()
+	target := newFakeTarget()
+	target.put("my-project", &Policy{})
+	m := newTestManager(t, target)
+
+	changes := ChangeSet{Changes: []Change{
+		{Op: OpAdd, Role: "roles/viewer", Member: "user:alice@example.com"},
+		{Op: OpAdd, Role: "roles/viewer", Member: "alice@example.com"},
+	}}
+	if _, err := ApplyChanges(ctx, m, "my-project", changes); err == nil {
+		t.Fatalf("ApplyChanges: got no error, want validation error")
+	}
+	if target.gets != 0 || target.sets != 0 {
+		t.Errorf("ApplyChanges: got %d gets and %d sets, want none for an invalid change set", target.gets, target.sets)
+	}
+}
+
+func TestApplyChangesWithEtag(t *testing.T) {
+	ctx := context.Background()
+	target := newFakeTarget()
+	target.put("my-project", &Policy{})
+	m := newTestManager(t, target)
+
+	held, err := m.GetPolicy(ctx, "my-project")
+	if err != nil {
+		t.Fatalf("GetPolicy: %v", err)
+	}
+	changes := ChangeSet{Changes: []Change{
+		{Op: OpAdd, Role: "roles/viewer", Member: "user:alice@example.com"},
+	}}
+	if _, err := ApplyChangesWithEtag(ctx, m, "my-project", changes, held.Etag); err != nil {
+		t.Fatalf("ApplyChangesWithEtag(current etag): %v", err)
+	}
+
+	more := ChangeSet{Changes: []Change{
+		{Op: OpAdd, Role: "roles/editor", Member: "user:bob@example.com"},
+	}}
+	_, err = ApplyChangesWithEtag(ctx, m, "my-project", more, held.Etag)
+	if !errors.Is(err, ErrPolicyConflict) {
+		t.Errorf("ApplyChangesWithEtag(stale etag): got %v, want ErrPolicyConflict", err)
+	}
+	if target.sets != 1 {
+		t.Errorf("got %d SetPolicy calls, want 1", target.sets)
+	}
+}
+
+func TestExplainChange(t *testing.T) {
+	policy := &Policy{Bindings: []*Binding{
+		{Role: "roles/viewer", Members: []string{"user:alice@example.com", "user:bob@example.com"}},
+		{Role: "roles/editor", Members: []string{"user:alice@example.com"}},
+	}}
+	tests := []struct {
+		change Change
+		want   string
+	}{
+		{Change{Op: OpAdd, Role: "roles/viewer", Member: "user:alice@example.com"}, "member already has role, no change"},
+		{Change{Op: OpAdd, Role: "roles/viewer", Member: "user:carol@example.com"}, "member will be added to the existing binding"},
+		{Change{Op: OpAdd, Role: "roles/owner", Member: "user:carol@example.com"}, "binding will be created"},
+		{Change{Op: OpRemove, Role: "roles/owner", Member: "user:alice@example.com"}, "no binding for role, no change"},
+		{Change{Op: OpRemove, Role: "roles/viewer", Member: "user:carol@example.com"}, "member does not have role, no change"},
+		{Change{Op: OpRemove, Role: "roles/viewer", Member: "user:bob@example.com"}, "member will be removed from the binding"},
+		{Change{Op: OpRemove, Role: "roles/editor", Member: "user:alice@example.com"}, "member will be removed and the empty binding deleted"},
+	}
+	for _, test := range tests {
+		if got := ExplainChange(policy, test.change); got != test.want {
+			t.Errorf("ExplainChange(%+v): got %q, want %q", test.change, got, test.want)
+		}
+	}
+}
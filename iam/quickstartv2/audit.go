@@ -0,0 +1,189 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/logging"
+)
+
+// AuditRecord describes one change made to a policy.
+type AuditRecord struct {
+	// ID identifies the change and the write that made it, for
+	// deduplication: redelivering the record keeps its ID, but making the
+	// same change again in a later write gives a new one.
+	ID string `json:"id"`
+	// ChangeID identifies the change alone, as returned by ChangeID, so
+	// the same grant or revoke can be matched across writes.
+	ChangeID string    `json:"changeId"`
+	Time     time.Time `json:"time"`
+	Project  string    `json:"project"`
+	Op       Op        `json:"op"`
+	Role     string    `json:"role"`
+	Member   string    `json:"member"`
+	Actor    string    `json:"actor,omitempty"`
+	Reason   string    `json:"reason,omitempty"`
+	// Etag is the etag of the policy written.
+	Etag string `json:"etag,omitempty"`
+}
+
+// auditSink is a destination for audit records.
+type auditSink interface {
+	Write(ctx context.Context, r AuditRecord) error
+	Close() error
+}
+
+// audit writes a record for every change in cs, made by the write that
+// returned a policy with etag, to each configured sink. Audit failures are
+// logged but never fail the IAM operation.
+func (m *PolicyManager) audit(ctx context.Context, cs ChangeSet, etag string) {
+	now := m.now()
+	for _, c := range cs.Changes {
+		r := AuditRecord{
+			ID:       auditRecordID(cs.Project, c, etag),
+			ChangeID: ChangeID(cs.Project, c),
+			Time:     now,
+			Project:  cs.Project,
+			Op:       c.Op,
+			Role:     c.Role,
+			Member:   c.Member,
+			Actor:    m.actor,
+			Reason:   cs.Reason,
+			Etag:     etag,
+		}
+		for _, a := range m.audits {
+			if err := a.Write(ctx, r); err != nil {
+				log.Printf("audit: %v", err)
+			}
+		}
+	}
+}
+
+// auditRecordID returns the ID of the audit record for c made to projectID
+// by the write that returned a policy with etag.
+func auditRecordID(projectID string, c Change, etag string) string {
+	sum := sha256.Sum256([]byte(ChangeID(projectID, c) + "\x00" + etag))
+	return hex.EncodeToString(sum[:16])
+}
+
+// WithAuditLog appends a JSON audit record for every change to the file at
+// path.
+func WithAuditLog(path string) Option {
+	return func(m *PolicyManager) error {
+		f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			return fmt.Errorf("os.OpenFile: %v", err)
+		}
+		m.audits = append(m.audits, &fileSink{f: f})
+		return nil
+	}
+}
+
+// fileSink writes audit records as JSON lines.
+type fileSink struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+func (s *fileSink) Write(ctx context.Context, r AuditRecord) error {
+	line, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("json.Marshal: %v", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("Write: %v", err)
+	}
+	return nil
+}
+
+func (s *fileSink) Close() error {
+	return s.f.Close()
+}
+
+// entryLogger is the part of *logging.Logger used to write audit entries.
+type entryLogger interface {
+	LogSync(ctx context.Context, e logging.Entry) error
+}
+
+// WithCloudLogging writes each change as a structured entry to Cloud Logging.
+// logName is the full log name, in the form
+// "projects/PROJECT_ID/logs/LOG_ID".
+func WithCloudLogging(logName string) Option {
+	return func(m *PolicyManager) error {
+		parts := strings.Split(logName, "/")
+		if len(parts) != 4 || parts[0] != "projects" || parts[2] != "logs" || parts[1] == "" || parts[3] == "" {
+			return fmt.Errorf("invalid log name %q, want projects/PROJECT_ID/logs/LOG_ID", logName)
+		}
+		client, err := logging.NewClient(context.Background(), "projects/"+parts[1])
+		if err != nil {
+			return fmt.Errorf("logging.NewClient: %v", err)
+		}
+		m.audits = append(m.audits, &cloudLoggingSink{
+			logger: client.Logger(parts[3]),
+			close:  client.Close,
+		})
+		return nil
+	}
+}
+
+// cloudLoggingSink writes audit records to Cloud Logging.
+type cloudLoggingSink struct {
+	logger entryLogger
+	close  func() error
+}
+
+func (s *cloudLoggingSink) Write(ctx context.Context, r AuditRecord) error {
+	e := logging.Entry{
+		// Cloud Logging treats entries with the same insert ID and
+		// timestamp as duplicates, so a redelivered record is dropped.
+		InsertID:  r.ID,
+		Timestamp: r.Time,
+		Severity:  logging.Notice,
+		Payload:   r,
+		Labels: map[string]string{
+			"project": r.Project,
+			"op":      string(r.Op),
+			"role":    r.Role,
+			"member":  r.Member,
+			"actor":   r.Actor,
+		},
+	}
+	if r.Reason != "" {
+		e.Labels["reason"] = r.Reason
+	}
+	if err := s.logger.LogSync(ctx, e); err != nil {
+		return fmt.Errorf("LogSync: %v", err)
+	}
+	return nil
+}
+
+func (s *cloudLoggingSink) Close() error {
+	if s.close == nil {
+		return nil
+	}
+	return s.close()
+}
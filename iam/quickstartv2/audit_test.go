@@ -0,0 +1,160 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/logging"
+	"github.com/google/go-cmp/cmp"
+)
+
+// fakeEntryLogger records the entries it is asked to log.
+type fakeEntryLogger struct {
+	entries []logging.Entry
+	err     error
+}
+
+func (l *fakeEntryLogger) LogSync(ctx context.Context, e logging.Entry) error {
+	if l.err != nil {
+		return l.err
+	}
+	l.entries = append(l.entries, e)
+	return nil
+}
+
+func TestCloudLoggingAudit(t *testing.T) {
+	ctx := context.Background()
+	target := newFakeTarget()
+	target.put("my-project", &Policy{})
+	m := newTestManager(t, target, WithActor("admin@example.com"))
+	logger := &fakeEntryLogger{}
+	m.audits = append(m.audits, &cloudLoggingSink{logger: logger})
+
+	if _, err := m.AddBinding(ctx, "my-project", "user:alice@example.com", "roles/logging.logWriter"); err != nil {
+		t.Fatalf("AddBinding: %v", err)
+	}
+
+	if len(logger.entries) != 1 {
+		t.Fatalf("got %d log entries, want 1", len(logger.entries))
+	}
+	want := map[string]string{
+		"project": "my-project",
+		"op":      "add",
+		"role":    "roles/logging.logWriter",
+		"member":  "user:alice@example.com",
+		"actor":   "admin@example.com",
+	}
+	if diff := cmp.Diff(want, logger.entries[0].Labels); diff != "" {
+		t.Errorf("entry labels: got diff (-want +got):\n%s", diff)
+	}
+	c := Change{Op: OpAdd, Role: "roles/logging.logWriter", Member: "user:alice@example.com"}
+	if got, want := logger.entries[0].InsertID, auditRecordID("my-project", c, "etag-2"); got != want {
+		t.Errorf("entry insert ID: got %q, want %q", got, want)
+	}
+	r := logger.entries[0].Payload.(AuditRecord)
+	if got, want := r.ID, auditRecordID("my-project", c, "etag-2"); got != want {
+		t.Errorf("audit record ID: got %q, want %q", got, want)
+	}
+	if got, want := r.ChangeID, ChangeID("my-project", c); got != want {
+		t.Errorf("audit record change ID: got %q, want %q", got, want)
+	}
+
+	// Granting the role again after it was revoked is a new change, so it
+	// must not share the first grant's insert ID.
+	if _, err := m.RemoveMember(ctx, "my-project", "user:alice@example.com", "roles/logging.logWriter"); err != nil {
+		t.Fatalf("RemoveMember: %v", err)
+	}
+	if _, err := m.AddBinding(ctx, "my-project", "user:alice@example.com", "roles/logging.logWriter"); err != nil {
+		t.Fatalf("AddBinding(again): %v", err)
+	}
+	if len(logger.entries) != 3 {
+		t.Fatalf("got %d log entries, want 3", len(logger.entries))
+	}
+	if logger.entries[0].InsertID == logger.entries[2].InsertID {
+		t.Errorf("re-grant: got insert ID %q, the same as the first grant", logger.entries[2].InsertID)
+	}
+	if got, want := logger.entries[2].Payload.(AuditRecord).ChangeID, r.ChangeID; got != want {
+		t.Errorf("re-grant: got change ID %q, want the first grant's %q", got, want)
+	}
+}
+
+func TestCloudLoggingFailureDoesNotFailOp(t *testing.T) {
+	ctx := context.Background()
+	target := newFakeTarget()
+	target.put("my-project", &Policy{})
+	m := newTestManager(t, target)
+	m.audits = append(m.audits, &cloudLoggingSink{logger: &fakeEntryLogger{err: errors.New("logging unavailable")}})
+
+	if _, err := m.AddBinding(ctx, "my-project", "user:alice@example.com", "roles/logging.logWriter"); err != nil {
+		t.Fatalf("AddBinding: got %v, want no error when audit logging fails", err)
+	}
+	if b := GetBinding(target.policy("my-project"), "roles/logging.logWriter"); b == nil {
+		t.Errorf("AddBinding: binding was not written")
+	}
+}
+
+func TestWithCloudLoggingInvalidName(t *testing.T) {
+	if _, err := NewPolicyManager(newFakeTarget(), WithCloudLogging("iam-audit")); err == nil {
+		t.Errorf("WithCloudLogging(%q): got no error, want error", "iam-audit")
+	}
+}
+
+func TestReasonRecorded(t *testing.T) {
+	ctx := WithReason(context.Background(), "JIRA-123")
+	target := newFakeTarget()
+	target.put("my-project", &Policy{})
+	path := filepath.Join(t.TempDir(), "audit.log")
+	m := newTestManager(t, target, WithAuditLog(path))
+
+	cs, err := m.GrantFor(ctx, "my-project", "user:alice@example.com", "roles/viewer", time.Hour)
+	if err != nil {
+		t.Fatalf("GrantFor: %v", err)
+	}
+	if err := m.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if cs.Reason != "JIRA-123" {
+		t.Errorf("GrantFor: got change set reason %q, want %q", cs.Reason, "JIRA-123")
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ioutil.ReadFile: %v", err)
+	}
+	var r AuditRecord
+	if err := json.Unmarshal(data, &r); err != nil {
+		t.Fatalf("json.Unmarshal: %v", err)
+	}
+	if r.Reason != "JIRA-123" {
+		t.Errorf("audit record: got reason %q, want %q", r.Reason, "JIRA-123")
+	}
+
+	policy := target.policy("my-project")
+	if len(policy.Bindings) != 1 || policy.Bindings[0].Condition == nil ||
+		!strings.Contains(policy.Bindings[0].Condition.Description, "JIRA-123") {
+		t.Errorf("GrantFor: got bindings %+v, want a condition description with the reason", policy.Bindings)
+	}
+	if policy.Version != 3 {
+		t.Errorf("GrantFor: got policy version %d, want 3", policy.Version)
+	}
+}
@@ -0,0 +1,47 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+// allServices is the audit config service that applies to every service.
+const allServices = "allServices"
+
+// AuditGap is a required audit log type that a policy doesn't enable.
+type AuditGap struct {
+	Service string
+	LogType string
+}
+
+// CheckAuditLogging returns the log types in requiredLogTypes, such as
+// "DATA_READ" and "DATA_WRITE", that the audit configs of policy don't
+// enable for allServices, in the order given. Log types enabled only for
+// individual services don't satisfy the requirement.
+func CheckAuditLogging(policy *Policy, requiredLogTypes []string) []AuditGap {
+	enabled := make(map[string]bool)
+	for _, ac := range policy.AuditConfigs {
+		if ac.Service != allServices {
+			continue
+		}
+		for _, lc := range ac.AuditLogConfigs {
+			enabled[lc.LogType] = true
+		}
+	}
+	var gaps []AuditGap
+	for _, lt := range requiredLogTypes {
+		if !enabled[lt] {
+			gaps = append(gaps, AuditGap{Service: allServices, LogType: lt})
+		}
+	}
+	return gaps
+}
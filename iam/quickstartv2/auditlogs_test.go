@@ -0,0 +1,48 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/api/cloudresourcemanager/v1"
+)
+
+func TestCheckAuditLogging(t *testing.T) {
+	policy := &Policy{AuditConfigs: []*cloudresourcemanager.AuditConfig{
+		{
+			Service: "allServices",
+			AuditLogConfigs: []*cloudresourcemanager.AuditLogConfig{
+				{LogType: "ADMIN_READ"},
+				{LogType: "DATA_READ"},
+			},
+		},
+		{
+			Service:         "storage.googleapis.com",
+			AuditLogConfigs: []*cloudresourcemanager.AuditLogConfig{{LogType: "DATA_WRITE"}},
+		},
+	}}
+
+	got := CheckAuditLogging(policy, []string{"DATA_READ", "DATA_WRITE"})
+	want := []AuditGap{{Service: "allServices", LogType: "DATA_WRITE"}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("CheckAuditLogging: got diff (-want +got):\n%s", diff)
+	}
+
+	if got := CheckAuditLogging(&Policy{}, []string{"DATA_READ"}); len(got) != 1 {
+		t.Errorf("CheckAuditLogging(no audit configs): got %v, want one gap", got)
+	}
+}
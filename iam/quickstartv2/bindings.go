@@ -0,0 +1,94 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"google.golang.org/api/cloudresourcemanager/v1"
+)
+
+// GetBinding returns the unconditional binding for role in policy, or nil if
+// there is none. The returned binding is part of policy, so changes to it
+// change the policy.
+func GetBinding(policy *Policy, role string) *Binding {
+	return GetConditionalBinding(policy, role, nil)
+}
+
+// GetConditionalBinding returns the binding for role whose condition has the
+// same title, description and expression as condition, or nil if there is
+// none. A nil condition matches the unconditional binding.
+func GetConditionalBinding(policy *Policy, role string, condition *cloudresourcemanager.Expr) *Binding {
+	if policy == nil {
+		return nil
+	}
+	key := conditionKey(condition)
+	for _, b := range policy.Bindings {
+		if b.Role == role && conditionKey(b.Condition) == key {
+			return b
+		}
+	}
+	return nil
+}
+
+// addMember adds member to the binding for role with the given condition,
+// creating the binding if needed. It reports whether the policy changed.
+func addMember(policy *Policy, member, role string, condition *cloudresourcemanager.Expr) bool {
+	binding := GetConditionalBinding(policy, role, condition)
+	if binding == nil {
+		b := &Binding{Role: role, Members: []string{member}}
+		if condition != nil {
+			c := *condition
+			b.Condition = &c
+		}
+		policy.Bindings = append(policy.Bindings, b)
+		return true
+	}
+	if containsString(binding.Members, member) {
+		return false
+	}
+	binding.Members = append(binding.Members, member)
+	return true
+}
+
+// deleteMember removes member from the binding for role with the given
+// condition, dropping the binding once it has no members left. It reports
+// whether the policy changed.
+func deleteMember(policy *Policy, member, role string, condition *cloudresourcemanager.Expr) bool {
+	key := conditionKey(condition)
+	for i, b := range policy.Bindings {
+		if b.Role != role || conditionKey(b.Condition) != key {
+			continue
+		}
+		if !containsString(b.Members, member) {
+			return false
+		}
+		b.Members = removeString(b.Members, member)
+		if len(b.Members) == 0 {
+			policy.Bindings = append(policy.Bindings[:i], policy.Bindings[i+1:]...)
+		}
+		return true
+	}
+	return false
+}
+
+// removeString returns list without any occurrence of s.
+func removeString(list []string, s string) []string {
+	out := list[:0]
+	for _, v := range list {
+		if v != s {
+			out = append(out, v)
+		}
+	}
+	return out
+}
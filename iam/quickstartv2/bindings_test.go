@@ -0,0 +1,47 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	"google.golang.org/api/cloudresourcemanager/v1"
+)
+
+func TestGetBinding(t *testing.T) {
+	temp := &cloudresourcemanager.Expr{
+		Title:      "temp-access",
+		Expression: `request.time < timestamp("2030-01-01T00:00:00Z")`,
+	}
+	conditional := &Binding{Role: "roles/viewer", Members: []string{"user:bob@example.com"}, Condition: temp}
+	unconditional := &Binding{Role: "roles/viewer", Members: []string{"user:alice@example.com"}}
+	policy := &Policy{Bindings: []*Binding{conditional, unconditional}}
+
+	if got := GetBinding(policy, "roles/viewer"); got != unconditional {
+		t.Errorf("GetBinding(roles/viewer): got %+v, want the unconditional binding", got)
+	}
+	if got := GetBinding(policy, "roles/editor"); got != nil {
+		t.Errorf("GetBinding(roles/editor): got %+v, want nil", got)
+	}
+
+	same := *temp
+	if got := GetConditionalBinding(policy, "roles/viewer", &same); got != conditional {
+		t.Errorf("GetConditionalBinding: got %+v, want the conditional binding", got)
+	}
+	other := &cloudresourcemanager.Expr{Title: "other", Expression: "true"}
+	if got := GetConditionalBinding(policy, "roles/viewer", other); got != nil {
+		t.Errorf("GetConditionalBinding(other): got %+v, want nil", got)
+	}
+}
@@ -0,0 +1,175 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"google.golang.org/api/googleapi"
+)
+
+// ErrCircuitOpen is returned, without calling the API, while a circuit
+// breaker is open.
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// Circuit breaker states, as returned by CircuitBreaker.State.
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half-open"
+)
+
+// CircuitBreaker stops API calls during sustained failures. After
+// threshold consecutive failed calls it opens, and calls fail fast with
+// ErrCircuitOpen. Once cooldown has elapsed it half-opens and lets a single
+// call through: if that call succeeds the breaker closes, otherwise it
+// opens for another cooldown. Only server errors, rate limiting and errors
+// without an API status, such as network failures, count as failures;
+// conflicts and other client errors don't. Cancelled calls, and calls that
+// started before the breaker opened, don't change its state.
+//
+// A CircuitBreaker is safe for concurrent use and is meant to be shared by
+// every manager and worker calling the same API.
+type CircuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+	// now returns the current time. It is replaced in tests.
+	now func() time.Time
+
+	mu       sync.Mutex
+	state    string
+	failures int
+	openedAt time.Time
+}
+
+// NewCircuitBreaker returns a closed CircuitBreaker that opens after
+// threshold consecutive failures, at least one, and stays open for
+// cooldown.
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	if threshold < 1 {
+		threshold = 1
+	}
+	return &CircuitBreaker{threshold: threshold, cooldown: cooldown, now: time.Now, state: CircuitClosed}
+}
+
+// WithCircuitBreaker routes every policy read and write through b.
+func WithCircuitBreaker(b *CircuitBreaker) Option {
+	return func(m *PolicyManager) error {
+		m.breaker = b
+		return nil
+	}
+}
+
+// State returns the breaker's state: CircuitClosed, CircuitOpen or
+// CircuitHalfOpen.
+func (b *CircuitBreaker) State() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == CircuitOpen && b.now().Sub(b.openedAt) >= b.cooldown {
+		return CircuitHalfOpen
+	}
+	return b.state
+}
+
+// do runs call unless the breaker is open, and records its outcome.
+func (b *CircuitBreaker) do(call func() error) error {
+	probe, err := b.allow()
+	if err != nil {
+		return err
+	}
+	err = call()
+	b.record(err, probe)
+	return err
+}
+
+// allow reports, with ErrCircuitOpen, whether a call may proceed, and
+// whether it is the half-open probe: the first call after the cooldown.
+func (b *CircuitBreaker) allow() (probe bool, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case CircuitHalfOpen:
+		// A probe is already in flight.
+		return false, ErrCircuitOpen
+	case CircuitOpen:
+		if b.now().Sub(b.openedAt) < b.cooldown {
+			return false, ErrCircuitOpen
+		}
+		b.state = CircuitHalfOpen
+		return true, nil
+	}
+	return false, nil
+}
+
+// record updates the breaker with the outcome of a call, which was the
+// half-open probe if probe is set.
+func (b *CircuitBreaker) record(err error, probe bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if errors.Is(err, context.Canceled) {
+		// A cancelled call says nothing about the API. A cancelled probe
+		// leaves the breaker open, with the cooldown already elapsed, so
+		// the next call probes again.
+		if probe {
+			b.state = CircuitOpen
+		}
+		return
+	}
+	if !probe && b.state != CircuitClosed {
+		// The call started before the breaker opened; only the probe
+		// decides whether it closes.
+		return
+	}
+	if !isServiceFailure(err) {
+		b.state = CircuitClosed
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if probe || b.failures >= b.threshold {
+		b.state = CircuitOpen
+		b.openedAt = b.now()
+	}
+}
+
+// isServiceFailure reports whether err indicates that the API, rather than
+// the request, is failing.
+func isServiceFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+	var apiErr *googleapi.Error
+	if !errors.As(err, &apiErr) {
+		return true
+	}
+	return apiErr.Code >= 500 || apiErr.Code == http.StatusTooManyRequests
+}
+
+// guard runs call through the manager's circuit breaker, if any.
+func (m *PolicyManager) guard(resource string, call func() error) error {
+	if m.breaker == nil {
+		return call()
+	}
+	err := m.breaker.do(call)
+	if errors.Is(err, ErrCircuitOpen) {
+		return fmt.Errorf("%s: %w", resource, err)
+	}
+	return err
+}
@@ -0,0 +1,122 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"google.golang.org/api/googleapi"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	ctx := context.Background()
+	target := newFakeTarget()
+	target.put("my-project", &Policy{})
+	unavailable := &googleapi.Error{Code: http.StatusServiceUnavailable, Message: "backend unavailable"}
+	target.getErrs = map[string]error{"my-project": unavailable}
+
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	b := NewCircuitBreaker(2, time.Minute)
+	b.now = func() time.Time { return now }
+	// Two workers share the breaker.
+	m1 := newTestManager(t, target, WithCircuitBreaker(b))
+	m2 := newTestManager(t, target, WithCircuitBreaker(b))
+
+	for _, m := range []*PolicyManager{m1, m2} {
+		if _, err := m.GetPolicy(ctx, "my-project"); !errors.Is(err, unavailable) {
+			t.Fatalf("GetPolicy: got %v, want the API error", err)
+		}
+	}
+	if got := b.State(); got != CircuitOpen {
+		t.Fatalf("after 2 failures: got state %q, want %q", got, CircuitOpen)
+	}
+	gets := target.gets
+	if _, err := m1.GetPolicy(ctx, "my-project"); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("GetPolicy(open): got %v, want ErrCircuitOpen", err)
+	}
+	if target.gets != gets {
+		t.Errorf("GetPolicy(open): got %d API calls, want none", target.gets-gets)
+	}
+
+	// A failed probe after the cooldown opens the breaker again.
+	now = now.Add(time.Minute)
+	if got := b.State(); got != CircuitHalfOpen {
+		t.Errorf("after cooldown: got state %q, want %q", got, CircuitHalfOpen)
+	}
+	if _, err := m2.GetPolicy(ctx, "my-project"); !errors.Is(err, unavailable) {
+		t.Errorf("GetPolicy(probe): got %v, want the API error", err)
+	}
+	if got := b.State(); got != CircuitOpen {
+		t.Errorf("after failed probe: got state %q, want %q", got, CircuitOpen)
+	}
+
+	// A successful probe closes it.
+	now = now.Add(time.Minute)
+	target.getErrs = nil
+	if _, err := m1.GetPolicy(ctx, "my-project"); err != nil {
+		t.Errorf("GetPolicy(probe): %v", err)
+	}
+	if got := b.State(); got != CircuitClosed {
+		t.Errorf("after successful probe: got state %q, want %q", got, CircuitClosed)
+	}
+
+	// Client errors don't count as failures.
+	target.getErrs = map[string]error{"my-project": &googleapi.Error{Code: http.StatusForbidden}}
+	for i := 0; i < 3; i++ {
+		m1.GetPolicy(ctx, "my-project")
+	}
+	if got := b.State(); got != CircuitClosed {
+		t.Errorf("after client errors: got state %q, want %q", got, CircuitClosed)
+	}
+}
+
+func TestCircuitBreakerCancelledProbe(t *testing.T) {
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	b := NewCircuitBreaker(1, time.Minute)
+	b.now = func() time.Time { return now }
+	unavailable := &googleapi.Error{Code: http.StatusServiceUnavailable}
+
+	// A call that started while the breaker was closed succeeds only after
+	// another call has opened it.
+	probe, err := b.allow()
+	if probe || err != nil {
+		t.Fatalf("allow(closed): got probe %v and error %v, want no probe and nil", probe, err)
+	}
+	b.do(func() error { return unavailable })
+	b.record(nil, probe)
+	if got := b.State(); got != CircuitOpen {
+		t.Errorf("after late success: got state %q, want %q", got, CircuitOpen)
+	}
+
+	now = now.Add(time.Minute)
+	if err := b.do(func() error { return context.Canceled }); !errors.Is(err, context.Canceled) {
+		t.Fatalf("do(cancelled probe): got %v, want context.Canceled", err)
+	}
+	if got := b.State(); got == CircuitClosed {
+		t.Errorf("after cancelled probe: got state %q, want open or half-open", got)
+	}
+
+	// The next call is the probe.
+	if err := b.do(func() error { return nil }); err != nil {
+		t.Errorf("do(probe): %v", err)
+	}
+	if got := b.State(); got != CircuitClosed {
+		t.Errorf("after successful probe: got state %q, want %q", got, CircuitClosed)
+	}
+}
@@ -0,0 +1,240 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+)
+
+// WithCheckpoint makes ApplyToProjects record each project it completes in
+// a JSON file at path, and skip the projects already recorded there, so
+// that an interrupted run can be resumed by running it again. The file also
+// records which changes were being applied; a run applying different
+// changes ignores the recorded projects and starts a new checkpoint.
+func WithCheckpoint(path string) Option {
+	return func(m *PolicyManager) error {
+		m.checkpoint = path
+		return nil
+	}
+}
+
+// checkpoint is the file format of a bulk apply checkpoint.
+type checkpoint struct {
+	// Changes is a hash of the changes being applied, from changesHash.
+	Changes   string   `json:"changes"`
+	Completed []string `json:"completed"`
+}
+
+// changesHash returns a hash identifying the changes in cs, in order.
+func changesHash(cs ChangeSet) (string, error) {
+	data, err := json.Marshal(cs.Changes)
+	if err != nil {
+		return "", fmt.Errorf("json.Marshal: %v", err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// readCheckpoint reads the checkpoint at path. A missing file is an empty
+// checkpoint.
+func readCheckpoint(path string) (*checkpoint, error) {
+	cp := &checkpoint{}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cp, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ioutil.ReadFile: %v", err)
+	}
+	if err := json.Unmarshal(data, cp); err != nil {
+		return nil, fmt.Errorf("invalid checkpoint %s: %v", path, err)
+	}
+	return cp, nil
+}
+
+// writeCheckpoint replaces the checkpoint at path.
+func writeCheckpoint(path string, cp *checkpoint) error {
+	data, err := json.MarshalIndent(cp, "", "  ")
+	if err != nil {
+		return fmt.Errorf("json.MarshalIndent: %v", err)
+	}
+	return writeFileAtomic(path, data)
+}
+
+// ApplyToProjects applies changes to each of projectIDs in turn, with one
+// read-modify-write per project, and returns the changes made to each. It
+// stops at the first project that fails, returning the results so far.
+// With WithCheckpoint, projects completed by an earlier run of the same
+// changes are skipped and are not included in the results.
+func ApplyToProjects(ctx context.Context, svc *PolicyManager, projectIDs []string, changes ChangeSet) (map[string]ChangeSet, error) {
+	if err := ValidateChangeSet(changes); err != nil {
+		return nil, err
+	}
+	hash, err := changesHash(changes)
+	if err != nil {
+		return nil, err
+	}
+	cp := &checkpoint{Changes: hash}
+	if svc.checkpoint != "" {
+		recorded, err := readCheckpoint(svc.checkpoint)
+		if err != nil {
+			return nil, err
+		}
+		if recorded.Changes == hash {
+			cp = recorded
+		}
+	}
+	done := make(map[string]bool)
+	for _, p := range cp.Completed {
+		done[p] = true
+	}
+
+	results := make(map[string]ChangeSet)
+	for _, projectID := range projectIDs {
+		if done[projectID] {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return results, err
+		}
+		_, cs, err := svc.modifyPolicy(ctx, projectID, func(policy *Policy) error {
+			for _, c := range changes.Changes {
+				applyChange(policy, c)
+			}
+			return nil
+		})
+		if err != nil {
+			return results, fmt.Errorf("%s: %w", projectID, err)
+		}
+		results[projectID] = cs
+		if svc.checkpoint != "" {
+			done[projectID] = true
+			cp.Completed = append(cp.Completed, projectID)
+			sort.Strings(cp.Completed)
+			if err := writeCheckpoint(svc.checkpoint, cp); err != nil {
+				return results, err
+			}
+		}
+	}
+	return results, nil
+}
+
+// BulkResult is the outcome of applying policies to many projects.
+type BulkResult struct {
+	// Changes holds the changes made to each project that succeeded.
+	Changes map[string]ChangeSet
+	// Errors holds the error for each project that failed.
+	Errors map[string]error
+}
+
+// policyFileName matches the "<projectID>.json" files read by ApplyDir.
+var policyFileName = regexp.MustCompile(`^([a-z][a-z0-9-]{4,28}[a-z0-9])\.json$`)
+
+// ApplyDir reconciles each project that has a "<projectID>.json" policy
+// file in dir, at most concurrency projects at a time. Other files are
+// skipped. If authoritative is set a project's bindings are replaced with
+// the file's bindings, and files with no bindings or with fields the policy
+// type doesn't have are rejected; otherwise the file's grants are added
+// with ReconcileAdditive and nothing is removed. Audit configs in the files are
+// ignored. A project that fails, including one whose file doesn't parse or
+// validate, is recorded in the result without stopping the others. The
+// returned error is set only if dir can't be read or ctx is done before
+// every project is applied.
+func ApplyDir(ctx context.Context, svc *PolicyManager, dir string, authoritative bool, concurrency int) (BulkResult, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return BulkResult{}, fmt.Errorf("ioutil.ReadDir: %v", err)
+	}
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	result := BulkResult{Changes: make(map[string]ChangeSet), Errors: make(map[string]error)}
+	var mu sync.Mutex
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for _, info := range infos {
+		match := policyFileName.FindStringSubmatch(info.Name())
+		if match == nil || info.IsDir() {
+			continue
+		}
+		projectID, path := match[1], filepath.Join(dir, info.Name())
+		select {
+		case <-ctx.Done():
+		case sem <- struct{}{}:
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			cs, err := applyPolicyFile(ctx, svc, projectID, path, authoritative)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				result.Errors[projectID] = err
+				return
+			}
+			result.Changes[projectID] = cs
+		}()
+	}
+	wg.Wait()
+	return result, ctx.Err()
+}
+
+// applyPolicyFile reconciles projectID with the policy file at path.
+func applyPolicyFile(ctx context.Context, svc *PolicyManager, projectID, path string, authoritative bool) (ChangeSet, error) {
+	desired, err := LoadPolicyFile(path)
+	if err != nil {
+		return ChangeSet{}, err
+	}
+	if !authoritative {
+		return ReconcileAdditive(ctx, svc, projectID, desired.Bindings)
+	}
+	// The file replaces every binding, so a typo that makes it look empty,
+	// such as "binding" for "bindings", must not revoke everything.
+	warnings, err := LintPolicyFile(path)
+	if err != nil {
+		return ChangeSet{}, err
+	}
+	var problems []string
+	for _, w := range warnings {
+		problems = append(problems, w.String())
+	}
+	if len(desired.Bindings) == 0 {
+		problems = append(problems, "no bindings; an authoritative file must list every binding to keep")
+	}
+	problems = append(problems, validatePolicy(desired)...)
+	if len(problems) > 0 {
+		return ChangeSet{}, &ValidationError{Problems: problems}
+	}
+	_, cs, err := svc.modifyPolicy(ctx, projectID, func(policy *Policy) error {
+		policy.Bindings = copyPolicy(desired).Bindings
+		return nil
+	})
+	return cs, err
+}
@@ -0,0 +1,161 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/api/googleapi"
+)
+
+func TestApplyToProjectsCheckpoint(t *testing.T) {
+	ctx := context.Background()
+	target := newFakeTarget()
+	for _, p := range []string{"project-a", "project-b", "project-c"} {
+		target.put(p, &Policy{})
+	}
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+	changes := ChangeSet{Changes: []Change{
+		{Op: OpAdd, Role: "roles/viewer", Member: "user:alice@example.com"},
+	}}
+	projects := []string{"project-a", "project-b", "project-c"}
+
+	// The first run is interrupted by a permanent error on project-b.
+	m := newTestManager(t, target, WithCheckpoint(path))
+	target.onGet = func(resource string, gets int) {
+		if resource == "project-b" {
+			target.setErrs = []error{&googleapi.Error{Code: http.StatusForbidden, Message: "denied"}}
+		}
+	}
+	got, err := ApplyToProjects(ctx, m, projects, changes)
+	var apiErr *googleapi.Error
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("ApplyToProjects: got error %v, want the API error", err)
+	}
+	if _, ok := got["project-a"]; !ok || len(got) != 1 {
+		t.Errorf("ApplyToProjects: got results for %v, want only project-a", got)
+	}
+
+	// The second run resumes after project-a.
+	target.onGet = nil
+	before := target.sets
+	got, err = ApplyToProjects(ctx, m, projects, changes)
+	if err != nil {
+		t.Fatalf("ApplyToProjects (resumed): %v", err)
+	}
+	if _, ok := got["project-a"]; ok || len(got) != 2 {
+		t.Errorf("ApplyToProjects (resumed): got results for %v, want project-b and project-c", got)
+	}
+	if n := target.sets - before; n != 2 {
+		t.Errorf("ApplyToProjects (resumed): got %d SetPolicy calls, want 2", n)
+	}
+
+	cp, err := readCheckpoint(path)
+	if err != nil {
+		t.Fatalf("readCheckpoint: %v", err)
+	}
+	if len(cp.Completed) != 3 {
+		t.Errorf("checkpoint: got completed %v, want all three projects", cp.Completed)
+	}
+
+	// A run of different changes doesn't skip the projects completed by
+	// the earlier one.
+	other := ChangeSet{Changes: []Change{
+		{Op: OpAdd, Role: "roles/logging.viewer", Member: "user:bob@example.com"},
+	}}
+	got, err = ApplyToProjects(ctx, m, projects, other)
+	if err != nil {
+		t.Fatalf("ApplyToProjects (other changes): %v", err)
+	}
+	if len(got) != 3 {
+		t.Errorf("ApplyToProjects (other changes): got results for %v, want all three projects", got)
+	}
+	for _, p := range projects {
+		if !policyHasRole(target.policy(p), "user:bob@example.com", "roles/logging.viewer") {
+			t.Errorf("ApplyToProjects (other changes): %s not changed", p)
+		}
+	}
+}
+
+func TestApplyDir(t *testing.T) {
+	ctx := context.Background()
+	target := newFakeTarget()
+	target.put("project-a", &Policy{Bindings: []*Binding{
+		{Role: "roles/owner", Members: []string{"user:legacy@example.com"}},
+	}})
+	target.put("project-b", &Policy{})
+	owners := []*Binding{{Role: "roles/owner", Members: []string{"user:admin@example.com"}}}
+	target.put("project-d", &Policy{Bindings: owners})
+	target.put("project-e", &Policy{Bindings: owners})
+	m := newTestManager(t, target)
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"project-a.json": `{"bindings": [{"role": "roles/viewer", "members": ["user:alice@example.com"]}]}`,
+		"project-b.json": `{"bindings": [{"role": "roles/editor", "members": ["group:dev@example.com"]}]}`,
+		"README.md":      "Policies, one file per project.",
+		"Project-C.json": `{"bindings": [{"role": "roles/owner", "members": ["user:mallory@example.com"]}]}`,
+		// Neither file may wipe the project's bindings.
+		"project-d.json": `{}`,
+		"project-e.json": `{"binding": [{"role": "roles/viewer", "members": ["user:alice@example.com"]}]}`,
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("ioutil.WriteFile: %v", err)
+		}
+	}
+
+	got, err := ApplyDir(ctx, m, dir, true, 2)
+	if err != nil {
+		t.Fatalf("ApplyDir: %v", err)
+	}
+	for _, p := range []string{"project-d", "project-e"} {
+		var verr *ValidationError
+		if !errors.As(got.Errors[p], &verr) {
+			t.Errorf("ApplyDir: got error %v for %s, want a *ValidationError", got.Errors[p], p)
+		}
+		if diff := cmp.Diff(owners, target.policy(p).Bindings); diff != "" {
+			t.Errorf("ApplyDir: %s: got diff (-want +got):\n%s", p, diff)
+		}
+	}
+	if len(got.Errors) != 2 {
+		t.Errorf("ApplyDir: got errors %v, want only project-d and project-e", got.Errors)
+	}
+	want := map[string]ChangeSet{
+		"project-a": {Project: "project-a", Changes: []Change{
+			{Op: OpRemove, Role: "roles/owner", Member: "user:legacy@example.com"},
+			{Op: OpAdd, Role: "roles/viewer", Member: "user:alice@example.com"},
+		}, Warnings: []string{
+			"user:alice@example.com granted primitive role roles/viewer; prefer a predefined role",
+		}},
+		"project-b": {Project: "project-b", Changes: []Change{
+			{Op: OpAdd, Role: "roles/editor", Member: "group:dev@example.com"},
+		}, Warnings: []string{
+			"group:dev@example.com granted primitive role roles/editor; prefer a predefined role",
+		}},
+	}
+	if diff := cmp.Diff(want, got.Changes); diff != "" {
+		t.Errorf("ApplyDir: got diff (-want +got):\n%s", diff)
+	}
+	if target.sets != 2 {
+		t.Errorf("ApplyDir: got %d SetPolicy calls, want 2", target.sets)
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// PolicyCache stores fetched policies so that repeated reads don't call the
+// API. A cache may be shared by several managers; a write through any of
+// them invalidates the entry for every manager using the cache.
+//
+// Each resource has a generation that Invalidate advances. Put only stores a
+// policy if the generation is unchanged since the Get that preceded the
+// fetch, so a read that raced with a write can't cache the policy from
+// before the write.
+type PolicyCache interface {
+	// Get returns a copy of the cached policy of resource, or nil if there
+	// is none, and the resource's current generation.
+	Get(resource string) (*Policy, uint64)
+	// Put caches a copy of policy for resource if its generation is still
+	// gen.
+	Put(resource string, gen uint64, policy *Policy)
+	// Invalidate drops any cached policy for resource and advances its
+	// generation.
+	Invalidate(resource string)
+}
+
+// WithCache serves GetPolicy and the read methods built on it from cache.
+// Writes invalidate the written resource whether or not they succeed.
+// Read-modify-write cycles always fetch a fresh policy, so changes are never
+// computed against a cached copy.
+func WithCache(cache PolicyCache) Option {
+	return func(m *PolicyManager) error {
+		m.cache = cache
+		return nil
+	}
+}
+
+// memoryCache is an in-process PolicyCache.
+type memoryCache struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+	gens    map[string]uint64
+}
+
+type cacheEntry struct {
+	policy  *Policy
+	fetched time.Time
+}
+
+// NewMemoryCache returns a PolicyCache, safe for concurrent use, that keeps
+// policies in memory for ttl. A ttl of zero keeps them until invalidated.
+func NewMemoryCache(ttl time.Duration) PolicyCache {
+	return &memoryCache{
+		ttl:     ttl,
+		now:     time.Now,
+		entries: make(map[string]cacheEntry),
+		gens:    make(map[string]uint64),
+	}
+}
+
+func (c *memoryCache) Get(resource string) (*Policy, uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	gen := c.gens[resource]
+	e, ok := c.entries[resource]
+	if !ok {
+		return nil, gen
+	}
+	if c.ttl > 0 && c.now().Sub(e.fetched) >= c.ttl {
+		delete(c.entries, resource)
+		return nil, gen
+	}
+	return copyPolicy(e.policy), gen
+}
+
+func (c *memoryCache) Put(resource string, gen uint64, policy *Policy) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.gens[resource] != gen {
+		return
+	}
+	c.entries[resource] = cacheEntry{policy: copyPolicy(policy), fetched: c.now()}
+}
+
+func (c *memoryCache) Invalidate(resource string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, resource)
+	c.gens[resource]++
+}
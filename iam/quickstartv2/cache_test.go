@@ -0,0 +1,117 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestCacheServesReads(t *testing.T) {
+	ctx := context.Background()
+	target := newFakeTarget()
+	target.put("my-project", &Policy{})
+	m := newTestManager(t, target, WithCache(NewMemoryCache(0)))
+
+	for i := 0; i < 3; i++ {
+		if _, err := m.GetPolicy(ctx, "my-project"); err != nil {
+			t.Fatalf("GetPolicy: %v", err)
+		}
+	}
+	if target.gets != 1 {
+		t.Errorf("GetPolicy: got %d target reads, want 1", target.gets)
+	}
+
+	if _, err := m.AddBinding(ctx, "my-project", "user:alice@example.com", "roles/viewer"); err != nil {
+		t.Fatalf("AddBinding: %v", err)
+	}
+	has, err := m.HasRole(ctx, "my-project", "user:alice@example.com", "roles/viewer")
+	if err != nil {
+		t.Fatalf("HasRole: %v", err)
+	}
+	if !has {
+		t.Errorf("HasRole after AddBinding: got false, want true")
+	}
+}
+
+func TestCacheSharedAcrossManagers(t *testing.T) {
+	ctx := context.Background()
+	target := newFakeTarget()
+	target.put("my-project", &Policy{})
+	cache := NewMemoryCache(0)
+	reader := newTestManager(t, target, WithCache(cache))
+	writer := newTestManager(t, target, WithCache(cache))
+
+	if _, err := reader.GetPolicy(ctx, "my-project"); err != nil {
+		t.Fatalf("GetPolicy: %v", err)
+	}
+	if _, err := writer.AddBinding(ctx, "my-project", "user:alice@example.com", "roles/viewer"); err != nil {
+		t.Fatalf("AddBinding: %v", err)
+	}
+	has, err := reader.HasRole(ctx, "my-project", "user:alice@example.com", "roles/viewer")
+	if err != nil {
+		t.Fatalf("HasRole: %v", err)
+	}
+	if !has {
+		t.Errorf("HasRole through the other manager: got false, want true")
+	}
+}
+
+func TestCacheConcurrentReadWrite(t *testing.T) {
+	ctx := context.Background()
+	target := newFakeTarget()
+	target.put("my-project", &Policy{})
+	m := newTestManager(t, target, WithCache(NewMemoryCache(0)))
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	defer func() {
+		close(stop)
+		wg.Wait()
+	}()
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				if _, err := m.GetPolicy(ctx, "my-project"); err != nil {
+					t.Errorf("GetPolicy: %v", err)
+					return
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < 50; i++ {
+		member := fmt.Sprintf("user:user%d@example.com", i)
+		if _, err := m.AddBinding(ctx, "my-project", member, "roles/viewer"); err != nil {
+			t.Fatalf("AddBinding: %v", err)
+		}
+		has, err := m.HasRole(ctx, "my-project", member, "roles/viewer")
+		if err != nil {
+			t.Fatalf("HasRole: %v", err)
+		}
+		if !has {
+			t.Fatalf("HasRole(%s) after AddBinding returned: got false, want true", member)
+		}
+	}
+}
@@ -0,0 +1,312 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"google.golang.org/api/cloudresourcemanager/v1"
+	crmv2 "google.golang.org/api/cloudresourcemanager/v2"
+	"google.golang.org/api/option"
+)
+
+// Interaction is one HTTP request and its response, as stored in a cassette.
+type Interaction struct {
+	Method       string
+	URL          string
+	RequestBody  string
+	StatusCode   int
+	ResponseBody string
+}
+
+// A cassette file holds interactions separated by blank lines. Each
+// interaction is a block of lines prefixed with "> " for the request, whose
+// first line is the method and URL, followed by lines prefixed with "< " for
+// the response, whose first line is the status code. The remaining lines of
+// each are the body. Lines starting with "#" are comments.
+//
+//	> POST https://cloudresourcemanager.googleapis.com/v1/projects/my-project:getIamPolicy?alt=json&prettyPrint=false
+//	> {"options":{"requestedPolicyVersion":3}}
+//	< 200
+//	< {"etag":"BwWKmjvelug=","version":1}
+
+// RecordingTransport is an http.RoundTripper that passes requests to Base,
+// or http.DefaultTransport if Base is nil, and records every interaction.
+// Close writes the recorded interactions to the cassette file.
+type RecordingTransport struct {
+	Base http.RoundTripper
+
+	path         string
+	mu           sync.Mutex
+	interactions []Interaction
+}
+
+// NewRecordingTransport returns a transport that records to the cassette at
+// path when closed.
+func NewRecordingTransport(path string, base http.RoundTripper) *RecordingTransport {
+	return &RecordingTransport{Base: base, path: path}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *RecordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody, err := readBody(&req.Body)
+	if err != nil {
+		return nil, err
+	}
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := readBody(&resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.interactions = append(t.interactions, Interaction{
+		Method:       req.Method,
+		URL:          req.URL.String(),
+		RequestBody:  reqBody,
+		StatusCode:   resp.StatusCode,
+		ResponseBody: respBody,
+	})
+	return resp, nil
+}
+
+// Close writes the recorded interactions to the cassette file, replacing
+// any earlier recording.
+func (t *RecordingTransport) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var buf bytes.Buffer
+	for i, in := range t.interactions {
+		if i > 0 {
+			buf.WriteString("\n")
+		}
+		fmt.Fprintf(&buf, "> %s %s\n", in.Method, in.URL)
+		writePrefixed(&buf, "> ", in.RequestBody)
+		fmt.Fprintf(&buf, "< %d\n", in.StatusCode)
+		writePrefixed(&buf, "< ", in.ResponseBody)
+	}
+	return writeFileAtomic(t.path, buf.Bytes())
+}
+
+// ReplayTransport is an http.RoundTripper that serves the interactions of a
+// cassette in the order they were recorded, without network access. A
+// request that doesn't match the next interaction fails.
+type ReplayTransport struct {
+	mu           sync.Mutex
+	interactions []Interaction
+	next         int
+}
+
+// NewReplayTransport returns a transport that plays back the cassette at
+// path.
+func NewReplayTransport(path string) (*ReplayTransport, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("os.Open: %v", err)
+	}
+	defer f.Close()
+	interactions, err := parseCassette(bufio.NewScanner(f))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return &ReplayTransport{interactions: interactions}, nil
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *ReplayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readBody(&req.Body)
+	if err != nil {
+		return nil, err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.next >= len(t.interactions) {
+		return nil, fmt.Errorf("replay: unexpected request %s %s after the last recorded interaction", req.Method, req.URL)
+	}
+	in := t.interactions[t.next]
+	if req.Method != in.Method || req.URL.String() != in.URL {
+		return nil, fmt.Errorf("replay: got request %s %s, want %s %s", req.Method, req.URL, in.Method, in.URL)
+	}
+	if strings.TrimSpace(body) != strings.TrimSpace(in.RequestBody) {
+		return nil, fmt.Errorf("replay: %s %s: got body %s, want %s", req.Method, req.URL, body, in.RequestBody)
+	}
+	t.next++
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", in.StatusCode, http.StatusText(in.StatusCode)),
+		StatusCode:    in.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": []string{"application/json; charset=UTF-8"}},
+		Body:          ioutil.NopCloser(strings.NewReader(in.ResponseBody)),
+		ContentLength: int64(len(in.ResponseBody)),
+		Request:       req,
+	}, nil
+}
+
+// Remaining returns the number of recorded interactions not yet played
+// back.
+func (t *ReplayTransport) Remaining() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.interactions) - t.next
+}
+
+// readBody reads and replaces *body so that it can be read again.
+func readBody(body *io.ReadCloser) (string, error) {
+	if *body == nil {
+		return "", nil
+	}
+	data, err := ioutil.ReadAll(*body)
+	(*body).Close()
+	if err != nil {
+		return "", fmt.Errorf("reading body: %v", err)
+	}
+	*body = ioutil.NopCloser(bytes.NewReader(data))
+	return string(data), nil
+}
+
+// writePrefixed writes each line of text to buf behind prefix.
+func writePrefixed(buf *bytes.Buffer, prefix, text string) {
+	if text == "" {
+		return
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(text, "\n"), "\n") {
+		buf.WriteString(strings.TrimRight(prefix+line, " ") + "\n")
+	}
+}
+
+// parseCassette parses the interactions of a cassette file.
+func parseCassette(s *bufio.Scanner) ([]Interaction, error) {
+	var interactions []Interaction
+	var in *Interaction
+	var req, resp []string
+	flush := func() {
+		if in == nil {
+			return
+		}
+		in.RequestBody = strings.Join(req, "\n")
+		in.ResponseBody = strings.Join(resp, "\n")
+		interactions = append(interactions, *in)
+		in, req, resp = nil, nil, nil
+	}
+	for n := 1; s.Scan(); n++ {
+		line := s.Text()
+		switch {
+		case strings.HasPrefix(line, "#"):
+		case line == "":
+			if in != nil && in.StatusCode == 0 {
+				return nil, fmt.Errorf("line %d: interaction has no response", n)
+			}
+			flush()
+		case line[0] == '>':
+			text := strings.TrimPrefix(line[1:], " ")
+			switch {
+			case in == nil:
+				parts := strings.SplitN(text, " ", 2)
+				if len(parts) != 2 {
+					return nil, fmt.Errorf("line %d: want \"> METHOD URL\", got %q", n, line)
+				}
+				in = &Interaction{Method: parts[0], URL: parts[1]}
+			case in.StatusCode != 0:
+				return nil, fmt.Errorf("line %d: request line after the response", n)
+			default:
+				req = append(req, text)
+			}
+		case line[0] == '<':
+			text := strings.TrimPrefix(line[1:], " ")
+			switch {
+			case in == nil:
+				return nil, fmt.Errorf("line %d: response without a request", n)
+			case in.StatusCode == 0:
+				code, err := strconv.Atoi(text)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: invalid status code %q", n, text)
+				}
+				in.StatusCode = code
+			default:
+				resp = append(resp, text)
+			}
+		default:
+			return nil, fmt.Errorf("line %d: want a line starting with \">\" or \"<\", got %q", n, line)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	if in != nil && in.StatusCode == 0 {
+		return nil, errors.New("last interaction has no response")
+	}
+	flush()
+	return interactions, nil
+}
+
+// WithHTTPClient sends the API requests of the policy target through client,
+// for example one whose Transport is a RecordingTransport or
+// ReplayTransport. The target's endpoints are kept.
+func WithHTTPClient(client *http.Client) Option {
+	return func(m *PolicyManager) error {
+		ctx := context.Background()
+		switch t := m.target.(type) {
+		case *projectsTarget:
+			svc, err := newV1Service(ctx, t.svc, client)
+			if err != nil {
+				return err
+			}
+			m.target = &projectsTarget{svc: svc}
+		case *resourceTarget:
+			v1, err := newV1Service(ctx, t.v1, client)
+			if err != nil {
+				return err
+			}
+			v2, err := crmv2.NewService(ctx, option.WithHTTPClient(client), option.WithEndpoint(t.v2.BasePath))
+			if err != nil {
+				return fmt.Errorf("cloudresourcemanager/v2.NewService: %v", err)
+			}
+			m.target = &resourceTarget{v1: v1, v2: v2}
+		default:
+			return errors.New("policy target does not make HTTP requests")
+		}
+		return nil
+	}
+}
+
+// newV1Service returns a v1 Resource Manager service using client and the
+// endpoint of svc.
+func newV1Service(ctx context.Context, svc *cloudresourcemanager.Service, client *http.Client) (*cloudresourcemanager.Service, error) {
+	s, err := cloudresourcemanager.NewService(ctx, option.WithHTTPClient(client), option.WithEndpoint(svc.BasePath))
+	if err != nil {
+		return nil, fmt.Errorf("cloudresourcemanager.NewService: %v", err)
+	}
+	return s, nil
+}
@@ -0,0 +1,106 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/api/cloudresourcemanager/v1"
+	"google.golang.org/api/option"
+)
+
+const cassettePath = "testdata/add_binding_cassette.txt"
+
+func TestReplayAddBinding(t *testing.T) {
+	ctx := context.Background()
+	replay, err := NewReplayTransport(cassettePath)
+	if err != nil {
+		t.Fatalf("NewReplayTransport: %v", err)
+	}
+	crmService, err := cloudresourcemanager.NewService(ctx, option.WithoutAuthentication())
+	if err != nil {
+		t.Fatalf("cloudresourcemanager.NewService: %v", err)
+	}
+	m, err := NewPolicyManager(NewProjectsTarget(crmService), WithHTTPClient(&http.Client{Transport: replay}))
+	if err != nil {
+		t.Fatalf("NewPolicyManager: %v", err)
+	}
+
+	cs, err := m.AddBinding(ctx, "my-project", "user:alice@example.com", "roles/logging.viewer")
+	if err != nil {
+		t.Fatalf("AddBinding: %v", err)
+	}
+	want := []Change{{Op: OpAdd, Role: "roles/logging.viewer", Member: "user:alice@example.com"}}
+	if diff := cmp.Diff(want, cs.Changes); diff != "" {
+		t.Errorf("AddBinding: got diff (-want +got):\n%s", diff)
+	}
+	if n := replay.Remaining(); n != 0 {
+		t.Errorf("AddBinding: %d recorded interactions not played back", n)
+	}
+
+	if _, err := m.AddBinding(ctx, "my-project", "user:carol@example.com", "roles/logging.viewer"); err == nil {
+		t.Errorf("AddBinding past the end of the cassette: got nil error, want error")
+	}
+}
+
+func TestRecordingTransport(t *testing.T) {
+	ctx := context.Background()
+	replay, err := NewReplayTransport(cassettePath)
+	if err != nil {
+		t.Fatalf("NewReplayTransport: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "cassette.txt")
+	rec := NewRecordingTransport(path, replay)
+	crmService, err := cloudresourcemanager.NewService(ctx, option.WithoutAuthentication())
+	if err != nil {
+		t.Fatalf("cloudresourcemanager.NewService: %v", err)
+	}
+	m, err := NewPolicyManager(NewProjectsTarget(crmService), WithHTTPClient(&http.Client{Transport: rec}))
+	if err != nil {
+		t.Fatalf("NewPolicyManager: %v", err)
+	}
+	if _, err := m.AddBinding(ctx, "my-project", "user:alice@example.com", "roles/logging.viewer"); err != nil {
+		t.Fatalf("AddBinding: %v", err)
+	}
+	if err := rec.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	got, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ioutil.ReadFile: %v", err)
+	}
+	want, err := ioutil.ReadFile(cassettePath)
+	if err != nil {
+		t.Fatalf("ioutil.ReadFile: %v", err)
+	}
+	// The fixture starts with comments, which are not recorded.
+	wantText := string(want[strings.Index(string(want), "\n>")+1:])
+	if diff := cmp.Diff(wantText, string(got)); diff != "" {
+		t.Errorf("recorded cassette: got diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestWithHTTPClientFileTarget(t *testing.T) {
+	if _, err := NewPolicyManager(NewFileTarget("policy.json"), WithHTTPClient(http.DefaultClient)); err == nil {
+		t.Errorf("WithHTTPClient on a file target: got nil error, want error")
+	}
+}
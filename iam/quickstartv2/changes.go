@@ -0,0 +1,216 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"google.golang.org/api/cloudresourcemanager/v1"
+)
+
+// Op is the kind of a policy change.
+type Op string
+
+// Supported change operations.
+const (
+	OpAdd    Op = "add"
+	OpRemove Op = "remove"
+)
+
+// Change is a single member being granted or revoked a role.
+type Change struct {
+	Op        Op                         `json:"op"`
+	Role      string                     `json:"role"`
+	Member    string                     `json:"member"`
+	Condition *cloudresourcemanager.Expr `json:"condition,omitempty"`
+}
+
+// ChangeSet is the set of changes made, or to be made, to one project.
+type ChangeSet struct {
+	Project string   `json:"project"`
+	Changes []Change `json:"changes"`
+	// Reason is why the changes were made, as set with WithReason.
+	Reason string `json:"reason,omitempty"`
+	// Warnings are advisories about a change that was nonetheless made, such
+	// as granting a primitive role or public access.
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// Empty reports whether the change set has no changes.
+func (cs ChangeSet) Empty() bool {
+	return len(cs.Changes) == 0
+}
+
+// OneLine summarizes cs on a single line for terse logs, such as
+// "project=foo added=2 removed=1 roles=[logging.logWriter,storage.objectViewer]".
+// Roles are listed once each, sorted, with any "roles/" prefix dropped.
+func (cs ChangeSet) OneLine() string {
+	var added, removed int
+	seen := make(map[string]bool)
+	var roles []string
+	for _, c := range cs.Changes {
+		if c.Op == OpRemove {
+			removed++
+		} else {
+			added++
+		}
+		r := strings.TrimPrefix(c.Role, "roles/")
+		if !seen[r] {
+			seen[r] = true
+			roles = append(roles, r)
+		}
+	}
+	sort.Strings(roles)
+	return fmt.Sprintf("project=%s added=%d removed=%d roles=[%s]", cs.Project, added, removed, strings.Join(roles, ","))
+}
+
+// ChangeID returns a stable identifier for c made to projectID: a hash of
+// the project, operation, role, member and condition. Identical changes
+// have identical IDs, so consumers can drop duplicate records.
+func ChangeID(projectID string, c Change) string {
+	sum := sha256.Sum256([]byte(projectID + "\x00" + string(c.Op) + "\x00" + c.Role + "\x00" + c.Member + "\x00" + conditionKey(c.Condition)))
+	return hex.EncodeToString(sum[:16])
+}
+
+// copyPolicy returns a deep copy of policy. Nil slices stay nil, so a copy
+// compares equal to the original.
+func copyPolicy(policy *Policy) *Policy {
+	if policy == nil {
+		return nil
+	}
+	out := *policy
+	if policy.Bindings != nil {
+		out.Bindings = make([]*Binding, len(policy.Bindings))
+	}
+	for i, b := range policy.Bindings {
+		out.Bindings[i] = copyBinding(b)
+	}
+	if policy.AuditConfigs != nil {
+		out.AuditConfigs = make([]*cloudresourcemanager.AuditConfig, len(policy.AuditConfigs))
+	}
+	for i, ac := range policy.AuditConfigs {
+		c := *ac
+		if ac.AuditLogConfigs != nil {
+			c.AuditLogConfigs = make([]*cloudresourcemanager.AuditLogConfig, len(ac.AuditLogConfigs))
+		}
+		for j, lc := range ac.AuditLogConfigs {
+			l := *lc
+			l.ExemptedMembers = append([]string(nil), lc.ExemptedMembers...)
+			c.AuditLogConfigs[j] = &l
+		}
+		out.AuditConfigs[i] = &c
+	}
+	return &out
+}
+
+// copyBinding returns a deep copy of b.
+func copyBinding(b *Binding) *Binding {
+	out := *b
+	out.Members = append([]string(nil), b.Members...)
+	if b.Condition != nil {
+		c := *b.Condition
+		out.Condition = &c
+	}
+	return &out
+}
+
+// conditionKey identifies a binding condition; the empty string means none.
+func conditionKey(c *cloudresourcemanager.Expr) string {
+	if c == nil {
+		return ""
+	}
+	return c.Title + "\x00" + c.Description + "\x00" + c.Expression
+}
+
+// grantKey identifies a (role, condition, member) grant.
+type grantKey struct {
+	role, condition, member string
+}
+
+// grants flattens policy into its individual grants, keeping each grant's
+// condition for reporting.
+func grants(policy *Policy) map[grantKey]*cloudresourcemanager.Expr {
+	out := make(map[grantKey]*cloudresourcemanager.Expr)
+	if policy == nil {
+		return out
+	}
+	for _, b := range policy.Bindings {
+		for _, m := range b.Members {
+			out[grantKey{b.Role, conditionKey(b.Condition), m}] = b.Condition
+		}
+	}
+	return out
+}
+
+// ComparePolicies returns the grants added and removed between before and
+// after, sorted by role, then member, then operation. Only bindings are
+// compared; etags, versions and audit configs are ignored.
+func ComparePolicies(before, after *Policy) []Change {
+	return diffPolicies(before, after)
+}
+
+// policyUnchanged reports whether after has the same bindings, audit configs
+// and version as before. changes are the binding changes between the two,
+// as returned by diffPolicies.
+func policyUnchanged(before, after *Policy, changes []Change) bool {
+	if len(changes) > 0 || before.Version != after.Version {
+		return false
+	}
+	if len(before.AuditConfigs) == 0 && len(after.AuditConfigs) == 0 {
+		return true
+	}
+	return reflect.DeepEqual(before.AuditConfigs, after.AuditConfigs)
+}
+
+// diffPolicies returns the changes that turn before into after, sorted by
+// role, then member, then operation.
+func diffPolicies(before, after *Policy) []Change {
+	was, is := grants(before), grants(after)
+	var changes []Change
+	for k, cond := range is {
+		if _, ok := was[k]; !ok {
+			changes = append(changes, Change{Op: OpAdd, Role: k.role, Member: k.member, Condition: cond})
+		}
+	}
+	for k, cond := range was {
+		if _, ok := is[k]; !ok {
+			changes = append(changes, Change{Op: OpRemove, Role: k.role, Member: k.member, Condition: cond})
+		}
+	}
+	sortChanges(changes)
+	return changes
+}
+
+// sortChanges orders changes by role, member, condition, then operation.
+func sortChanges(changes []Change) {
+	sort.Slice(changes, func(i, j int) bool {
+		a, b := changes[i], changes[j]
+		if a.Role != b.Role {
+			return a.Role < b.Role
+		}
+		if a.Member != b.Member {
+			return a.Member < b.Member
+		}
+		if ka, kb := conditionKey(a.Condition), conditionKey(b.Condition); ka != kb {
+			return ka < kb
+		}
+		return a.Op < b.Op
+	})
+}
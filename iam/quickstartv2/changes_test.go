@@ -0,0 +1,87 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/api/cloudresourcemanager/v1"
+)
+
+func TestChangeSetOneLine(t *testing.T) {
+	cs := ChangeSet{
+		Project: "foo",
+		Changes: []Change{
+			{Op: OpAdd, Role: "roles/storage.objectViewer", Member: "user:alice@example.com"},
+			{Op: OpAdd, Role: "roles/logging.logWriter", Member: "user:alice@example.com"},
+			{Op: OpRemove, Role: "roles/storage.objectViewer", Member: "user:bob@example.com"},
+		},
+	}
+	want := "project=foo added=2 removed=1 roles=[logging.logWriter,storage.objectViewer]"
+	if got := cs.OneLine(); got != want {
+		t.Errorf("OneLine: got %q, want %q", got, want)
+	}
+}
+
+func TestChangeID(t *testing.T) {
+	c := Change{Op: OpAdd, Role: "roles/viewer", Member: "user:alice@example.com"}
+	same := Change{Op: OpAdd, Role: "roles/viewer", Member: "user:alice@example.com"}
+	if ChangeID("my-project", c) != ChangeID("my-project", same) {
+		t.Errorf("ChangeID: identical changes got different IDs")
+	}
+
+	others := []struct {
+		project string
+		change  Change
+	}{
+		{"other-project", c},
+		{"my-project", Change{Op: OpRemove, Role: c.Role, Member: c.Member}},
+		{"my-project", Change{Op: OpAdd, Role: "roles/editor", Member: c.Member}},
+		{"my-project", Change{Op: OpAdd, Role: c.Role, Member: "user:bob@example.com"}},
+		{"my-project", Change{Op: OpAdd, Role: c.Role, Member: c.Member, Condition: &cloudresourcemanager.Expr{Title: "t", Expression: "true"}}},
+	}
+	for _, o := range others {
+		if ChangeID(o.project, o.change) == ChangeID("my-project", c) {
+			t.Errorf("ChangeID(%s, %+v): got the same ID as a different change", o.project, o.change)
+		}
+	}
+}
+
+func TestCopyPolicy(t *testing.T) {
+	for _, policy := range []*Policy{
+		{Etag: "etag-1"},
+		{Bindings: []*Binding{}, AuditConfigs: []*cloudresourcemanager.AuditConfig{}},
+		{
+			Bindings: []*Binding{{Role: "roles/viewer", Members: []string{"user:alice@example.com"}}},
+			AuditConfigs: []*cloudresourcemanager.AuditConfig{{
+				Service:         "allServices",
+				AuditLogConfigs: []*cloudresourcemanager.AuditLogConfig{{LogType: "DATA_READ", ExemptedMembers: []string{"user:bob@example.com"}}},
+			}},
+		},
+	} {
+		got := copyPolicy(policy)
+		if diff := cmp.Diff(policy, got); diff != "" {
+			t.Errorf("copyPolicy: got diff (-want +got):\n%s", diff)
+		}
+		if len(got.Bindings) > 0 {
+			got.Bindings[0].Members[0] = "user:mallory@example.com"
+			got.AuditConfigs[0].AuditLogConfigs[0].ExemptedMembers[0] = "user:mallory@example.com"
+			if policy.Bindings[0].Members[0] == got.Bindings[0].Members[0] || policy.AuditConfigs[0].AuditLogConfigs[0].ExemptedMembers[0] == got.AuditConfigs[0].AuditLogConfigs[0].ExemptedMembers[0] {
+				t.Errorf("copyPolicy: modifying the copy modified the original")
+			}
+		}
+	}
+}
@@ -0,0 +1,337 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"google.golang.org/api/cloudresourcemanager/v1"
+)
+
+// Flags of the command-line tool's commands beyond the quickstart flow in
+// quickstart.go. They are parsed together with the quickstart's flags.
+var (
+	roleFileFlag   = flag.String("role-file", "", "File of roles to grant to the member")
+	quietFlag      = flag.Bool("quiet", false, "Print a one-line summary of the changes")
+	dryRunFlag     = flag.Bool("dry-run", false, "Explain the changes without making them")
+	formatFlag     = flag.String("format", "text", "How to print changes: text or udiff")
+	editFlag       = flag.Bool("edit", false, "Edit the project's policy in $EDITOR")
+	sinceEtagFlag  = flag.String("since-etag", "", "Print the project's policy only if its etag differs")
+	removeRoleFlag = flag.String("remove-role", "", "Remove a role from every member, after confirmation")
+	outputFileFlag = flag.String("output-file", "", "Write output to this file instead of stdout")
+	resolverFlag   = flag.String("member-resolver", "", "Program that prints the IAM member for an alias given as its argument")
+)
+
+// runCLI runs the command selected by the command-line flags on projectID
+// and reports whether there was one. If not, main runs the quickstart flow.
+// Failures are fatal.
+func runCLI(ctx context.Context, crmService *cloudresourcemanager.Service, projectID, member string) bool {
+	if err := checkCLIFlags(); err != nil {
+		log.Fatal(err)
+	}
+	if !*editFlag && *sinceEtagFlag == "" && *removeRoleFlag == "" && *roleFileFlag == "" {
+		return false
+	}
+	m, err := NewPolicyManager(NewProjectsTarget(crmService), cliManagerOptions()...)
+	if err != nil {
+		log.Fatalf("NewPolicyManager: %v", err)
+	}
+	defer m.Close()
+	switch {
+	case *editFlag:
+		// Opens the project's policy in your editor
+		if err := editPolicy(ctx, os.Stdout, m, projectID); err != nil {
+			log.Fatalf("editPolicy: %v", err)
+		}
+	case *sinceEtagFlag != "":
+		// Prints the project's policy if it changed since the given etag
+		out := openOutput(*outputFileFlag)
+		if err := printIfChanged(ctx, out, m, projectID, *sinceEtagFlag); err != nil {
+			log.Fatalf("printIfChanged: %v", err)
+		}
+		if err := out.Close(); err != nil {
+			log.Fatalf("writing output: %v", err)
+		}
+	case *removeRoleFlag != "":
+		// Removes a role from the project once you confirm
+		if err := removeRole(ctx, os.Stdin, os.Stdout, m, projectID, *removeRoleFlag); err != nil {
+			log.Fatalf("removeRole: %v", err)
+		}
+	case *roleFileFlag != "":
+		// Grants your member every role in the role file
+		out := openOutput(*outputFileFlag)
+		opts := cliOptions{quiet: *quietFlag, dryRun: *dryRunFlag, format: *formatFlag}
+		if err := grantRoleFile(ctx, out, m, projectID, member, *roleFileFlag, opts); err != nil {
+			log.Fatalf("grantRoleFile: %v", err)
+		}
+		if err := out.Close(); err != nil {
+			log.Fatalf("writing output: %v", err)
+		}
+	}
+	return true
+}
+
+// cliManagerOptions returns the manager options set by command-line flags.
+// Every command uses them, so members are resolved the same way throughout.
+func cliManagerOptions() []Option {
+	var opts []Option
+	if *resolverFlag != "" {
+		opts = append(opts, WithMemberResolver(commandResolver{path: *resolverFlag}))
+	}
+	return opts
+}
+
+// checkCLIFlags returns an error if a flag is set that the selected command
+// would ignore.
+func checkCLIFlags() error {
+	if *roleFileFlag == "" || *editFlag || *sinceEtagFlag != "" || *removeRoleFlag != "" {
+		if *quietFlag {
+			return errors.New("-quiet is only supported with -role-file")
+		}
+		// Refused, so that a dry run never falls through to a command
+		// that changes the policy.
+		if *dryRunFlag {
+			return errors.New("-dry-run is only supported with -role-file")
+		}
+	}
+	if *quietFlag && *formatFlag == "udiff" {
+		return errors.New("-quiet can't be combined with -format=udiff")
+	}
+	// -edit and -remove-role take precedence over -since-etag and
+	// -role-file, and write to stdout like the quickstart flow.
+	if *outputFileFlag != "" && ((*sinceEtagFlag == "" && *roleFileFlag == "") || *editFlag || *removeRoleFlag != "") {
+		return errors.New("-output-file is only supported with -since-etag or -role-file")
+	}
+	return nil
+}
+
+// cliOptions are the output settings of the command line.
+type cliOptions struct {
+	// quiet prints a one-line summary instead of every change.
+	quiet bool
+	// dryRun explains each change instead of making it.
+	dryRun bool
+	// format is how changes are printed: "text", one line per change, or
+	// "udiff", a unified diff of the policy.
+	format string
+}
+
+// grantRoleFile grants member every role listed in roleFile on projectID
+// and writes the changes made to w.
+func grantRoleFile(ctx context.Context, w io.Writer, m *PolicyManager, projectID, member, roleFile string, opts cliOptions) error {
+	if opts.format != "" && opts.format != "text" && opts.format != "udiff" {
+		return fmt.Errorf("unknown format %q, want text or udiff", opts.format)
+	}
+	roles, err := ReadRoleFile(roleFile)
+	if err != nil {
+		return err
+	}
+	if opts.dryRun {
+		// Explains the member as AddRoles would write it.
+		member, err := m.member(member)
+		if err != nil {
+			return err
+		}
+		policy, err := m.GetPolicy(ctx, projectID)
+		if err != nil {
+			return err
+		}
+		for _, role := range roles {
+			c := Change{Op: OpAdd, Role: role, Member: member}
+			if _, err := fmt.Fprintf(w, "%s %s: %s\n", c.Role, c.Member, ExplainChange(policy, c)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if opts.format == "udiff" {
+		before, cs, err := m.addRoles(ctx, projectID, member, roles)
+		if err != nil {
+			return err
+		}
+		diff, err := cs.UnifiedDiff(before)
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(w, diff)
+		return err
+	}
+	cs, err := m.AddRoles(ctx, projectID, member, roles)
+	if err != nil {
+		return err
+	}
+	if opts.quiet {
+		_, err := fmt.Fprintln(w, cs.OneLine())
+		return err
+	}
+	return printChanges(w, cs)
+}
+
+// printChanges writes one line per change in cs to w.
+func printChanges(w io.Writer, cs ChangeSet) error {
+	if cs.Empty() {
+		_, err := fmt.Fprintf(w, "%s: no changes\n", cs.Project)
+		return err
+	}
+	for _, c := range cs.Changes {
+		sign := "+"
+		if c.Op == OpRemove {
+			sign = "-"
+		}
+		if _, err := fmt.Fprintf(w, "%s %s %s\n", sign, c.Role, c.Member); err != nil {
+			return err
+		}
+	}
+	for _, warning := range cs.Warnings {
+		if _, err := fmt.Fprintf(w, "WARNING: %s\n", warning); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// editPolicy opens the policy of projectID in the user's $EDITOR, vi by
+// default, and writes back the edited policy.
+func editPolicy(ctx context.Context, w io.Writer, m *PolicyManager, projectID string) error {
+	if _, err := EditPolicy(ctx, m, projectID, runEditor); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "%s: policy updated\n", projectID)
+	return err
+}
+
+// runEditor writes current to a temporary file, opens it in $EDITOR and
+// returns the file's contents once the editor exits.
+func runEditor(current []byte) ([]byte, error) {
+	f, err := ioutil.TempFile("", "policy-*.json")
+	if err != nil {
+		return nil, fmt.Errorf("ioutil.TempFile: %v", err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(current); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("Write: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		return nil, fmt.Errorf("Close: %v", err)
+	}
+
+	editor := os.Getenv("EDITOR")
+	if editor == "" {
+		editor = "vi"
+	}
+	cmd := exec.Command(editor, f.Name())
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%s: %v", editor, err)
+	}
+	edited, err := ioutil.ReadFile(f.Name())
+	if err != nil {
+		return nil, fmt.Errorf("ioutil.ReadFile: %v", err)
+	}
+	return edited, nil
+}
+
+// printIfChanged writes the policy of projectID to w as JSON if its etag
+// differs from knownEtag, or a line saying it is unchanged.
+func printIfChanged(ctx context.Context, w io.Writer, m *PolicyManager, projectID, knownEtag string) error {
+	policy, changed, err := ListIfChanged(ctx, m, projectID, knownEtag)
+	if err != nil {
+		return err
+	}
+	if !changed {
+		_, err := fmt.Fprintf(w, "%s: unchanged since etag %s\n", projectID, knownEtag)
+		return err
+	}
+	return ExportPolicy(w, policy, ExportOptions{})
+}
+
+// removeRole prints the impact of removing role from projectID to w and
+// removes it once the user confirms on in.
+func removeRole(ctx context.Context, in io.Reader, w io.Writer, m *PolicyManager, projectID, role string) error {
+	impact, err := EstimateRemovalImpact(ctx, m, projectID, role)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintln(w, impact); err != nil {
+		return err
+	}
+	if len(impact.Members) == 0 {
+		return nil
+	}
+	if _, err := fmt.Fprint(w, "Remove the role? [y/N] "); err != nil {
+		return err
+	}
+	answer, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return fmt.Errorf("ReadString: %v", err)
+	}
+	if a := strings.ToLower(strings.TrimSpace(answer)); a != "y" && a != "yes" {
+		_, err := fmt.Fprintln(w, "Not removed.")
+		return err
+	}
+	cs, err := m.RemoveBinding(ctx, projectID, role)
+	if err != nil {
+		return err
+	}
+	return printChanges(w, cs)
+}
+
+// openOutput returns where command output is written: stdout if path is
+// empty, otherwise a buffer that Close writes atomically to path, creating
+// its parent directories. Output from a command that fails before Close
+// never reaches the file.
+func openOutput(path string) io.WriteCloser {
+	if path == "" {
+		return nopCloser{os.Stdout}
+	}
+	return &atomicOutput{path: path}
+}
+
+// nopCloser is a writer whose Close does nothing.
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
+
+// atomicOutput buffers output for a file and writes it on Close.
+type atomicOutput struct {
+	path string
+	buf  bytes.Buffer
+}
+
+func (o *atomicOutput) Write(p []byte) (int, error) {
+	return o.buf.Write(p)
+}
+
+func (o *atomicOutput) Close() error {
+	if err := os.MkdirAll(filepath.Dir(o.path), 0755); err != nil {
+		return fmt.Errorf("os.MkdirAll: %v", err)
+	}
+	return writeFileAtomic(o.path, o.buf.Bytes())
+}
@@ -0,0 +1,199 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestOpenOutput(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "reports", "2020", "policy.json")
+	out := openOutput(path)
+	fmt.Fprintln(out, "line 1")
+	fmt.Fprintln(out, "line 2")
+
+	// Nothing is written until Close.
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("before Close: got Stat error %v, want not exist", err)
+	}
+	if err := out.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	got, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ioutil.ReadFile: %v", err)
+	}
+	if want := "line 1\nline 2\n"; string(got) != want {
+		t.Errorf("output file: got %q, want %q", got, want)
+	}
+	files, err := ioutil.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatalf("ioutil.ReadDir: %v", err)
+	}
+	if len(files) != 1 {
+		t.Errorf("output directory: got %d files, want only the output file", len(files))
+	}
+	if len(files) == 1 && files[0].Mode().Perm() != 0644 {
+		t.Errorf("output file: got mode %v, want 0644", files[0].Mode().Perm())
+	}
+}
+
+// setFlags sets command-line flags for the duration of the test.
+func setFlags(t *testing.T, values map[string]string) {
+	t.Helper()
+	for name, v := range values {
+		f := flag.Lookup(name)
+		old := f.Value.String()
+		if err := flag.Set(name, v); err != nil {
+			t.Fatalf("flag.Set(%s): %v", name, err)
+		}
+		name := name
+		t.Cleanup(func() { flag.Set(name, old) })
+	}
+}
+
+func TestCheckCLIFlags(t *testing.T) {
+	for _, tc := range []struct {
+		flags   map[string]string
+		wantErr bool
+	}{
+		{flags: map[string]string{}},
+		{flags: map[string]string{"role-file": "roles.txt", "quiet": "true"}},
+		{flags: map[string]string{"quiet": "true"}, wantErr: true},
+		{flags: map[string]string{"role-file": "roles.txt", "remove-role": "roles/viewer", "quiet": "true"}, wantErr: true},
+		{flags: map[string]string{"role-file": "roles.txt", "dry-run": "true"}},
+		{flags: map[string]string{"role-file": "roles.txt", "format": "udiff"}},
+		{flags: map[string]string{"role-file": "roles.txt", "format": "udiff", "quiet": "true"}, wantErr: true},
+		{flags: map[string]string{"dry-run": "true"}, wantErr: true},
+		{flags: map[string]string{"edit": "true", "dry-run": "true"}, wantErr: true},
+		{flags: map[string]string{"remove-role": "roles/viewer", "dry-run": "true"}, wantErr: true},
+		{flags: map[string]string{"since-etag": "etag-1", "output-file": "out.json"}},
+		{flags: map[string]string{"role-file": "roles.txt", "output-file": "out.txt"}},
+		{flags: map[string]string{"output-file": "out.json"}, wantErr: true},
+		{flags: map[string]string{"edit": "true", "output-file": "out.json"}, wantErr: true},
+		{flags: map[string]string{"remove-role": "roles/viewer", "output-file": "out.txt"}, wantErr: true},
+		{flags: map[string]string{"since-etag": "etag-1", "remove-role": "roles/viewer", "output-file": "out.txt"}, wantErr: true},
+	} {
+		t.Run(fmt.Sprint(tc.flags), func(t *testing.T) {
+			setFlags(t, tc.flags)
+			if err := checkCLIFlags(); (err != nil) != tc.wantErr {
+				t.Errorf("checkCLIFlags: got %v, want error %v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestGrantRoleFileResolvesMember(t *testing.T) {
+	ctx := context.Background()
+	target := newFakeTarget()
+	target.put("my-project", &Policy{})
+	resolver := MemberResolverFunc(func(input string) (string, error) {
+		return "user:" + input + "@example.com", nil
+	})
+	m := newTestManager(t, target, WithMemberResolver(resolver))
+	roleFile := writeTempFile(t, "roles.txt", "roles/logging.viewer\n")
+
+	var buf bytes.Buffer
+	if err := grantRoleFile(ctx, &buf, m, "my-project", "alice", roleFile, cliOptions{dryRun: true}); err != nil {
+		t.Fatalf("grantRoleFile (dry run): %v", err)
+	}
+	if want := "roles/logging.viewer user:alice@example.com: "; !strings.HasPrefix(buf.String(), want) {
+		t.Errorf("grantRoleFile (dry run): got %q, want prefix %q", buf.String(), want)
+	}
+
+	buf.Reset()
+	if err := grantRoleFile(ctx, &buf, m, "my-project", "alice", roleFile, cliOptions{}); err != nil {
+		t.Fatalf("grantRoleFile: %v", err)
+	}
+	if want := "+ roles/logging.viewer user:alice@example.com\n"; buf.String() != want {
+		t.Errorf("grantRoleFile: got %q, want %q", buf.String(), want)
+	}
+}
+
+func TestMemberResolverFlag(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the resolver is a shell script")
+	}
+	ctx := context.Background()
+	dir := t.TempDir()
+	resolver := filepath.Join(dir, "resolve-alias")
+	script := "#!/bin/sh\ncase \"$1\" in\nalice) echo user:alice@example.com ;;\n*) echo \"unknown alias $1\" >&2; exit 1 ;;\nesac\n"
+	if err := ioutil.WriteFile(resolver, []byte(script), 0755); err != nil {
+		t.Fatalf("ioutil.WriteFile: %v", err)
+	}
+	setFlags(t, map[string]string{"member-resolver": resolver})
+	target := newFakeTarget()
+	target.put("my-project", &Policy{})
+	m := newTestManager(t, target, cliManagerOptions()...)
+	roleFile := writeTempFile(t, "roles.txt", "roles/logging.viewer\n")
+
+	var buf bytes.Buffer
+	if err := grantRoleFile(ctx, &buf, m, "my-project", "alice", roleFile, cliOptions{}); err != nil {
+		t.Fatalf("grantRoleFile(alice): %v", err)
+	}
+	if want := "+ roles/logging.viewer user:alice@example.com\n"; buf.String() != want {
+		t.Errorf("grantRoleFile(alice): got %q, want %q", buf.String(), want)
+	}
+	if err := grantRoleFile(ctx, &buf, m, "my-project", "mallory", roleFile, cliOptions{}); err == nil {
+		t.Errorf("grantRoleFile(mallory): got nil error, want error")
+	}
+	if target.sets != 1 {
+		t.Errorf("grantRoleFile: got %d SetPolicy calls, want 1", target.sets)
+	}
+}
+
+func TestGrantRoleFileUnifiedDiffAfterConflict(t *testing.T) {
+	ctx := context.Background()
+	target := newFakeTarget()
+	target.put("my-project", &Policy{})
+	// The first write conflicts with a grant made in the meantime, which
+	// the retry reads.
+	target.setErrs = []error{conflictErr}
+	target.onGet = func(resource string, gets int) {
+		if gets == 2 {
+			target.policies[resource] = &Policy{Etag: "etag-other", Bindings: []*Binding{
+				{Role: "roles/viewer", Members: []string{"user:bob@example.com"}},
+			}}
+		}
+	}
+	m := newTestManager(t, target)
+	roleFile := writeTempFile(t, "roles.txt", "roles/viewer\n")
+
+	var buf bytes.Buffer
+	if err := grantRoleFile(ctx, &buf, m, "my-project", "user:alice@example.com", roleFile, cliOptions{format: "udiff"}); err != nil {
+		t.Fatalf("grantRoleFile: %v", err)
+	}
+	var added []string
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if strings.HasPrefix(line, "+") && !strings.HasPrefix(line, "+++") {
+			added = append(added, strings.TrimSpace(strings.TrimPrefix(line, "+")))
+		}
+	}
+	if diff := cmp.Diff([]string{`"user:alice@example.com",`}, added); diff != "" {
+		t.Errorf("grantRoleFile: got added lines diff (-want +got):\n%s\nin:\n%s", diff, buf.String())
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// CommonBinding is a grant found on many projects.
+type CommonBinding struct {
+	Role   string `json:"role"`
+	Member string `json:"member"`
+	// Projects are the projects, sorted, that have the grant.
+	Projects []string `json:"projects"`
+}
+
+// FindCommonBindings returns the unconditional grants present on every one
+// of projectIDs, sorted by role and then member. Such org-standard grants
+// are candidates to be granted once on a parent folder or the organization
+// instead. Policies are fetched at most concurrency at a time, and every
+// policy must be fetched for the result to be returned.
+func FindCommonBindings(ctx context.Context, svc *PolicyManager, projectIDs []string, concurrency int) ([]CommonBinding, error) {
+	return FindBindingsOnAtLeast(ctx, svc, projectIDs, concurrency, len(projectIDs))
+}
+
+// FindBindingsOnAtLeast is FindCommonBindings for the grants present on at
+// least minProjects of projectIDs.
+func FindBindingsOnAtLeast(ctx context.Context, svc *PolicyManager, projectIDs []string, concurrency, minProjects int) ([]CommonBinding, error) {
+	report, err := BuildAccessReport(ctx, svc, projectIDs, concurrency)
+	if err != nil {
+		return nil, err
+	}
+
+	found := make(map[grantKey][]string)
+	for _, pa := range report.Projects {
+		if pa.Error != "" {
+			return nil, fmt.Errorf("%s: %s", pa.Project, pa.Error)
+		}
+		seen := make(map[grantKey]bool)
+		for _, b := range pa.Bindings {
+			if b.Condition != nil {
+				continue
+			}
+			for _, m := range b.Members {
+				k := grantKey{role: b.Role, member: m}
+				if !seen[k] {
+					seen[k] = true
+					found[k] = append(found[k], pa.Project)
+				}
+			}
+		}
+	}
+
+	var common []CommonBinding
+	for k, projects := range found {
+		if len(projects) >= minProjects {
+			common = append(common, CommonBinding{Role: k.role, Member: k.member, Projects: projects})
+		}
+	}
+	sort.Slice(common, func(i, j int) bool {
+		if common[i].Role != common[j].Role {
+			return common[i].Role < common[j].Role
+		}
+		return common[i].Member < common[j].Member
+	})
+	return common, nil
+}
@@ -0,0 +1,71 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFindCommonBindings(t *testing.T) {
+	ctx := context.Background()
+	target := newFakeTarget()
+	for _, p := range []string{"project-a", "project-b", "project-c"} {
+		target.put(p, &Policy{Bindings: []*Binding{
+			{Role: "roles/logging.viewer", Members: []string{"group:sre@example.com"}},
+		}})
+	}
+	target.put("project-b", &Policy{Bindings: []*Binding{
+		{Role: "roles/logging.viewer", Members: []string{"group:sre@example.com"}},
+		{Role: "roles/editor", Members: []string{"user:alice@example.com"}},
+	}})
+	target.put("project-c", &Policy{Bindings: []*Binding{
+		{Role: "roles/logging.viewer", Members: []string{"group:sre@example.com"}},
+		{Role: "roles/editor", Members: []string{"user:alice@example.com"}},
+	}})
+	m := newTestManager(t, target)
+	projects := []string{"project-c", "project-a", "project-b"}
+
+	got, err := FindCommonBindings(ctx, m, projects, 2)
+	if err != nil {
+		t.Fatalf("FindCommonBindings: %v", err)
+	}
+	want := []CommonBinding{
+		{Role: "roles/logging.viewer", Member: "group:sre@example.com", Projects: []string{"project-a", "project-b", "project-c"}},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("FindCommonBindings: got diff (-want +got):\n%s", diff)
+	}
+
+	got, err = FindBindingsOnAtLeast(ctx, m, projects, 2, 2)
+	if err != nil {
+		t.Fatalf("FindBindingsOnAtLeast: %v", err)
+	}
+	want = append([]CommonBinding{
+		{Role: "roles/editor", Member: "user:alice@example.com", Projects: []string{"project-b", "project-c"}},
+	}, want...)
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("FindBindingsOnAtLeast: got diff (-want +got):\n%s", diff)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := FindCommonBindings(cancelled, m, projects, 2); !errors.Is(err, context.Canceled) {
+		t.Errorf("FindCommonBindings(cancelled): got %v, want context.Canceled", err)
+	}
+}
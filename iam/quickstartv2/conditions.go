@@ -0,0 +1,327 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"google.golang.org/api/cloudresourcemanager/v1"
+)
+
+// ErrConditionNotFound is returned when a policy has no binding with the
+// requested condition.
+var ErrConditionNotFound = errors.New("conditional binding not found")
+
+// hasConditions reports whether any binding in policy has a condition.
+func hasConditions(policy *Policy) bool {
+	for _, b := range policy.Bindings {
+		if b.Condition != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// conditionFields are the fields available to condition templates.
+type conditionFields struct {
+	Member string
+	Role   string
+	// Expiry is the expiry time in RFC 3339 format.
+	Expiry string
+	// Reason is the reason set with WithReason, or empty.
+	Reason string
+}
+
+// Default templates for the conditions of temporary grants.
+var (
+	defaultTitleTemplate = template.Must(template.New("title").Parse("temporary-access"))
+	defaultDescTemplate  = template.Must(template.New("description").Parse(
+		"Temporary access for {{.Member}} until {{.Expiry}}{{if .Reason}} (reason: {{.Reason}}){{end}}"))
+)
+
+// WithConditionTemplate sets the text/template templates used to generate
+// the title and description of the conditions created by GrantUntil and
+// GrantFor, such as "temp-access for {{.Member}} until {{.Expiry}}". The
+// templates can use the fields Member, Role, Expiry and Reason. An empty
+// template keeps the default.
+func WithConditionTemplate(titleTmpl, descTmpl string) Option {
+	return func(m *PolicyManager) error {
+		title, err := parseConditionTemplate("title", titleTmpl)
+		if err != nil {
+			return err
+		}
+		desc, err := parseConditionTemplate("description", descTmpl)
+		if err != nil {
+			return err
+		}
+		if title != nil {
+			m.titleTmpl = title
+		}
+		if desc != nil {
+			m.descTmpl = desc
+		}
+		return nil
+	}
+}
+
+// parseConditionTemplate parses text and checks that it renders with sample
+// fields. It returns nil for an empty text.
+func parseConditionTemplate(name, text string) (*template.Template, error) {
+	if text == "" {
+		return nil, nil
+	}
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("condition %s template: %v", name, err)
+	}
+	sample := conditionFields{Member: "user:alice@example.com", Role: "roles/viewer", Expiry: "2020-01-01T00:00:00Z", Reason: "TICKET-1"}
+	if err := tmpl.Execute(ioutil.Discard, sample); err != nil {
+		return nil, fmt.Errorf("condition %s template: %v", name, err)
+	}
+	return tmpl, nil
+}
+
+// expiryCondition returns a condition granting role to member that holds
+// until expiry. Its title and description are rendered from the manager's
+// condition templates.
+func (m *PolicyManager) expiryCondition(member, role string, expiry time.Time, reason string) (*cloudresourcemanager.Expr, error) {
+	ts := expiry.UTC().Format(time.RFC3339)
+	fields := conditionFields{Member: member, Role: role, Expiry: ts, Reason: reason}
+	titleTmpl, descTmpl := defaultTitleTemplate, defaultDescTemplate
+	if m.titleTmpl != nil {
+		titleTmpl = m.titleTmpl
+	}
+	if m.descTmpl != nil {
+		descTmpl = m.descTmpl
+	}
+	var title, desc strings.Builder
+	if err := titleTmpl.Execute(&title, fields); err != nil {
+		return nil, fmt.Errorf("condition title template: %v", err)
+	}
+	if err := descTmpl.Execute(&desc, fields); err != nil {
+		return nil, fmt.Errorf("condition description template: %v", err)
+	}
+	return &cloudresourcemanager.Expr{
+		Title:       title.String(),
+		Description: desc.String(),
+		Expression:  fmt.Sprintf("request.time < timestamp(%q)", ts),
+	}, nil
+}
+
+// GrantUntil grants role to member on projectID with a condition that makes
+// the grant expire at expiry. The condition's title and description come
+// from the templates set with WithConditionTemplate; by default the
+// description names the member, the expiry and the reason set with
+// WithReason, if any.
+func (m *PolicyManager) GrantUntil(ctx context.Context, projectID, member, role string, expiry time.Time) (ChangeSet, error) {
+	member, err := m.member(member)
+	if err != nil {
+		return ChangeSet{}, err
+	}
+	cond, err := m.expiryCondition(member, role, expiry, reasonFromContext(ctx))
+	if err != nil {
+		return ChangeSet{}, err
+	}
+	projectID = resourceOrDefault(ctx, projectID)
+	_, cs, err := m.modifyPolicy(ctx, projectID, func(policy *Policy) error {
+		m.addMemberTraced(projectID, policy, member, role, cond)
+		return nil
+	})
+	return cs, err
+}
+
+// GrantFor grants role to member on projectID for duration d from now.
+func (m *PolicyManager) GrantFor(ctx context.Context, projectID, member, role string, d time.Duration) (ChangeSet, error) {
+	return m.GrantUntil(ctx, projectID, member, role, m.now().Add(d))
+}
+
+// ListConditionTitles returns the distinct titles of the conditional bindings
+// in policy, sorted. Untitled conditions are skipped.
+func ListConditionTitles(policy *Policy) []string {
+	seen := make(map[string]bool)
+	var titles []string
+	for _, b := range policy.Bindings {
+		if b.Condition == nil || b.Condition.Title == "" || seen[b.Condition.Title] {
+			continue
+		}
+		seen[b.Condition.Title] = true
+		titles = append(titles, b.Condition.Title)
+	}
+	sort.Strings(titles)
+	return titles
+}
+
+// expiryPattern matches the expressions written by expiryCondition.
+var expiryPattern = regexp.MustCompile(`^\s*request\.time\s*<\s*timestamp\("([^"]+)"\)\s*$`)
+
+// conditionExpiry returns the expiry of expr if it is a plain
+// request.time < timestamp("...") expression.
+func conditionExpiry(expr string) (time.Time, bool) {
+	match := expiryPattern.FindStringSubmatch(expr)
+	if match == nil {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(time.RFC3339, match[1])
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// RelativeExpiry renders expr as the time left until it expires, measured
+// from now, such as "expires in 3d 4h" or "expired". Expressions other than
+// request.time < timestamp("...") are returned unchanged.
+func RelativeExpiry(expr string, now time.Time) string {
+	expiry, ok := conditionExpiry(expr)
+	if !ok {
+		return expr
+	}
+	left := expiry.Sub(now)
+	if left <= 0 {
+		return "expired"
+	}
+	days := int(left / (24 * time.Hour))
+	hours := int(left % (24 * time.Hour) / time.Hour)
+	minutes := int(left % time.Hour / time.Minute)
+	switch {
+	case days > 0:
+		return fmt.Sprintf("expires in %dd %dh", days, hours)
+	case hours > 0:
+		return fmt.Sprintf("expires in %dh %dm", hours, minutes)
+	case minutes > 0:
+		return fmt.Sprintf("expires in %dm", minutes)
+	}
+	return "expires in <1m"
+}
+
+// Condition categories reported by ClassifyConditions.
+const (
+	ConditionTime     = "time"
+	ConditionResource = "resource"
+	ConditionIP       = "ip"
+	ConditionOther    = "other"
+)
+
+// conditionMarkers are the CEL fragments that place an expression in a
+// category.
+var conditionMarkers = []struct {
+	category string
+	markers  []string
+}{
+	{ConditionTime, []string{"request.time"}},
+	{ConditionResource, []string{"resource.name", "resource.type", "resource.service", "resource.matchTag"}},
+	{ConditionIP, []string{"origin.ip", "inIpRange(", "request.auth.access_levels"}},
+}
+
+// ConditionalBinding is a binding that has a condition.
+type ConditionalBinding struct {
+	Role      string
+	Members   []string
+	Condition *cloudresourcemanager.Expr
+}
+
+// ClassifyConditions groups the conditional bindings of policy by the kinds
+// of attributes their expressions reference: ConditionTime for request.time,
+// ConditionResource for resource attributes, ConditionIP for the caller's IP
+// or access levels. A binding whose expression references several kinds is
+// listed under each; one that references none is listed under
+// ConditionOther. Detection is a substring match on the expression, not a
+// full CEL parse.
+func ClassifyConditions(policy *Policy) map[string][]ConditionalBinding {
+	out := make(map[string][]ConditionalBinding)
+	for _, b := range policy.Bindings {
+		if b.Condition == nil {
+			continue
+		}
+		cb := ConditionalBinding{Role: b.Role, Members: b.Members, Condition: b.Condition}
+		matched := false
+		for _, c := range conditionMarkers {
+			for _, marker := range c.markers {
+				if strings.Contains(b.Condition.Expression, marker) {
+					out[c.category] = append(out[c.category], cb)
+					matched = true
+					break
+				}
+			}
+		}
+		if !matched {
+			out[ConditionOther] = append(out[ConditionOther], cb)
+		}
+	}
+	return out
+}
+
+// RemoveFromConditionalBindings removes member from every conditional
+// binding on projectID, such as the temporary grants made by GrantUntil,
+// dropping bindings left empty. Unconditional bindings are left intact.
+func RemoveFromConditionalBindings(ctx context.Context, svc *PolicyManager, projectID, member string) (ChangeSet, error) {
+	member, err := svc.member(member)
+	if err != nil {
+		return ChangeSet{}, err
+	}
+	_, cs, err := svc.modifyPolicy(ctx, projectID, func(policy *Policy) error {
+		bindings := policy.Bindings[:0]
+		for _, b := range policy.Bindings {
+			if b.Condition != nil {
+				b.Members = removeString(b.Members, member)
+			}
+			if b.Condition == nil || len(b.Members) > 0 {
+				bindings = append(bindings, b)
+			}
+		}
+		policy.Bindings = bindings
+		return nil
+	})
+	return cs, err
+}
+
+// RemoveConditionalByTitle removes member from the binding of role on
+// projectID whose condition has the title conditionTitle, dropping the
+// binding if it is left empty. It fails with ErrConditionNotFound if there is
+// no such binding; a member who is not in the binding is not an error.
+func RemoveConditionalByTitle(ctx context.Context, svc *PolicyManager, projectID, member, role, conditionTitle string) error {
+	member, err := svc.member(member)
+	if err != nil {
+		return err
+	}
+	_, _, err = svc.modifyPolicy(ctx, projectID, func(policy *Policy) error {
+		found := false
+		bindings := policy.Bindings[:0]
+		for _, b := range policy.Bindings {
+			if b.Role == role && b.Condition != nil && b.Condition.Title == conditionTitle {
+				found = true
+				b.Members = removeString(b.Members, member)
+				if len(b.Members) == 0 {
+					continue
+				}
+			}
+			bindings = append(bindings, b)
+		}
+		if !found {
+			return fmt.Errorf("%s with condition %q: %w", role, conditionTitle, ErrConditionNotFound)
+		}
+		policy.Bindings = bindings
+		return nil
+	})
+	return err
+}
@@ -0,0 +1,190 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/api/cloudresourcemanager/v1"
+)
+
+func TestListConditionTitles(t *testing.T) {
+	policy := &Policy{Bindings: []*Binding{
+		{Role: "roles/viewer", Members: []string{"user:alice@example.com"}},
+		{
+			Role:      "roles/editor",
+			Members:   []string{"user:alice@example.com"},
+			Condition: &cloudresourcemanager.Expr{Title: "temp-oncall-access", Expression: "true"},
+		},
+		{
+			Role:      "roles/viewer",
+			Members:   []string{"user:bob@example.com"},
+			Condition: &cloudresourcemanager.Expr{Title: "business-hours", Expression: "true"},
+		},
+		{
+			Role:      "roles/owner",
+			Members:   []string{"user:carol@example.com"},
+			Condition: &cloudresourcemanager.Expr{Title: "temp-oncall-access", Expression: "true"},
+		},
+		{
+			Role:      "roles/browser",
+			Members:   []string{"user:carol@example.com"},
+			Condition: &cloudresourcemanager.Expr{Expression: "true"},
+		},
+	}}
+
+	want := []string{"business-hours", "temp-oncall-access"}
+	if diff := cmp.Diff(want, ListConditionTitles(policy)); diff != "" {
+		t.Errorf("ListConditionTitles: got diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestClassifyConditions(t *testing.T) {
+	until := &cloudresourcemanager.Expr{Title: "until", Expression: `request.time < timestamp("2021-01-01T00:00:00Z")`}
+	bucket := &cloudresourcemanager.Expr{Title: "bucket", Expression: `resource.name.startsWith("projects/_/buckets/logs")`}
+	office := &cloudresourcemanager.Expr{Title: "office", Expression: `inIpRange(origin.ip, "203.0.113.0/24")`}
+	both := &cloudresourcemanager.Expr{Title: "both", Expression: `request.time.getHours("UTC") < 18 && resource.type == "storage.googleapis.com/Bucket"`}
+	always := &cloudresourcemanager.Expr{Title: "always", Expression: "true"}
+	policy := &Policy{Bindings: []*Binding{
+		{Role: "roles/viewer", Members: []string{"user:alice@example.com"}},
+		{Role: "roles/editor", Members: []string{"user:alice@example.com"}, Condition: until},
+		{Role: "roles/storage.admin", Members: []string{"user:bob@example.com"}, Condition: bucket},
+		{Role: "roles/storage.admin", Members: []string{"user:carol@example.com"}, Condition: both},
+		{Role: "roles/viewer", Members: []string{"user:dan@example.com"}, Condition: office},
+		{Role: "roles/browser", Members: []string{"user:erin@example.com"}, Condition: always},
+	}}
+
+	got := ClassifyConditions(policy)
+	titles := make(map[string][]string)
+	for category, cbs := range got {
+		for _, cb := range cbs {
+			titles[category] = append(titles[category], cb.Condition.Title)
+		}
+	}
+	want := map[string][]string{
+		ConditionTime:     {"until", "both"},
+		ConditionResource: {"bucket", "both"},
+		ConditionIP:       {"office"},
+		ConditionOther:    {"always"},
+	}
+	if diff := cmp.Diff(want, titles); diff != "" {
+		t.Errorf("ClassifyConditions: got diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestWithConditionTemplate(t *testing.T) {
+	ctx := WithReason(context.Background(), "JIRA-7")
+	target := newFakeTarget()
+	target.put("my-project", &Policy{})
+	m := newTestManager(t, target, WithConditionTemplate(
+		"temp-{{.Role}}",
+		"temp-access for {{.Member}} until {{.Expiry}} [{{.Reason}}]",
+	))
+
+	expiry := time.Date(2020, 7, 1, 0, 0, 0, 0, time.UTC)
+	if _, err := m.GrantUntil(ctx, "my-project", "user:alice@example.com", "roles/viewer", expiry); err != nil {
+		t.Fatalf("GrantUntil: %v", err)
+	}
+	got := target.policy("my-project").Bindings[0].Condition
+	want := &cloudresourcemanager.Expr{
+		Title:       "temp-roles/viewer",
+		Description: "temp-access for user:alice@example.com until 2020-07-01T00:00:00Z [JIRA-7]",
+		Expression:  `request.time < timestamp("2020-07-01T00:00:00Z")`,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("GrantUntil condition: got diff (-want +got):\n%s", diff)
+	}
+
+	for _, bad := range [][2]string{{"{{.Member", ""}, {"", "{{.Ticket}}"}} {
+		if _, err := NewPolicyManager(target, WithConditionTemplate(bad[0], bad[1])); err == nil {
+			t.Errorf("WithConditionTemplate(%q, %q): got nil error, want error", bad[0], bad[1])
+		}
+	}
+}
+
+func TestRemoveFromConditionalBindings(t *testing.T) {
+	ctx := context.Background()
+	temporary := &cloudresourcemanager.Expr{
+		Title:      "temporary-access",
+		Expression: `request.time < timestamp("2020-06-01T00:00:00Z")`,
+	}
+	target := newFakeTarget()
+	target.put("my-project", &Policy{Version: 3, Bindings: []*Binding{
+		{Role: "roles/viewer", Members: []string{"user:alice@example.com"}},
+		{Role: "roles/viewer", Members: []string{"user:alice@example.com", "user:bob@example.com"}, Condition: temporary},
+		{Role: "roles/editor", Members: []string{"user:alice@example.com"}, Condition: temporary},
+	}})
+	m := newTestManager(t, target)
+
+	cs, err := RemoveFromConditionalBindings(ctx, m, "my-project", "user:alice@example.com")
+	if err != nil {
+		t.Fatalf("RemoveFromConditionalBindings: %v", err)
+	}
+	wantChanges := []Change{
+		{Op: OpRemove, Role: "roles/editor", Member: "user:alice@example.com", Condition: temporary},
+		{Op: OpRemove, Role: "roles/viewer", Member: "user:alice@example.com", Condition: temporary},
+	}
+	if diff := cmp.Diff(wantChanges, cs.Changes); diff != "" {
+		t.Errorf("RemoveFromConditionalBindings: got changes diff (-want +got):\n%s", diff)
+	}
+	want := []*Binding{
+		{Role: "roles/viewer", Members: []string{"user:alice@example.com"}},
+		{Role: "roles/viewer", Members: []string{"user:bob@example.com"}, Condition: temporary},
+	}
+	if diff := cmp.Diff(want, target.policy("my-project").Bindings); diff != "" {
+		t.Errorf("RemoveFromConditionalBindings: got bindings diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestRemoveConditionalByTitle(t *testing.T) {
+	ctx := context.Background()
+	businessHours := &cloudresourcemanager.Expr{
+		Title:      "business-hours",
+		Expression: `request.time.getHours("Europe/Amsterdam") >= 9`,
+	}
+	oncall := &cloudresourcemanager.Expr{
+		Title:      "on-call",
+		Expression: `request.time.getHours("Europe/Amsterdam") >= 9`,
+	}
+	target := newFakeTarget()
+	target.put("my-project", &Policy{Version: 3, Bindings: []*Binding{
+		{Role: "roles/compute.admin", Members: []string{"user:alice@example.com"}, Condition: businessHours},
+		{Role: "roles/compute.admin", Members: []string{"user:alice@example.com", "user:bob@example.com"}, Condition: oncall},
+	}})
+	m := newTestManager(t, target)
+
+	if err := RemoveConditionalByTitle(ctx, m, "my-project", "user:alice@example.com", "roles/compute.admin", "on-call"); err != nil {
+		t.Fatalf("RemoveConditionalByTitle: %v", err)
+	}
+	want := []*Binding{
+		{Role: "roles/compute.admin", Members: []string{"user:alice@example.com"}, Condition: businessHours},
+		{Role: "roles/compute.admin", Members: []string{"user:bob@example.com"}, Condition: oncall},
+	}
+	if diff := cmp.Diff(want, target.policy("my-project").Bindings); diff != "" {
+		t.Errorf("RemoveConditionalByTitle: got diff (-want +got):\n%s", diff)
+	}
+
+	err := RemoveConditionalByTitle(ctx, m, "my-project", "user:alice@example.com", "roles/compute.admin", "weekends")
+	if !errors.Is(err, ErrConditionNotFound) {
+		t.Errorf("RemoveConditionalByTitle(unknown title): got %v, want ErrConditionNotFound", err)
+	}
+	if target.sets != 1 {
+		t.Errorf("got %d SetPolicy calls, want 1", target.sets)
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+)
+
+// resourceKey is the context key for the default resource.
+type resourceKey struct{}
+
+// reasonKey is the context key for the reason for a change.
+type reasonKey struct{}
+
+// WithResource returns a copy of ctx that carries resource as the default
+// for PolicyManager methods. A method uses it only when its explicit
+// resource or project argument is empty; a non-empty argument always wins.
+func WithResource(ctx context.Context, resource string) context.Context {
+	return context.WithValue(ctx, resourceKey{}, resource)
+}
+
+// resourceOrDefault returns resource, or the resource set on ctx with
+// WithResource if resource is empty.
+func resourceOrDefault(ctx context.Context, resource string) string {
+	if resource != "" {
+		return resource
+	}
+	if r, ok := ctx.Value(resourceKey{}).(string); ok {
+		return r
+	}
+	return ""
+}
+
+// WithReason returns a copy of ctx that carries the reason for the changes
+// made with it, such as an approval ticket ID. The reason is recorded in
+// audit records and change sets, and in the condition description of
+// temporary grants.
+func WithReason(ctx context.Context, reason string) context.Context {
+	return context.WithValue(ctx, reasonKey{}, reason)
+}
+
+// reasonFromContext returns the reason set on ctx with WithReason, if any.
+func reasonFromContext(ctx context.Context) string {
+	r, _ := ctx.Value(reasonKey{}).(string)
+	return r
+}
@@ -0,0 +1,50 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestWithResource(t *testing.T) {
+	target := newFakeTarget()
+	target.put("ctx-project", &Policy{})
+	target.put("explicit-project", &Policy{})
+	m := newTestManager(t, target)
+	ctx := WithResource(context.Background(), "ctx-project")
+
+	cs, err := m.AddBinding(ctx, "", "user:alice@example.com", "roles/viewer")
+	if err != nil {
+		t.Fatalf("AddBinding: %v", err)
+	}
+	if cs.Project != "ctx-project" {
+		t.Errorf("AddBinding: got project %q, want %q", cs.Project, "ctx-project")
+	}
+	if ok, err := m.HasRole(ctx, "", "user:alice@example.com", "roles/viewer"); err != nil || !ok {
+		t.Errorf("HasRole on context resource: got (%v, %v), want (true, nil)", ok, err)
+	}
+
+	// An explicit argument takes precedence over the context.
+	if _, err := m.AddBinding(ctx, "explicit-project", "user:bob@example.com", "roles/viewer"); err != nil {
+		t.Fatalf("AddBinding: %v", err)
+	}
+	if policyHasRole(target.policy("ctx-project"), "user:bob@example.com", "roles/viewer") {
+		t.Errorf("AddBinding with explicit project wrote to the context resource")
+	}
+	if !policyHasRole(target.policy("explicit-project"), "user:bob@example.com", "roles/viewer") {
+		t.Errorf("AddBinding with explicit project did not write to it")
+	}
+}
@@ -0,0 +1,219 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+
+	"google.golang.org/api/cloudresourcemanager/v1"
+)
+
+// DenyPolicy is an IAM deny policy attached to a resource. The IAM v2 API
+// that serves deny policies is not part of this client library version, so
+// deny policies are read through a DenyPolicyLister supplied by the caller.
+type DenyPolicy struct {
+	Name  string     `json:"name"`
+	Rules []DenyRule `json:"rules"`
+}
+
+// DenyRule denies permissions to principals. Principals use the deny policy
+// syntax, such as "principal://goog/subject/alice@example.com" or
+// "principalSet://goog/group/eng@example.com", and permissions use the
+// "SERVICE.googleapis.com/RESOURCE.VERB" form, where RESOURCE.VERB may end
+// in "*".
+type DenyRule struct {
+	DeniedPrincipals     []string                   `json:"deniedPrincipals"`
+	ExceptionPrincipals  []string                   `json:"exceptionPrincipals,omitempty"`
+	DeniedPermissions    []string                   `json:"deniedPermissions"`
+	ExceptionPermissions []string                   `json:"exceptionPermissions,omitempty"`
+	DenialCondition      *cloudresourcemanager.Expr `json:"denialCondition,omitempty"`
+}
+
+// DenyPolicyLister lists the deny policies attached to a project.
+type DenyPolicyLister interface {
+	ListDenyPolicies(ctx context.Context, projectID string) ([]DenyPolicy, error)
+}
+
+// WithDenyPolicies sets the source of deny policies used by
+// AnalyzeEffectiveness.
+func WithDenyPolicies(l DenyPolicyLister) Option {
+	return func(m *PolicyManager) error {
+		m.denies = l
+		return nil
+	}
+}
+
+// IneffectiveBinding is a role granted to a member whose permissions are all
+// denied to that member by deny policies.
+type IneffectiveBinding struct {
+	Role   string `json:"role"`
+	Member string `json:"member"`
+	// DenyPolicies are the names of the deny policies with rules denying the
+	// role's permissions, sorted.
+	DenyPolicies []string `json:"denyPolicies"`
+}
+
+// AnalyzeEffectiveness returns the bindings on projectID that grant nothing
+// because deny policies on the project deny every permission of the role to
+// the member. Deny rules with a denial condition are ignored, since they may
+// not apply. Role permissions are resolved with the manager's role service.
+// Members that have no deny policy form, such as domain: members, are never
+// reported.
+func AnalyzeEffectiveness(ctx context.Context, svc *PolicyManager, projectID string) ([]IneffectiveBinding, error) {
+	if svc.denies == nil {
+		return nil, errors.New("no deny policy lister configured, see WithDenyPolicies")
+	}
+	projectID = resourceOrDefault(ctx, projectID)
+	policy, err := svc.GetPolicy(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	denies, err := svc.denies.ListDenyPolicies(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []IneffectiveBinding
+	for _, role := range policyRoles(policy) {
+		perms, err := svc.RolePermissions(ctx, role)
+		if err != nil {
+			return nil, err
+		}
+		if len(perms) == 0 {
+			continue
+		}
+		for _, member := range policyMembers(policy, role) {
+			if names, ok := deniedByPolicies(denies, member, perms); ok {
+				out = append(out, IneffectiveBinding{Role: role, Member: member, DenyPolicies: names})
+			}
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Member != out[j].Member {
+			return out[i].Member < out[j].Member
+		}
+		return out[i].Role < out[j].Role
+	})
+	return out, nil
+}
+
+// deniedByPolicies reports whether every permission in perms is denied to
+// member by an unconditional rule in denies, and returns the names of the
+// policies whose rules do so.
+func deniedByPolicies(denies []DenyPolicy, member string, perms []string) ([]string, bool) {
+	principals := denyPrincipals(member)
+	if len(principals) == 0 {
+		return nil, false
+	}
+	used := make(map[string]bool)
+	for _, perm := range perms {
+		denied := false
+		for _, dp := range denies {
+			for _, r := range dp.Rules {
+				if r.DenialCondition == nil && ruleDenies(r, principals, perm) {
+					denied = true
+					used[dp.Name] = true
+				}
+			}
+		}
+		if !denied {
+			return nil, false
+		}
+	}
+	var names []string
+	for name := range used {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, true
+}
+
+// publicPrincipalSet is the deny policy principal set of every user.
+const publicPrincipalSet = "principalSet://goog/public:all"
+
+// denyPrincipals returns the deny policy principals that identify member.
+func denyPrincipals(member string) []string {
+	t, id, err := ParseMember(member)
+	if err != nil {
+		return nil
+	}
+	switch t {
+	case MemberUser:
+		return []string{"principal://goog/subject/" + id, publicPrincipalSet}
+	case MemberGroup:
+		return []string{"principalSet://goog/group/" + id, publicPrincipalSet}
+	case MemberServiceAccount:
+		return []string{"principal://iam.googleapis.com/projects/-/serviceAccounts/" + id, publicPrincipalSet}
+	case MemberAllUsers:
+		return []string{publicPrincipalSet}
+	case MemberPrincipal, MemberPrincipalSet:
+		return []string{member, publicPrincipalSet}
+	}
+	return nil
+}
+
+// ruleDenies reports whether r denies perm to any of principals.
+func ruleDenies(r DenyRule, principals []string, perm string) bool {
+	for _, p := range principals {
+		if containsString(r.ExceptionPrincipals, p) {
+			return false
+		}
+	}
+	denied := false
+	for _, p := range principals {
+		if containsString(r.DeniedPrincipals, p) {
+			denied = true
+		}
+	}
+	if !denied {
+		return false
+	}
+	for _, ex := range r.ExceptionPermissions {
+		if denyPermissionMatches(ex, perm) {
+			return false
+		}
+	}
+	for _, d := range r.DeniedPermissions {
+		if denyPermissionMatches(d, perm) {
+			return true
+		}
+	}
+	return false
+}
+
+// denyPermissionMatches reports whether the deny policy permission pattern,
+// such as "storage.googleapis.com/buckets.*", matches the IAM permission
+// perm, such as "storage.buckets.delete". The pattern's service matches the
+// permission's service prefix, so "cloudresourcemanager.googleapis.com"
+// matches "resourcemanager".
+func denyPermissionMatches(pattern, perm string) bool {
+	i := strings.Index(pattern, "/")
+	if i < 0 {
+		return false
+	}
+	service, rest := strings.TrimSuffix(pattern[:i], ".googleapis.com"), pattern[i+1:]
+	j := strings.Index(perm, ".")
+	if j < 0 || !strings.HasSuffix(service, perm[:j]) {
+		return false
+	}
+	action := perm[j+1:]
+	if strings.HasSuffix(rest, "*") {
+		return strings.HasPrefix(action, strings.TrimSuffix(rest, "*"))
+	}
+	return action == rest
+}
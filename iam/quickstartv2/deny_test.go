@@ -0,0 +1,90 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/api/cloudresourcemanager/v1"
+)
+
+// fakeDenies is a DenyPolicyLister that returns fixed deny policies.
+type fakeDenies map[string][]DenyPolicy
+
+func (f fakeDenies) ListDenyPolicies(ctx context.Context, projectID string) ([]DenyPolicy, error) {
+	return f[projectID], nil
+}
+
+func TestAnalyzeEffectiveness(t *testing.T) {
+	ctx := context.Background()
+	target := newFakeTarget()
+	target.put("my-project", &Policy{Bindings: []*Binding{
+		{Role: "roles/storage.admin", Members: []string{"user:alice@example.com", "user:bob@example.com"}},
+		{Role: "roles/viewer", Members: []string{"user:alice@example.com"}},
+	}})
+	roles := newFakeRoles(map[string][]string{
+		"roles/storage.admin": {"storage.buckets.create", "storage.buckets.delete"},
+		"roles/viewer":        {"resourcemanager.projects.get", "storage.buckets.list"},
+	})
+	denies := fakeDenies{"my-project": {
+		{
+			Name: "policies/no-storage",
+			Rules: []DenyRule{{
+				DeniedPrincipals:  []string{"principal://goog/subject/alice@example.com"},
+				DeniedPermissions: []string{"storage.googleapis.com/buckets.*"},
+			}},
+		},
+		{
+			Name: "policies/conditional",
+			Rules: []DenyRule{{
+				DeniedPrincipals:  []string{"principal://goog/subject/alice@example.com"},
+				DeniedPermissions: []string{"cloudresourcemanager.googleapis.com/projects.get"},
+				DenialCondition:   &cloudresourcemanager.Expr{Expression: "resource.matchTag('env', 'prod')"},
+			}},
+		},
+	}}
+	m := newTestManager(t, target, WithRoleService(roles), WithDenyPolicies(denies))
+
+	got, err := AnalyzeEffectiveness(ctx, m, "my-project")
+	if err != nil {
+		t.Fatalf("AnalyzeEffectiveness: %v", err)
+	}
+	want := []IneffectiveBinding{
+		{Role: "roles/storage.admin", Member: "user:alice@example.com", DenyPolicies: []string{"policies/no-storage"}},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("AnalyzeEffectiveness: got diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestDenyPermissionMatches(t *testing.T) {
+	tests := []struct {
+		pattern, perm string
+		want          bool
+	}{
+		{"storage.googleapis.com/buckets.delete", "storage.buckets.delete", true},
+		{"storage.googleapis.com/buckets.*", "storage.buckets.create", true},
+		{"storage.googleapis.com/buckets.*", "storage.objects.get", false},
+		{"cloudresourcemanager.googleapis.com/projects.delete", "resourcemanager.projects.delete", true},
+		{"compute.googleapis.com/instances.delete", "storage.instances.delete", false},
+	}
+	for _, test := range tests {
+		if got := denyPermissionMatches(test.pattern, test.perm); got != test.want {
+			t.Errorf("denyPermissionMatches(%q, %q): got %v, want %v", test.pattern, test.perm, got, test.want)
+		}
+	}
+}
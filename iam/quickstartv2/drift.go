@@ -0,0 +1,42 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"io"
+)
+
+// DiffAgainstFile compares the live policy of projectID with the policy
+// file at path, such as one exported with "gcloud projects
+// get-iam-policy" in JSON or YAML, and writes the differences to w, one
+// line per grant: "+" for a grant in the file that is missing from the live
+// policy and "-" for a live grant the file doesn't have. A live policy that
+// matches the file is reported as having no changes.
+func DiffAgainstFile(ctx context.Context, svc *PolicyManager, projectID, path string, w io.Writer) error {
+	want, err := LoadPolicyFile(path)
+	if err != nil {
+		return err
+	}
+	live, err := svc.GetPolicy(ctx, projectID)
+	if err != nil {
+		return err
+	}
+	cs := ChangeSet{
+		Project: resourceOrDefault(ctx, projectID),
+		Changes: ComparePolicies(live, want),
+	}
+	return printChanges(w, cs)
+}
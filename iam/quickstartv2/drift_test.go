@@ -0,0 +1,58 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDiffAgainstFile(t *testing.T) {
+	ctx := context.Background()
+	target := newFakeTarget()
+	target.put("my-project", &Policy{Bindings: []*Binding{
+		{Role: "roles/viewer", Members: []string{"user:alice@example.com", "user:bob@example.com"}},
+	}})
+	m := newTestManager(t, target)
+
+	for _, tc := range []struct {
+		name, file, content, want string
+	}{
+		{
+			name:    "matching",
+			file:    "policy.json",
+			content: `{"etag": "BwWKmjvelug=", "bindings": [{"role": "roles/viewer", "members": ["user:bob@example.com", "user:alice@example.com"]}]}`,
+			want:    "my-project: no changes\n",
+		},
+		{
+			name:    "drifted",
+			file:    "policy.yaml",
+			content: "bindings:\n- members:\n  - user:alice@example.com\n  role: roles/viewer\n- members:\n  - group:sre@example.com\n  role: roles/logging.viewer\n",
+			want:    "+ roles/logging.viewer group:sre@example.com\n- roles/viewer user:bob@example.com\n",
+		},
+	} {
+		path := writeTempFile(t, tc.file, tc.content)
+		var buf bytes.Buffer
+		if err := DiffAgainstFile(ctx, m, "my-project", path, &buf); err != nil {
+			t.Fatalf("DiffAgainstFile(%s): %v", tc.name, err)
+		}
+		if diff := cmp.Diff(tc.want, buf.String()); diff != "" {
+			t.Errorf("DiffAgainstFile(%s): got diff (-want +got):\n%s", tc.name, diff)
+		}
+	}
+}
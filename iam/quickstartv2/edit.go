@@ -0,0 +1,67 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// EditPolicy fetches the policy of projectID as indented JSON, passes it to
+// editor and writes back the policy editor returns. The edited JSON must
+// parse and its roles and members must validate, or nothing is written.
+// Only bindings may be edited: changes to the audit configs are rejected.
+// The edit is reduced to the grants added and removed, which are replayed
+// on a freshly fetched policy if the write conflicts with a concurrent
+// change, so editor is called only once. The etag and version in the
+// edited JSON are ignored. It returns the policy as written, or the current
+// policy if the edit changed nothing.
+func EditPolicy(ctx context.Context, svc *PolicyManager, projectID string, editor func(current []byte) ([]byte, error)) (*Policy, error) {
+	current, err := svc.readPolicy(ctx, resourceOrDefault(ctx, projectID))
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.MarshalIndent(current, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("json.MarshalIndent: %v", err)
+	}
+	edited, err := editor(data)
+	if err != nil {
+		return nil, err
+	}
+
+	desired := &Policy{}
+	if err := json.Unmarshal(edited, desired); err != nil {
+		return nil, fmt.Errorf("invalid edited policy: %v", err)
+	}
+	if problems := validatePolicy(desired); len(problems) > 0 {
+		return nil, &ValidationError{Problems: problems}
+	}
+	if !reflect.DeepEqual(current.AuditConfigs, desired.AuditConfigs) {
+		return nil, errors.New("invalid edited policy: audit configs cannot be edited")
+	}
+	changes := diffPolicies(current, desired)
+
+	written, _, err := svc.modifyPolicy(ctx, projectID, func(policy *Policy) error {
+		for _, c := range changes {
+			applyChange(policy, c)
+		}
+		return nil
+	})
+	return written, err
+}
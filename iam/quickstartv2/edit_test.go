@@ -0,0 +1,89 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestEditPolicy(t *testing.T) {
+	ctx := context.Background()
+	target := newFakeTarget()
+	target.put("my-project", &Policy{Bindings: []*Binding{
+		{Role: "roles/viewer", Members: []string{"user:alice@example.com"}},
+	}})
+	// A concurrent change lands while the policy is being edited, and the
+	// first write conflicts.
+	target.onGet = func(resource string, gets int) {
+		if gets == 2 {
+			target.version[resource]++
+			p := target.policies[resource]
+			p.Bindings = append(p.Bindings, &Binding{Role: "roles/editor", Members: []string{"user:bob@example.com"}})
+			p.Etag = "etag-concurrent"
+		}
+	}
+	target.setErrs = []error{conflictErr}
+	m := newTestManager(t, target)
+
+	calls := 0
+	got, err := EditPolicy(ctx, m, "my-project", func(current []byte) ([]byte, error) {
+		calls++
+		return bytes.Replace(current, []byte("roles/viewer"), []byte("roles/browser"), 1), nil
+	})
+	if err != nil {
+		t.Fatalf("EditPolicy: %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("EditPolicy: got %d editor calls, want 1", calls)
+	}
+	if target.sets != 2 {
+		t.Errorf("EditPolicy: got %d SetPolicy calls, want 2", target.sets)
+	}
+	want := []*Binding{
+		{Role: "roles/editor", Members: []string{"user:bob@example.com"}},
+		{Role: "roles/browser", Members: []string{"user:alice@example.com"}},
+	}
+	if diff := cmp.Diff(want, got.Bindings); diff != "" {
+		t.Errorf("EditPolicy: got diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestEditPolicyRejectsInvalid(t *testing.T) {
+	ctx := context.Background()
+	target := newFakeTarget()
+	target.put("my-project", &Policy{})
+	m := newTestManager(t, target)
+
+	for _, edited := range []string{
+		`{"bindings": [`,
+		`{"bindings": [{"role": "viewer", "members": ["alice@example.com"]}]}`,
+		`{"auditConfigs": [{"service": "allServices"}]}`,
+	} {
+		edited := edited
+		_, err := EditPolicy(ctx, m, "my-project", func([]byte) ([]byte, error) {
+			return []byte(edited), nil
+		})
+		if err == nil {
+			t.Errorf("EditPolicy(%s): got nil error, want error", edited)
+		}
+	}
+	if target.sets != 0 {
+		t.Errorf("EditPolicy: got %d SetPolicy calls, want 0", target.sets)
+	}
+}
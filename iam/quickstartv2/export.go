@@ -0,0 +1,141 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ExportOptions controls how a policy is exported.
+type ExportOptions struct {
+	// Clean normalizes, coalesces and sorts the policy before writing it,
+	// producing a canonical, minimal file. By default the policy is written
+	// as returned by the API.
+	Clean bool
+	// YAML writes the policy in the YAML shape printed by
+	// "gcloud projects get-iam-policy" instead of as JSON.
+	YAML bool
+}
+
+// ExportPolicy writes policy to w as indented JSON, or as YAML if
+// opts.YAML is set.
+func ExportPolicy(w io.Writer, policy *Policy, opts ExportOptions) error {
+	if opts.Clean {
+		policy = canonicalPolicy(policy)
+	}
+	if opts.YAML {
+		data, err := MarshalPolicyYAML(policy)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(data); err != nil {
+			return fmt.Errorf("Write: %v", err)
+		}
+		return nil
+	}
+	data, err := json.MarshalIndent(policy, "", "  ")
+	if err != nil {
+		return fmt.Errorf("json.MarshalIndent: %v", err)
+	}
+	if _, err := w.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("Write: %v", err)
+	}
+	return nil
+}
+
+// FilterOptions selects the grants written by ExportFilteredCSV. Every set
+// field must match; the zero value matches every grant.
+type FilterOptions struct {
+	// MemberTypes, if set, keeps only members of these types.
+	MemberTypes []MemberType
+	// RolePrefix, if set, keeps only roles starting with it, such as
+	// "roles/storage.". A trailing "*" is ignored, so "roles/storage.*"
+	// means the same.
+	RolePrefix string
+	// ConditionalOnly keeps only grants that have a condition.
+	ConditionalOnly bool
+}
+
+// matchesRole reports whether role passes the role prefix filter.
+func (o FilterOptions) matchesRole(role string) bool {
+	return strings.HasPrefix(role, strings.TrimSuffix(o.RolePrefix, "*"))
+}
+
+// matchesMember reports whether member passes the member type filter.
+// Members that don't parse only match when no types are set.
+func (o FilterOptions) matchesMember(member string) bool {
+	if len(o.MemberTypes) == 0 {
+		return true
+	}
+	t, _, err := ParseMember(member)
+	if err != nil {
+		return false
+	}
+	for _, want := range o.MemberTypes {
+		if t == want {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesCondition reports whether a binding with condition passes the
+// conditional-only filter.
+func (o FilterOptions) matchesCondition(hasCondition bool) bool {
+	return !o.ConditionalOnly || hasCondition
+}
+
+// ExportFilteredCSV writes the grants on projectID that match opts to w as
+// CSV with the columns project, role, member and condition, where condition
+// is the condition's title.
+func ExportFilteredCSV(ctx context.Context, svc *PolicyManager, projectID string, opts FilterOptions, w io.Writer) error {
+	policy, err := svc.GetPolicy(ctx, projectID)
+	if err != nil {
+		return err
+	}
+	project := resourceOrDefault(ctx, projectID)
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"project", "role", "member", "condition"}); err != nil {
+		return fmt.Errorf("csv.Write: %v", err)
+	}
+	for _, b := range policy.Bindings {
+		if !opts.matchesRole(b.Role) || !opts.matchesCondition(b.Condition != nil) {
+			continue
+		}
+		cond := ""
+		if b.Condition != nil {
+			cond = b.Condition.Title
+		}
+		for _, m := range b.Members {
+			if !opts.matchesMember(m) {
+				continue
+			}
+			if err := cw.Write([]string{project, b.Role, m, cond}); err != nil {
+				return fmt.Errorf("csv.Write: %v", err)
+			}
+		}
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return fmt.Errorf("csv.Flush: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/api/cloudresourcemanager/v1"
+)
+
+func TestExportPolicyClean(t *testing.T) {
+	messy := &Policy{
+		Etag:    "BwWKmjvelug=",
+		Version: 1,
+		Bindings: []*Binding{
+			{Role: "roles/viewer", Members: []string{"user:bob@example.com", "user:alice@example.com", "user:bob@example.com"}},
+			{Role: "roles/editor", Members: []string{}},
+			{Role: "roles/viewer", Members: []string{"user:carol@example.com"}},
+		},
+	}
+
+	var raw bytes.Buffer
+	if err := ExportPolicy(&raw, messy, ExportOptions{}); err != nil {
+		t.Fatalf("ExportPolicy: %v", err)
+	}
+	wantRaw := `{
+  "bindings": [
+    {
+      "members": [
+        "user:bob@example.com",
+        "user:alice@example.com",
+        "user:bob@example.com"
+      ],
+      "role": "roles/viewer"
+    },
+    {
+      "role": "roles/editor"
+    },
+    {
+      "members": [
+        "user:carol@example.com"
+      ],
+      "role": "roles/viewer"
+    }
+  ],
+  "etag": "BwWKmjvelug=",
+  "version": 1
+}
+`
+	if got := raw.String(); got != wantRaw {
+		t.Errorf("raw ExportPolicy: got\n%s\nwant\n%s", got, wantRaw)
+	}
+
+	var clean bytes.Buffer
+	if err := ExportPolicy(&clean, messy, ExportOptions{Clean: true}); err != nil {
+		t.Fatalf("ExportPolicy: %v", err)
+	}
+	wantClean := `{
+  "bindings": [
+    {
+      "members": [
+        "user:alice@example.com",
+        "user:bob@example.com",
+        "user:carol@example.com"
+      ],
+      "role": "roles/viewer"
+    }
+  ],
+  "etag": "BwWKmjvelug=",
+  "version": 1
+}
+`
+	if got := clean.String(); got != wantClean {
+		t.Errorf("clean ExportPolicy: got\n%s\nwant\n%s", got, wantClean)
+	}
+
+	if len(messy.Bindings) != 3 {
+		t.Errorf("clean ExportPolicy modified its input")
+	}
+}
+
+func TestExportFilteredCSV(t *testing.T) {
+	ctx := context.Background()
+	temporary := &cloudresourcemanager.Expr{
+		Title:      "temporary-access",
+		Expression: `request.time < timestamp("2020-06-01T00:00:00Z")`,
+	}
+	target := newFakeTarget()
+	target.put("my-project", &Policy{Bindings: []*Binding{
+		{Role: "roles/storage.admin", Members: []string{"serviceAccount:ci@my-project.iam.gserviceaccount.com", "user:alice@example.com"}},
+		{Role: "roles/storage.objectViewer", Members: []string{"serviceAccount:web@my-project.iam.gserviceaccount.com"}, Condition: temporary},
+		{Role: "roles/viewer", Members: []string{"serviceAccount:ci@my-project.iam.gserviceaccount.com"}},
+	}})
+	m := newTestManager(t, target)
+
+	for _, tc := range []struct {
+		name string
+		opts FilterOptions
+		want string
+	}{
+		{
+			name: "service accounts with storage roles",
+			opts: FilterOptions{MemberTypes: []MemberType{MemberServiceAccount}, RolePrefix: "roles/storage.*"},
+			want: "project,role,member,condition\n" +
+				"my-project,roles/storage.admin,serviceAccount:ci@my-project.iam.gserviceaccount.com,\n" +
+				"my-project,roles/storage.objectViewer,serviceAccount:web@my-project.iam.gserviceaccount.com,temporary-access\n",
+		},
+		{
+			name: "conditional storage grants",
+			opts: FilterOptions{RolePrefix: "roles/storage.", ConditionalOnly: true},
+			want: "project,role,member,condition\n" +
+				"my-project,roles/storage.objectViewer,serviceAccount:web@my-project.iam.gserviceaccount.com,temporary-access\n",
+		},
+		{
+			name: "users",
+			opts: FilterOptions{MemberTypes: []MemberType{MemberUser}},
+			want: "project,role,member,condition\n" +
+				"my-project,roles/storage.admin,user:alice@example.com,\n",
+		},
+	} {
+		var buf bytes.Buffer
+		if err := ExportFilteredCSV(ctx, m, "my-project", tc.opts, &buf); err != nil {
+			t.Fatalf("ExportFilteredCSV(%s): %v", tc.name, err)
+		}
+		if diff := cmp.Diff(tc.want, buf.String()); diff != "" {
+			t.Errorf("ExportFilteredCSV(%s): got diff (-want +got):\n%s", tc.name, diff)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/api/cloudresourcemanager/v1"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/iam/v1"
+)
+
+// fakeTarget is an in-memory PolicyTarget that enforces etags like the real
+// API does.
+type fakeTarget struct {
+	mu       sync.Mutex
+	policies map[string]*Policy
+	version  map[string]int
+	gets     int
+	sets     int
+	// setErrs are returned, in order, by the next calls to SetPolicy.
+	setErrs []error
+	// ancestry maps a project to its ancestors, nearest first.
+	ancestry map[string][]string
+	// children maps a resource to the resources directly below it.
+	children map[string][]string
+	// getErrs maps a resource to the error GetPolicy returns for it.
+	getErrs map[string]error
+	// onGet, if set, is called with the resource and the number of reads so
+	// far before each GetPolicy is served. It may modify f.policies.
+	onGet func(resource string, gets int)
+	// getOpts are the options passed to the last GetPolicyWithOptions call.
+	getOpts *cloudresourcemanager.GetPolicyOptions
+}
+
+func newFakeTarget() *fakeTarget {
+	return &fakeTarget{
+		policies: make(map[string]*Policy),
+		version:  make(map[string]int),
+	}
+}
+
+// put stores policy for resource, replacing any existing policy.
+func (f *fakeTarget) put(resource string, policy *Policy) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.version[resource]++
+	p := copyPolicy(policy)
+	p.Etag = fmt.Sprintf("etag-%d", f.version[resource])
+	f.policies[resource] = p
+}
+
+// policy returns a copy of the stored policy for resource.
+func (f *fakeTarget) policy(resource string) *Policy {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return copyPolicy(f.policies[resource])
+}
+
+func (f *fakeTarget) GetPolicy(ctx context.Context, resource string) (*Policy, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.gets++
+	if f.onGet != nil {
+		f.onGet(resource, f.gets)
+	}
+	if err := f.getErrs[resource]; err != nil {
+		return nil, err
+	}
+	p, ok := f.policies[resource]
+	if !ok {
+		return nil, &googleapi.Error{Code: http.StatusNotFound, Message: "no policy for " + resource}
+	}
+	return copyPolicy(p), nil
+}
+
+func (f *fakeTarget) GetPolicyWithOptions(ctx context.Context, resource string, opts *cloudresourcemanager.GetPolicyOptions) (*Policy, error) {
+	f.mu.Lock()
+	f.getOpts = opts
+	f.mu.Unlock()
+	return f.GetPolicy(ctx, resource)
+}
+
+func (f *fakeTarget) SetPolicy(ctx context.Context, resource string, policy *Policy) (*Policy, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sets++
+	if len(f.setErrs) > 0 {
+		err := f.setErrs[0]
+		f.setErrs = f.setErrs[1:]
+		return nil, err
+	}
+	if cur, ok := f.policies[resource]; ok && policy.Etag != cur.Etag {
+		return nil, &googleapi.Error{Code: http.StatusConflict, Message: "etag mismatch"}
+	}
+	f.version[resource]++
+	p := copyPolicy(policy)
+	p.Etag = fmt.Sprintf("etag-%d", f.version[resource])
+	f.policies[resource] = p
+	return copyPolicy(p), nil
+}
+
+func (f *fakeTarget) Ancestry(ctx context.Context, projectID string) ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string{projectID}, f.ancestry[projectID]...), nil
+}
+
+func (f *fakeTarget) Children(ctx context.Context, resource string) ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.children[resource], nil
+}
+
+// fakeRoles is an in-memory RoleService.
+type fakeRoles struct {
+	mu    sync.Mutex
+	roles map[string]*iam.Role
+	calls int
+}
+
+// newFakeRoles returns a RoleService that knows the given roles, each
+// granting the listed permissions.
+func newFakeRoles(perms map[string][]string) *fakeRoles {
+	f := &fakeRoles{roles: make(map[string]*iam.Role)}
+	for name, p := range perms {
+		f.roles[name] = &iam.Role{Name: name, IncludedPermissions: p}
+	}
+	return f
+}
+
+func (f *fakeRoles) GetRole(ctx context.Context, name string) (*iam.Role, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	r, ok := f.roles[name]
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", name, ErrRoleNotFound)
+	}
+	return r, nil
+}
+
+// conflictErr is the error the API returns for a concurrent modification.
+var conflictErr = &googleapi.Error{Code: http.StatusConflict, Message: "concurrent policy changes"}
+
+// newTestManager returns a manager over target that retries without waiting.
+func newTestManager(t *testing.T, target PolicyTarget, opts ...Option) *PolicyManager {
+	t.Helper()
+	m, err := NewPolicyManager(target, opts...)
+	if err != nil {
+		t.Fatalf("NewPolicyManager: %v", err)
+	}
+	m.backoff = func(int) time.Duration { return 0 }
+	m.now = func() time.Time { return time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC) }
+	return m
+}
@@ -0,0 +1,98 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+
+	"google.golang.org/api/googleapi"
+)
+
+// fileTarget is a PolicyTarget that keeps a single policy in a local JSON
+// file. The resource name passed to its methods is ignored.
+type fileTarget struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewFileTarget returns a PolicyTarget that reads and writes the JSON policy
+// file at path, so every helper can be used offline. A missing file reads as
+// an empty policy. Like the API, SetPolicy rejects a policy whose etag
+// doesn't match the file's current contents.
+func NewFileTarget(path string) PolicyTarget {
+	return &fileTarget{path: path}
+}
+
+func (t *fileTarget) GetPolicy(ctx context.Context, resource string) (*Policy, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.read()
+}
+
+func (t *fileTarget) SetPolicy(ctx context.Context, resource string, policy *Policy) (*Policy, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	current, err := t.read()
+	if err != nil {
+		return nil, err
+	}
+	if policy.Etag != current.Etag {
+		return nil, &googleapi.Error{Code: http.StatusConflict, Message: "policy file changed since it was read"}
+	}
+
+	out := copyPolicy(policy)
+	out.Etag = ""
+	data, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("json.MarshalIndent: %v", err)
+	}
+	data = append(data, '\n')
+	if err := writeFileAtomic(t.path, data); err != nil {
+		return nil, err
+	}
+	out.Etag = contentEtag(data)
+	return out, nil
+}
+
+// read loads the policy file, deriving its etag from the file contents.
+func (t *fileTarget) read() (*Policy, error) {
+	data, err := ioutil.ReadFile(t.path)
+	if os.IsNotExist(err) {
+		return &Policy{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ioutil.ReadFile: %v", err)
+	}
+	policy := &Policy{}
+	if err := json.Unmarshal(data, policy); err != nil {
+		return nil, fmt.Errorf("invalid policy file %s: %v", t.path, err)
+	}
+	policy.Etag = contentEtag(data)
+	return policy, nil
+}
+
+// contentEtag returns an etag that changes whenever data does.
+func contentEtag(data []byte) string {
+	sum := sha256.Sum256(data)
+	return base64.StdEncoding.EncodeToString(sum[:8])
+}
@@ -0,0 +1,72 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFileTargetRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	path := writeTempFile(t, "policy.json", `{
+  "bindings": [
+    {"role": "roles/viewer", "members": ["user:bob@example.com"]}
+  ]
+}`)
+	m := newTestManager(t, NewFileTarget(path))
+
+	if _, err := m.AddBinding(ctx, "", "user:alice@example.com", "roles/viewer"); err != nil {
+		t.Fatalf("AddBinding: %v", err)
+	}
+
+	// A fresh manager sees the change written to the file.
+	fresh := newTestManager(t, NewFileTarget(path))
+	members, err := fresh.Members(ctx, "", "roles/viewer")
+	if err != nil {
+		t.Fatalf("Members: %v", err)
+	}
+	if diff := cmp.Diff([]string{"user:alice@example.com", "user:bob@example.com"}, members); diff != "" {
+		t.Errorf("Members: got diff (-want +got):\n%s", diff)
+	}
+	if err := ValidatePolicyFile(path); err != nil {
+		t.Errorf("ValidatePolicyFile after write: %v", err)
+	}
+}
+
+func TestFileTargetConflict(t *testing.T) {
+	ctx := context.Background()
+	target := NewFileTarget(writeTempFile(t, "policy.json", `{}`))
+	stale, err := target.GetPolicy(ctx, "")
+	if err != nil {
+		t.Fatalf("GetPolicy: %v", err)
+	}
+	written, err := target.SetPolicy(ctx, "", &Policy{Etag: stale.Etag, Version: 1})
+	if err != nil {
+		t.Fatalf("SetPolicy: %v", err)
+	}
+	got, err := target.GetPolicy(ctx, "")
+	if err != nil {
+		t.Fatalf("GetPolicy: %v", err)
+	}
+	if got.Etag != written.Etag {
+		t.Errorf("GetPolicy after SetPolicy: got etag %q, want %q", got.Etag, written.Etag)
+	}
+	if _, err := target.SetPolicy(ctx, "", stale); err == nil {
+		t.Errorf("SetPolicy with stale etag: got no error, want conflict")
+	}
+}
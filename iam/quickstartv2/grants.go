@@ -0,0 +1,134 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+// CopyRoleMembers grants toRole to every member of the unconditional
+// fromRole binding on projectID, in a single write. Members that already
+// have toRole are skipped. It returns the changes made.
+func CopyRoleMembers(ctx context.Context, svc *PolicyManager, projectID, fromRole, toRole string) (ChangeSet, error) {
+	if err := ValidateRole(toRole); err != nil {
+		return ChangeSet{}, err
+	}
+	_, cs, err := svc.modifyPolicy(ctx, projectID, func(policy *Policy) error {
+		from := GetBinding(policy, fromRole)
+		if from == nil {
+			return nil
+		}
+		for _, member := range append([]string(nil), from.Members...) {
+			addMember(policy, member, toRole, nil)
+		}
+		return nil
+	})
+	return cs, err
+}
+
+// AddRoles grants every role in roles to member on projectID, in a single
+// write. Roles the member already has are skipped. It returns the changes
+// made.
+func (m *PolicyManager) AddRoles(ctx context.Context, projectID, member string, roles []string) (ChangeSet, error) {
+	_, cs, err := m.addRoles(ctx, projectID, member, roles)
+	return cs, err
+}
+
+// addRoles is AddRoles, also returning the policy the changes were made to:
+// the one read by the attempt that wrote, after any conflict retries.
+func (m *PolicyManager) addRoles(ctx context.Context, projectID, member string, roles []string) (*Policy, ChangeSet, error) {
+	member, err := m.member(member)
+	if err != nil {
+		return nil, ChangeSet{}, err
+	}
+	for _, role := range roles {
+		if err := ValidateRole(role); err != nil {
+			return nil, ChangeSet{}, err
+		}
+	}
+	var base *Policy
+	_, cs, err := m.modifyPolicy(ctx, projectID, func(policy *Policy) error {
+		base = copyPolicy(policy)
+		for _, role := range roles {
+			addMember(policy, member, role, nil)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, ChangeSet{}, err
+	}
+	return base, cs, nil
+}
+
+// DiffRoleMembers compares the members of the unconditional role binding on
+// projectID with desired and returns, sorted, the members to add and to
+// remove to make them equal. Nothing is written.
+func DiffRoleMembers(ctx context.Context, svc *PolicyManager, projectID, role string, desired []string) (toAdd, toRemove []string, err error) {
+	policy, err := svc.GetPolicy(ctx, projectID)
+	if err != nil {
+		return nil, nil, err
+	}
+	current := make(map[string]bool)
+	if b := GetBinding(policy, role); b != nil {
+		for _, m := range b.Members {
+			current[m] = true
+		}
+	}
+	want := make(map[string]bool)
+	for _, m := range desired {
+		if !want[m] && !current[m] {
+			toAdd = append(toAdd, m)
+		}
+		want[m] = true
+	}
+	for m := range current {
+		if !want[m] {
+			toRemove = append(toRemove, m)
+		}
+	}
+	sort.Strings(toAdd)
+	sort.Strings(toRemove)
+	return toAdd, toRemove, nil
+}
+
+// ReadRoleFile reads a list of roles, one per line, from path. Blank lines
+// and lines starting with "#" are skipped. Every role is validated and all
+// invalid roles are reported together in a *ValidationError.
+func ReadRoleFile(path string) ([]string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("ioutil.ReadFile: %v", err)
+	}
+	var roles, problems []string
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if err := ValidateRole(line); err != nil {
+			problems = append(problems, fmt.Sprintf("%s:%d: %v", path, i+1, err))
+			continue
+		}
+		roles = append(roles, line)
+	}
+	if len(problems) > 0 {
+		return nil, &ValidationError{Problems: problems}
+	}
+	return roles, nil
+}
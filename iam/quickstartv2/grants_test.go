@@ -0,0 +1,118 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCopyRoleMembers(t *testing.T) {
+	ctx := context.Background()
+	target := newFakeTarget()
+	target.put("my-project", &Policy{Bindings: []*Binding{
+		{Role: "roles/logging.viewer", Members: []string{"user:alice@example.com", "user:bob@example.com", "group:sre@example.com"}},
+		{Role: "roles/monitoring.viewer", Members: []string{"user:bob@example.com"}},
+	}})
+	m := newTestManager(t, target)
+
+	cs, err := CopyRoleMembers(ctx, m, "my-project", "roles/logging.viewer", "roles/monitoring.viewer")
+	if err != nil {
+		t.Fatalf("CopyRoleMembers: %v", err)
+	}
+	want := []Change{
+		{Op: OpAdd, Role: "roles/monitoring.viewer", Member: "group:sre@example.com"},
+		{Op: OpAdd, Role: "roles/monitoring.viewer", Member: "user:alice@example.com"},
+	}
+	if diff := cmp.Diff(want, cs.Changes); diff != "" {
+		t.Errorf("CopyRoleMembers changes: got diff (-want +got):\n%s", diff)
+	}
+	if target.sets != 1 {
+		t.Errorf("CopyRoleMembers: got %d SetPolicy calls, want 1", target.sets)
+	}
+	wantMembers := []string{"group:sre@example.com", "user:alice@example.com", "user:bob@example.com"}
+	if diff := cmp.Diff(wantMembers, policyMembers(target.policy("my-project"), "roles/monitoring.viewer")); diff != "" {
+		t.Errorf("roles/monitoring.viewer members: got diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestAddRolesFromRoleFile(t *testing.T) {
+	ctx := context.Background()
+	path := writeTempFile(t, "developer-standard.txt", `# Standard developer access.
+roles/logging.viewer
+
+roles/monitoring.viewer
+  roles/cloudbuild.builds.editor
+`)
+	roles, err := ReadRoleFile(path)
+	if err != nil {
+		t.Fatalf("ReadRoleFile: %v", err)
+	}
+
+	target := newFakeTarget()
+	target.put("my-project", &Policy{Bindings: []*Binding{
+		{Role: "roles/logging.viewer", Members: []string{"user:alice@example.com"}},
+	}})
+	m := newTestManager(t, target)
+	cs, err := m.AddRoles(ctx, "my-project", "user:alice@example.com", roles)
+	if err != nil {
+		t.Fatalf("AddRoles: %v", err)
+	}
+
+	want := []Change{
+		{Op: OpAdd, Role: "roles/cloudbuild.builds.editor", Member: "user:alice@example.com"},
+		{Op: OpAdd, Role: "roles/monitoring.viewer", Member: "user:alice@example.com"},
+	}
+	if diff := cmp.Diff(want, cs.Changes); diff != "" {
+		t.Errorf("AddRoles changes: got diff (-want +got):\n%s", diff)
+	}
+	if target.sets != 1 {
+		t.Errorf("AddRoles: got %d SetPolicy calls, want 1", target.sets)
+	}
+}
+
+func TestReadRoleFileInvalid(t *testing.T) {
+	path := writeTempFile(t, "roles.txt", "roles/viewer\nlogging.viewer\n")
+	if _, err := ReadRoleFile(path); err == nil || !strings.Contains(err.Error(), "roles.txt:2") {
+		t.Errorf("ReadRoleFile: got %v, want error for line 2", err)
+	}
+}
+
+func TestDiffRoleMembers(t *testing.T) {
+	ctx := context.Background()
+	target := newFakeTarget()
+	target.put("my-project", &Policy{Bindings: []*Binding{
+		{Role: "roles/viewer", Members: []string{"user:carol@example.com", "user:alice@example.com", "user:bob@example.com"}},
+	}})
+	m := newTestManager(t, target)
+
+	desired := []string{"user:dan@example.com", "user:alice@example.com", "group:eng@example.com", "user:dan@example.com"}
+	toAdd, toRemove, err := DiffRoleMembers(ctx, m, "my-project", "roles/viewer", desired)
+	if err != nil {
+		t.Fatalf("DiffRoleMembers: %v", err)
+	}
+	if diff := cmp.Diff([]string{"group:eng@example.com", "user:dan@example.com"}, toAdd); diff != "" {
+		t.Errorf("DiffRoleMembers toAdd: got diff (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"user:bob@example.com", "user:carol@example.com"}, toRemove); diff != "" {
+		t.Errorf("DiffRoleMembers toRemove: got diff (-want +got):\n%s", diff)
+	}
+	if target.sets != 0 {
+		t.Errorf("DiffRoleMembers: got %d SetPolicy calls, want 0", target.sets)
+	}
+}
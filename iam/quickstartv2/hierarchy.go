@@ -0,0 +1,319 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"google.golang.org/api/cloudresourcemanager/v1"
+	crmv2 "google.golang.org/api/cloudresourcemanager/v2"
+	"google.golang.org/api/googleapi"
+)
+
+// ResourcePolicy is the IAM policy of one resource in the hierarchy.
+type ResourcePolicy struct {
+	// Resource is the resource name, such as "organizations/123",
+	// "folders/456" or "projects/my-project".
+	Resource string
+	// Depth is the resource's distance from the root of the hierarchy; the
+	// organization has depth 0.
+	Depth  int
+	Policy *Policy
+}
+
+// HierarchyLister is implemented by policy targets that can navigate the
+// resource hierarchy.
+type HierarchyLister interface {
+	// Ancestry returns the resource names from the project up to the root,
+	// starting with the project itself.
+	Ancestry(ctx context.Context, projectID string) ([]string, error)
+}
+
+// DescendantLister is implemented by policy targets that can list the
+// resources directly below a resource in the hierarchy.
+type DescendantLister interface {
+	// Children returns the folders and projects whose parent is resource,
+	// named "folders/ID" and "projects/ID".
+	Children(ctx context.Context, resource string) ([]string, error)
+}
+
+// resourceTarget is a PolicyTarget for projects, folders and organizations.
+// Resources are named "folders/ID" and "organizations/ID"; any other name is
+// a project ID, with or without a "projects/" prefix.
+type resourceTarget struct {
+	v1 *cloudresourcemanager.Service
+	v2 *crmv2.Service
+}
+
+// NewResourceTarget returns a PolicyTarget and HierarchyLister that uses the
+// v1 Resource Manager API for projects and organizations and the v2 API for
+// folders.
+func NewResourceTarget(v1 *cloudresourcemanager.Service, v2 *crmv2.Service) PolicyTarget {
+	return &resourceTarget{v1: v1, v2: v2}
+}
+
+func (t *resourceTarget) GetPolicy(ctx context.Context, resource string) (*Policy, error) {
+	return t.GetPolicyWithOptions(ctx, resource, defaultGetOptions)
+}
+
+func (t *resourceTarget) GetPolicyWithOptions(ctx context.Context, resource string, opts *cloudresourcemanager.GetPolicyOptions) (*Policy, error) {
+	switch {
+	case strings.HasPrefix(resource, "folders/"):
+		request := &crmv2.GetIamPolicyRequest{}
+		if opts != nil {
+			request.Options = &crmv2.GetPolicyOptions{RequestedPolicyVersion: opts.RequestedPolicyVersion}
+		}
+		p, err := t.v2.Folders.GetIamPolicy(resource, request).Context(ctx).Do()
+		if err != nil {
+			return nil, fmt.Errorf("Folders.GetIamPolicy: %w", err)
+		}
+		return fromV2Policy(p)
+	case strings.HasPrefix(resource, "organizations/"):
+		request := &cloudresourcemanager.GetIamPolicyRequest{Options: opts}
+		p, err := t.v1.Organizations.GetIamPolicy(resource, request).Context(ctx).Do()
+		if err != nil {
+			return nil, fmt.Errorf("Organizations.GetIamPolicy: %w", err)
+		}
+		return p, nil
+	}
+	return (&projectsTarget{svc: t.v1}).GetPolicyWithOptions(ctx, strings.TrimPrefix(resource, "projects/"), opts)
+}
+
+func (t *resourceTarget) SetPolicy(ctx context.Context, resource string, policy *Policy) (*Policy, error) {
+	switch {
+	case strings.HasPrefix(resource, "folders/"):
+		p2, err := toV2Policy(policy)
+		if err != nil {
+			return nil, err
+		}
+		request := &crmv2.SetIamPolicyRequest{Policy: p2}
+		p, err := t.v2.Folders.SetIamPolicy(resource, request).Context(ctx).Do()
+		if err != nil {
+			return nil, fmt.Errorf("Folders.SetIamPolicy: %w", err)
+		}
+		return fromV2Policy(p)
+	case strings.HasPrefix(resource, "organizations/"):
+		request := &cloudresourcemanager.SetIamPolicyRequest{Policy: policy}
+		p, err := t.v1.Organizations.SetIamPolicy(resource, request).Context(ctx).Do()
+		if err != nil {
+			return nil, fmt.Errorf("Organizations.SetIamPolicy: %w", err)
+		}
+		return p, nil
+	}
+	return (&projectsTarget{svc: t.v1}).SetPolicy(ctx, strings.TrimPrefix(resource, "projects/"), policy)
+}
+
+func (t *resourceTarget) Ancestry(ctx context.Context, projectID string) ([]string, error) {
+	resp, err := t.v1.Projects.GetAncestry(projectID, &cloudresourcemanager.GetAncestryRequest{}).Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("Projects.GetAncestry: %w", err)
+	}
+	var names []string
+	for _, a := range resp.Ancestor {
+		names = append(names, a.ResourceId.Type+"s/"+a.ResourceId.Id)
+	}
+	return names, nil
+}
+
+func (t *resourceTarget) Children(ctx context.Context, resource string) ([]string, error) {
+	parts := strings.Split(resource, "/")
+	if len(parts) != 2 || (parts[0] != "organizations" && parts[0] != "folders") {
+		return nil, nil
+	}
+
+	var names []string
+	err := t.v2.Folders.List().Parent(resource).Pages(ctx, func(resp *crmv2.ListFoldersResponse) error {
+		for _, f := range resp.Folders {
+			names = append(names, f.Name)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Folders.List: %w", err)
+	}
+
+	filter := fmt.Sprintf("parent.type:%s parent.id:%s", strings.TrimSuffix(parts[0], "s"), parts[1])
+	err = t.v1.Projects.List().Filter(filter).Pages(ctx, func(resp *cloudresourcemanager.ListProjectsResponse) error {
+		for _, p := range resp.Projects {
+			names = append(names, "projects/"+p.ProjectId)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Projects.List: %w", err)
+	}
+	return names, nil
+}
+
+// fromV2Policy converts a v2 API policy to the v1 type. The two have the
+// same JSON representation.
+func fromV2Policy(p *crmv2.Policy) (*Policy, error) {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return nil, fmt.Errorf("json.Marshal: %v", err)
+	}
+	out := &Policy{}
+	if err := json.Unmarshal(data, out); err != nil {
+		return nil, fmt.Errorf("json.Unmarshal: %v", err)
+	}
+	return out, nil
+}
+
+// toV2Policy converts a v1 API policy to the v2 type.
+func toV2Policy(p *Policy) (*crmv2.Policy, error) {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return nil, fmt.Errorf("json.Marshal: %v", err)
+	}
+	out := &crmv2.Policy{}
+	if err := json.Unmarshal(data, out); err != nil {
+		return nil, fmt.Errorf("json.Unmarshal: %v", err)
+	}
+	return out, nil
+}
+
+// hierarchy returns the manager's target as a HierarchyLister.
+func (m *PolicyManager) hierarchy() (HierarchyLister, error) {
+	h, ok := m.target.(HierarchyLister)
+	if !ok {
+		return nil, errors.New("policy target cannot navigate the resource hierarchy")
+	}
+	return h, nil
+}
+
+// descendants returns the manager's target as a DescendantLister.
+func (m *PolicyManager) descendants() (DescendantLister, error) {
+	d, ok := m.target.(DescendantLister)
+	if !ok {
+		return nil, errors.New("policy target cannot list descendant resources")
+	}
+	return d, nil
+}
+
+// FetchAncestry returns the policies of projectID and all of its ancestors,
+// ordered from the organization down to the project.
+func FetchAncestry(ctx context.Context, svc *PolicyManager, projectID string) ([]ResourcePolicy, error) {
+	h, err := svc.hierarchy()
+	if err != nil {
+		return nil, err
+	}
+	names, err := h.Ancestry(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	chain := make([]ResourcePolicy, len(names))
+	for i, name := range names {
+		policy, err := svc.GetPolicy(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		depth := len(names) - 1 - i
+		chain[depth] = ResourcePolicy{Resource: name, Depth: depth, Policy: policy}
+	}
+	return chain, nil
+}
+
+// PrintEffectiveAccess writes, for each resource in chain from the root
+// down, the roles member is granted there. Roles granted on the deepest
+// resource are marked direct; roles granted on its ancestors are marked
+// inherited. Resources where member has no role are marked "-".
+func PrintEffectiveAccess(w io.Writer, member string, chain []ResourcePolicy) error {
+	levels := append([]ResourcePolicy(nil), chain...)
+	sort.SliceStable(levels, func(i, j int) bool { return levels[i].Depth < levels[j].Depth })
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	if _, err := fmt.Fprintln(tw, "RESOURCE\tACCESS\tROLES"); err != nil {
+		return err
+	}
+	for i, rp := range levels {
+		access, roles := "-", "-"
+		if r := policyRolesForMember(rp.Policy, member); len(r) > 0 {
+			access = "inherited"
+			if i == len(levels)-1 {
+				access = "direct"
+			}
+			roles = strings.Join(r, ", ")
+		}
+		if _, err := fmt.Fprintf(tw, "%s\t%s\t%s\n", rp.Resource, access, roles); err != nil {
+			return err
+		}
+	}
+	return tw.Flush()
+}
+
+// PrintHierarchy writes the resources under rootResource, rootResource
+// included, as an indented tree with the number of bindings and distinct
+// members in each policy. Resources whose policy or children the caller may
+// not read are marked "access denied" and the walk continues past them.
+func PrintHierarchy(ctx context.Context, svc *PolicyManager, rootResource string, w io.Writer) error {
+	d, err := svc.descendants()
+	if err != nil {
+		return err
+	}
+	var walk func(resource string, depth int) error
+	walk = func(resource string, depth int) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		indent := strings.Repeat("  ", depth)
+		policy, err := svc.GetPolicy(ctx, resource)
+		switch {
+		case isPermissionDenied(err):
+			if _, err := fmt.Fprintf(w, "%s%s  access denied\n", indent, resource); err != nil {
+				return err
+			}
+		case err != nil:
+			return err
+		default:
+			stats := PolicyStats(policy)
+			if _, err := fmt.Fprintf(w, "%s%s  bindings=%d members=%d\n", indent, resource, stats.Bindings, stats.Members); err != nil {
+				return err
+			}
+		}
+
+		children, err := d.Children(ctx, resource)
+		if isPermissionDenied(err) {
+			_, err := fmt.Fprintf(w, "%s  (children: access denied)\n", indent)
+			return err
+		}
+		if err != nil {
+			return err
+		}
+		children = append([]string(nil), children...)
+		sort.Strings(children)
+		for _, c := range children {
+			if err := walk(c, depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return walk(rootResource, 0)
+}
+
+// isPermissionDenied reports whether err is an API permission error.
+func isPermissionDenied(err error) bool {
+	var apiErr *googleapi.Error
+	return errors.As(err, &apiErr) && apiErr.Code == http.StatusForbidden
+}
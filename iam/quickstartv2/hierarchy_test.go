@@ -0,0 +1,124 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/api/googleapi"
+)
+
+func TestPrintEffectiveAccess(t *testing.T) {
+	ctx := context.Background()
+	target := newFakeTarget()
+	target.put("organizations/1", &Policy{})
+	target.put("folders/2", &Policy{Bindings: []*Binding{
+		{Role: "roles/viewer", Members: []string{"user:alice@example.com"}},
+	}})
+	target.put("my-project", &Policy{Bindings: []*Binding{
+		{Role: "roles/editor", Members: []string{"user:bob@example.com"}},
+	}})
+	target.ancestry = map[string][]string{"my-project": {"folders/2", "organizations/1"}}
+	m := newTestManager(t, target)
+
+	chain, err := FetchAncestry(ctx, m, "my-project")
+	if err != nil {
+		t.Fatalf("FetchAncestry: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := PrintEffectiveAccess(&buf, "user:alice@example.com", chain); err != nil {
+		t.Fatalf("PrintEffectiveAccess: %v", err)
+	}
+	want := `RESOURCE         ACCESS     ROLES
+organizations/1  -          -
+folders/2        inherited  roles/viewer
+my-project       -          -
+`
+	if got := buf.String(); got != want {
+		t.Errorf("PrintEffectiveAccess: got\n%s\nwant\n%s", got, want)
+	}
+
+	buf.Reset()
+	if err := PrintEffectiveAccess(&buf, "user:bob@example.com", chain); err != nil {
+		t.Fatalf("PrintEffectiveAccess: %v", err)
+	}
+	want = `RESOURCE         ACCESS  ROLES
+organizations/1  -       -
+folders/2        -       -
+my-project       direct  roles/editor
+`
+	if got := buf.String(); got != want {
+		t.Errorf("PrintEffectiveAccess(bob): got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestPrintHierarchy(t *testing.T) {
+	ctx := context.Background()
+	target := newFakeTarget()
+	target.put("organizations/1", &Policy{Bindings: []*Binding{
+		{Role: "roles/owner", Members: []string{"group:admins@example.com"}},
+	}})
+	target.put("folders/2", &Policy{})
+	target.put("projects/a", &Policy{Bindings: []*Binding{
+		{Role: "roles/viewer", Members: []string{"user:alice@example.com", "user:bob@example.com"}},
+		{Role: "roles/editor", Members: []string{"user:alice@example.com"}},
+	}})
+	target.getErrs = map[string]error{
+		"projects/secret": &googleapi.Error{Code: http.StatusForbidden, Message: "denied"},
+	}
+	target.children = map[string][]string{
+		"organizations/1": {"projects/secret", "folders/2"},
+		"folders/2":       {"projects/a"},
+	}
+	m := newTestManager(t, target)
+
+	var buf bytes.Buffer
+	if err := PrintHierarchy(ctx, m, "organizations/1", &buf); err != nil {
+		t.Fatalf("PrintHierarchy: %v", err)
+	}
+	want := "organizations/1  bindings=1 members=1\n" +
+		"  folders/2  bindings=0 members=0\n" +
+		"    projects/a  bindings=2 members=2\n" +
+		"  projects/secret  access denied\n"
+	if diff := cmp.Diff(want, buf.String()); diff != "" {
+		t.Errorf("PrintHierarchy: got diff (-want +got):\n%s", diff)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := PrintHierarchy(cancelled, m, "organizations/1", &buf); !errors.Is(err, context.Canceled) {
+		t.Errorf("PrintHierarchy(cancelled): got %v, want context.Canceled", err)
+	}
+
+	if err := PrintHierarchy(ctx, m, "organizations/1", errWriter{errClosedPipe}); !errors.Is(err, errClosedPipe) {
+		t.Errorf("PrintHierarchy(closed pipe): got %v, want the write error", err)
+	}
+}
+
+var errClosedPipe = errors.New("closed pipe")
+
+// errWriter fails every write with err.
+type errWriter struct {
+	err error
+}
+
+func (w errWriter) Write(p []byte) (int, error) {
+	return 0, w.err
+}
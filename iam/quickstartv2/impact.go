@@ -0,0 +1,121 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ActivitySource reports when service accounts last authenticated, such as
+// from the Policy Intelligence activity API or audit logs.
+type ActivitySource interface {
+	// LastAuthenticated returns when the service account with the given
+	// email last authenticated, or the zero time if it never has.
+	LastAuthenticated(ctx context.Context, email string) (time.Time, error)
+}
+
+// WithActivitySource sets the source EstimateRemovalImpact uses to tell
+// which service accounts are in active use.
+func WithActivitySource(src ActivitySource) Option {
+	return func(m *PolicyManager) error {
+		m.activity = src
+		return nil
+	}
+}
+
+// activeWindow is how recently a service account must have authenticated
+// to be considered in active use.
+const activeWindow = 30 * 24 * time.Hour
+
+// Impact estimates the effect of removing a role from a project.
+type Impact struct {
+	Role string
+	// Members are the members, sorted, that would lose the role.
+	Members []string
+	// ServiceAccounts are the service accounts among Members.
+	ServiceAccounts []string
+	// ActivityKnown is set if the activity of every service account could
+	// be looked up, in which case ActiveServiceAccounts lists those that
+	// authenticated in the last 30 days.
+	ActivityKnown         bool
+	ActiveServiceAccounts []string
+	// EmptiesCriticalRole is set if the role is one that manages IAM, such
+	// as roles/owner, and removing it leaves no member holding it.
+	EmptiesCriticalRole bool
+}
+
+func (i Impact) String() string {
+	if len(i.Members) == 0 {
+		return fmt.Sprintf("%s: no members hold the role, no change", i.Role)
+	}
+	s := fmt.Sprintf("%s: %d member(s) lose the role, %d of them service accounts", i.Role, len(i.Members), len(i.ServiceAccounts))
+	switch {
+	case len(i.ServiceAccounts) == 0:
+	case i.ActivityKnown:
+		s += fmt.Sprintf(" (%d active in the last 30 days)", len(i.ActiveServiceAccounts))
+	default:
+		s += " (activity unknown)"
+	}
+	if i.EmptiesCriticalRole {
+		s += "; WARNING: no member will hold this IAM-managing role"
+	}
+	return s
+}
+
+// EstimateRemovalImpact computes, without writing anything, the effect
+// RemoveBinding would have on projectID. Service account activity is
+// looked up on a best-effort basis with the manager's ActivitySource, if
+// one is set; lookup failures leave ActivityKnown unset rather than failing
+// the estimate.
+func EstimateRemovalImpact(ctx context.Context, svc *PolicyManager, projectID, role string) (Impact, error) {
+	policy, err := svc.GetPolicy(ctx, projectID)
+	if err != nil {
+		return Impact{}, err
+	}
+	impact := Impact{Role: role, Members: policyMembers(policy, role)}
+	for _, m := range impact.Members {
+		if strings.HasPrefix(m, string(MemberServiceAccount)+":") {
+			impact.ServiceAccounts = append(impact.ServiceAccounts, m)
+		}
+	}
+	if len(impact.Members) > 0 {
+		admin, err := svc.isIAMAdminRole(ctx, role)
+		if err != nil {
+			return Impact{}, err
+		}
+		impact.EmptiesCriticalRole = admin
+	}
+
+	if svc.activity == nil {
+		return impact, nil
+	}
+	now := svc.now()
+	var active []string
+	for _, sa := range impact.ServiceAccounts {
+		last, err := svc.activity.LastAuthenticated(ctx, strings.TrimPrefix(sa, string(MemberServiceAccount)+":"))
+		if err != nil {
+			return impact, nil
+		}
+		if !last.IsZero() && now.Sub(last) < activeWindow {
+			active = append(active, sa)
+		}
+	}
+	impact.ActivityKnown = true
+	impact.ActiveServiceAccounts = active
+	return impact, nil
+}
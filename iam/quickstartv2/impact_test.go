@@ -0,0 +1,98 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// fakeActivity is an ActivitySource backed by a map of last
+// authentication times.
+type fakeActivity struct {
+	last map[string]time.Time
+	err  error
+}
+
+func (f *fakeActivity) LastAuthenticated(ctx context.Context, email string) (time.Time, error) {
+	return f.last[email], f.err
+}
+
+func TestEstimateRemovalImpact(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	target := newFakeTarget()
+	target.put("my-project", &Policy{Bindings: []*Binding{
+		{Role: "roles/owner", Members: []string{
+			"user:alice@example.com",
+			"serviceAccount:ci@my-project.iam.gserviceaccount.com",
+			"serviceAccount:old@my-project.iam.gserviceaccount.com",
+		}},
+		{Role: "roles/viewer", Members: []string{"user:bob@example.com"}},
+	}})
+	activity := &fakeActivity{last: map[string]time.Time{
+		"ci@my-project.iam.gserviceaccount.com":  now.Add(-time.Hour),
+		"old@my-project.iam.gserviceaccount.com": now.Add(-90 * 24 * time.Hour),
+	}}
+	m := newTestManager(t, target, WithActivitySource(activity))
+	m.now = func() time.Time { return now }
+
+	got, err := EstimateRemovalImpact(ctx, m, "my-project", "roles/owner")
+	if err != nil {
+		t.Fatalf("EstimateRemovalImpact: %v", err)
+	}
+	want := Impact{
+		Role: "roles/owner",
+		Members: []string{
+			"serviceAccount:ci@my-project.iam.gserviceaccount.com",
+			"serviceAccount:old@my-project.iam.gserviceaccount.com",
+			"user:alice@example.com",
+		},
+		ServiceAccounts: []string{
+			"serviceAccount:ci@my-project.iam.gserviceaccount.com",
+			"serviceAccount:old@my-project.iam.gserviceaccount.com",
+		},
+		ActivityKnown:         true,
+		ActiveServiceAccounts: []string{"serviceAccount:ci@my-project.iam.gserviceaccount.com"},
+		EmptiesCriticalRole:   true,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("EstimateRemovalImpact: got diff (-want +got):\n%s", diff)
+	}
+
+	activity.err = errors.New("activity API unavailable")
+	got, err = EstimateRemovalImpact(ctx, m, "my-project", "roles/viewer")
+	if err != nil {
+		t.Fatalf("EstimateRemovalImpact(viewer): %v", err)
+	}
+	if len(got.Members) != 1 || got.EmptiesCriticalRole {
+		t.Errorf("EstimateRemovalImpact(viewer): got %+v, want one member and no critical role", got)
+	}
+	if target.sets != 0 {
+		t.Errorf("EstimateRemovalImpact: got %d SetPolicy calls, want 0", target.sets)
+	}
+
+	if _, err := m.RemoveBinding(ctx, "my-project", "roles/owner"); err != nil {
+		t.Fatalf("RemoveBinding: %v", err)
+	}
+	wantBindings := []*Binding{{Role: "roles/viewer", Members: []string{"user:bob@example.com"}}}
+	if diff := cmp.Diff(wantBindings, target.policy("my-project").Bindings); diff != "" {
+		t.Errorf("RemoveBinding: got diff (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Limits on allow policies enforced by IAM. The API documents the size limit
+// only as "a few 10s of KB", so maxPolicyBytes is a conservative estimate.
+const (
+	maxPolicyMembers = 1500
+	maxPolicyBytes   = 64 * 1024
+)
+
+// PolicySizeBytes returns the size of policy serialized as JSON, which is
+// what counts towards the policy size limit.
+func PolicySizeBytes(policy *Policy) (int, error) {
+	data, err := json.Marshal(policy)
+	if err != nil {
+		return 0, fmt.Errorf("json.Marshal: %v", err)
+	}
+	return len(data), nil
+}
+
+// CheckPolicyLimits returns a *ValidationError if policy exceeds the number
+// of members or the size IAM accepts. Every occurrence of a member counts
+// towards the member limit, as it does in IAM.
+func CheckPolicyLimits(policy *Policy) error {
+	var problems []string
+	members := 0
+	for _, b := range policy.Bindings {
+		members += len(b.Members)
+	}
+	if members > maxPolicyMembers {
+		problems = append(problems, fmt.Sprintf("policy has %d members, limit is %d", members, maxPolicyMembers))
+	}
+	size, err := PolicySizeBytes(policy)
+	if err != nil {
+		return err
+	}
+	if size > maxPolicyBytes {
+		problems = append(problems, fmt.Sprintf("policy is %d bytes, limit is about %d", size, maxPolicyBytes))
+	}
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// nearLimitPercent is how full a policy may get, as a percentage of either
+// limit, before a change to it is warned about.
+const nearLimitPercent = 90
+
+// changeWarnings returns advisories about the changes in cs, which have been
+// applied to policy: primitive roles or public access granted, and a policy
+// approaching the limits IAM accepts.
+func changeWarnings(policy *Policy, cs ChangeSet) []string {
+	var warnings []string
+	for _, c := range cs.Changes {
+		if c.Op != OpAdd {
+			continue
+		}
+		if isPrimitiveRole(c.Role) {
+			warnings = append(warnings, fmt.Sprintf("%s granted primitive role %s; prefer a predefined role", c.Member, c.Role))
+		}
+		if t, _, _ := ParseMember(c.Member); t == MemberAllUsers || t == MemberAllAuthenticatedUsers {
+			warnings = append(warnings, fmt.Sprintf("%s granted to %s allows public access", c.Role, c.Member))
+		}
+	}
+	members := 0
+	for _, b := range policy.Bindings {
+		members += len(b.Members)
+	}
+	if members*100 >= maxPolicyMembers*nearLimitPercent {
+		warnings = append(warnings, fmt.Sprintf("policy has %d members, close to the limit of %d", members, maxPolicyMembers))
+	}
+	if size, err := PolicySizeBytes(policy); err == nil && size*100 >= maxPolicyBytes*nearLimitPercent {
+		warnings = append(warnings, fmt.Sprintf("policy is %d bytes, close to the limit of about %d", size, maxPolicyBytes))
+	}
+	return warnings
+}
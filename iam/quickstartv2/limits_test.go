@@ -0,0 +1,82 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestPolicySizeBytes(t *testing.T) {
+	policy := &Policy{}
+	prev, err := PolicySizeBytes(policy)
+	if err != nil {
+		t.Fatalf("PolicySizeBytes: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		policy.Bindings = append(policy.Bindings, &Binding{
+			Role:    fmt.Sprintf("roles/custom%d", i),
+			Members: []string{"user:alice@example.com"},
+		})
+		size, err := PolicySizeBytes(policy)
+		if err != nil {
+			t.Fatalf("PolicySizeBytes: %v", err)
+		}
+		if size <= prev {
+			t.Errorf("PolicySizeBytes with %d bindings: got %d, want more than %d", i+1, size, prev)
+		}
+		prev = size
+	}
+}
+
+func TestCheckPolicyLimits(t *testing.T) {
+	b := &Binding{Role: "roles/viewer"}
+	for i := 0; i < maxPolicyMembers+1; i++ {
+		b.Members = append(b.Members, fmt.Sprintf("user:user%d@example.com", i))
+	}
+	if err := CheckPolicyLimits(&Policy{Bindings: []*Binding{b}}); err == nil {
+		t.Errorf("CheckPolicyLimits: got no error for %d members, want error", len(b.Members))
+	}
+	if err := CheckPolicyLimits(&Policy{Bindings: []*Binding{{Role: "roles/viewer", Members: []string{"user:alice@example.com"}}}}); err != nil {
+		t.Errorf("CheckPolicyLimits: got %v for a small policy, want nil", err)
+	}
+}
+
+func TestChangeWarnings(t *testing.T) {
+	ctx := context.Background()
+	target := newFakeTarget()
+	target.put("my-project", &Policy{})
+	m := newTestManager(t, target)
+
+	cs, err := m.AddBinding(ctx, "my-project", "user:alice@example.com", "roles/editor")
+	if err != nil {
+		t.Fatalf("AddBinding(roles/editor): %v", err)
+	}
+	want := []string{"user:alice@example.com granted primitive role roles/editor; prefer a predefined role"}
+	if diff := cmp.Diff(want, cs.Warnings); diff != "" {
+		t.Errorf("AddBinding(roles/editor): got diff (-want +got):\n%s", diff)
+	}
+
+	cs, err = m.AddBinding(ctx, "my-project", "user:alice@example.com", "roles/logging.viewer")
+	if err != nil {
+		t.Fatalf("AddBinding(roles/logging.viewer): %v", err)
+	}
+	if len(cs.Warnings) != 0 {
+		t.Errorf("AddBinding(roles/logging.viewer): got warnings %q, want none", cs.Warnings)
+	}
+}
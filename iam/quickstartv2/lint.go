@@ -0,0 +1,127 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Warning is a non-fatal problem found in a policy or operation.
+type Warning struct {
+	// Field is the JSON path of the field concerned, such as
+	// "bindings[0].member", or empty if the warning isn't about a field.
+	Field   string
+	Message string
+}
+
+func (w Warning) String() string {
+	if w.Field == "" {
+		return w.Message
+	}
+	return w.Field + ": " + w.Message
+}
+
+// LintPolicyFile checks that the JSON policy file at path maps cleanly onto
+// the API policy type. Keys the type doesn't have, such as "binding" for
+// "bindings", and keys in the wrong case, which the API would silently
+// accept, are reported as warnings, with the keys of each object in sorted
+// order. It returns an error if the file can't be read or has values of the
+// wrong type.
+func LintPolicyFile(path string) ([]Warning, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("ioutil.ReadFile: %v", err)
+	}
+
+	// Unknown fields are found below, all of them rather than just the
+	// first; a lenient decode checks the types of the known ones.
+	if err := json.Unmarshal(data, &Policy{}); err != nil {
+		return nil, fmt.Errorf("invalid policy file %s: %v", path, err)
+	}
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid policy file %s: %v", path, err)
+	}
+	var warnings []Warning
+	lintValue(doc, reflect.TypeOf(Policy{}), "", &warnings)
+	return warnings, nil
+}
+
+// lintValue appends a warning to warnings for each key in v, at path, that
+// is not a JSON field of t.
+func lintValue(v interface{}, t reflect.Type, path string, warnings *[]Warning) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch v := v.(type) {
+	case []interface{}:
+		if t.Kind() != reflect.Slice {
+			return
+		}
+		for i, elem := range v {
+			lintValue(elem, t.Elem(), fmt.Sprintf("%s[%d]", path, i), warnings)
+		}
+	case map[string]interface{}:
+		if t.Kind() != reflect.Struct {
+			return
+		}
+		fields := jsonFields(t)
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			field := k
+			if path != "" {
+				field = path + "." + k
+			}
+			if ft, ok := fields[k]; ok {
+				lintValue(v[k], ft, field, warnings)
+				continue
+			}
+			msg := "unknown field"
+			for name := range fields {
+				if strings.EqualFold(name, k) {
+					msg = fmt.Sprintf("field should be spelled %q", name)
+				}
+			}
+			*warnings = append(*warnings, Warning{Field: field, Message: msg})
+		}
+	}
+}
+
+// jsonFields maps the JSON names of the fields of struct type t to their
+// types.
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if name == "-" || f.PkgPath != "" || f.Anonymous {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields[name] = f.Type
+	}
+	return fields
+}
@@ -0,0 +1,62 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLintPolicyFile(t *testing.T) {
+	path := writeTempFile(t, "policy.json", `{
+  "version": 1,
+  "binding": [],
+  "bindings": [
+    {"role": "roles/viewer", "member": ["user:alice@example.com"], "Condition": null}
+  ]
+}`)
+	got, err := LintPolicyFile(path)
+	if err != nil {
+		t.Fatalf("LintPolicyFile: %v", err)
+	}
+	want := []Warning{
+		{Field: "binding", Message: "unknown field"},
+		{Field: "bindings[0].Condition", Message: `field should be spelled "condition"`},
+		{Field: "bindings[0].member", Message: "unknown field"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("LintPolicyFile: got diff (-want +got):\n%s", diff)
+	}
+
+	clean := writeTempFile(t, "clean.json", `{"bindings": [{"role": "roles/viewer", "members": ["user:alice@example.com"]}]}`)
+	if got, err := LintPolicyFile(clean); err != nil || len(got) != 0 {
+		t.Errorf("LintPolicyFile(clean): got (%v, %v), want no warnings", got, err)
+	}
+
+	caseOnly := writeTempFile(t, "case.json", `{"Bindings": []}`)
+	got, err = LintPolicyFile(caseOnly)
+	if err != nil {
+		t.Fatalf("LintPolicyFile(case): %v", err)
+	}
+	if diff := cmp.Diff([]Warning{{Field: "Bindings", Message: `field should be spelled "bindings"`}}, got); diff != "" {
+		t.Errorf("LintPolicyFile(case): got diff (-want +got):\n%s", diff)
+	}
+
+	bad := writeTempFile(t, "bad.json", `{"binding": [], "version": "three"}`)
+	if _, err := LintPolicyFile(bad); err == nil {
+		t.Errorf("LintPolicyFile(wrong type): got nil error, want error")
+	}
+}
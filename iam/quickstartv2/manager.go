@@ -0,0 +1,540 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"text/template"
+	"time"
+
+	"google.golang.org/api/cloudresourcemanager/v1"
+	"google.golang.org/api/googleapi"
+)
+
+// Policy and Binding are shorthands for the Resource Manager API types.
+type (
+	Policy  = cloudresourcemanager.Policy
+	Binding = cloudresourcemanager.Binding
+)
+
+// PolicyTarget reads and writes the IAM policy of a resource.
+type PolicyTarget interface {
+	GetPolicy(ctx context.Context, resource string) (*Policy, error)
+	SetPolicy(ctx context.Context, resource string, policy *Policy) (*Policy, error)
+}
+
+// projectsTarget is a PolicyTarget backed by the Resource Manager projects API.
+type projectsTarget struct {
+	svc *cloudresourcemanager.Service
+}
+
+// NewProjectsTarget returns a PolicyTarget for projects, using crmService.
+func NewProjectsTarget(crmService *cloudresourcemanager.Service) PolicyTarget {
+	return &projectsTarget{svc: crmService}
+}
+
+// PolicyOptionsGetter is implemented by policy targets that can pass
+// caller-specified options to GetIamPolicy.
+type PolicyOptionsGetter interface {
+	GetPolicyWithOptions(ctx context.Context, resource string, opts *cloudresourcemanager.GetPolicyOptions) (*Policy, error)
+}
+
+// defaultGetOptions requests policy version 3, so that conditional bindings
+// are returned.
+var defaultGetOptions = &cloudresourcemanager.GetPolicyOptions{RequestedPolicyVersion: 3}
+
+func (t *projectsTarget) GetPolicy(ctx context.Context, projectID string) (*Policy, error) {
+	return t.GetPolicyWithOptions(ctx, projectID, defaultGetOptions)
+}
+
+func (t *projectsTarget) GetPolicyWithOptions(ctx context.Context, projectID string, opts *cloudresourcemanager.GetPolicyOptions) (*Policy, error) {
+	request := &cloudresourcemanager.GetIamPolicyRequest{Options: opts}
+	policy, err := t.svc.Projects.GetIamPolicy(projectID, request).Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("Projects.GetIamPolicy: %w", err)
+	}
+	return policy, nil
+}
+
+func (t *projectsTarget) SetPolicy(ctx context.Context, projectID string, policy *Policy) (*Policy, error) {
+	request := &cloudresourcemanager.SetIamPolicyRequest{Policy: policy}
+	policy, err := t.svc.Projects.SetIamPolicy(projectID, request).Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("Projects.SetIamPolicy: %w", err)
+	}
+	return policy, nil
+}
+
+// defaultMaxRetries is the number of times a read-modify-write is retried
+// after a retryable error before giving up.
+const defaultMaxRetries = 5
+
+// PolicyManager makes IAM policy changes through a PolicyTarget, retrying
+// concurrent-modification conflicts and transient errors.
+type PolicyManager struct {
+	target     PolicyTarget
+	maxRetries int
+	// backoff returns how long to wait before the given retry attempt.
+	backoff func(attempt int) time.Duration
+	// now returns the current time. It is replaced in tests.
+	now func() time.Time
+
+	actor  string
+	audits []auditSink
+	roles  *roleCache
+	cache  PolicyCache
+	denies DenyPolicyLister
+	// titleTmpl and descTmpl, if set, render the conditions of temporary
+	// grants.
+	titleTmpl, descTmpl *template.Template
+	// getOptions, if set, are sent with every read of a policy.
+	getOptions *cloudresourcemanager.GetPolicyOptions
+	// checkpoint, if set, is the path where ApplyToProjects records its
+	// progress.
+	checkpoint string
+	// policyVersion, if set, is the version of every policy written.
+	policyVersion int64
+	// maxMembersPerRole, if positive, bounds the members of each binding.
+	maxMembersPerRole int
+	// allowedRoles, if not empty, is the set of roles that may be granted.
+	allowedRoles map[string]bool
+	// resolver translates the members passed to grant and revoke methods.
+	resolver MemberResolver
+	// canonicalize, if set, is applied to members before they are added or
+	// removed.
+	canonicalize func(string) (string, error)
+	// breaker, if set, guards every policy read and write.
+	breaker *CircuitBreaker
+	// rolesCachePath, if set, is where LoadPredefinedRoles caches the
+	// predefined roles for rolesCacheTTL.
+	rolesCachePath string
+	rolesCacheTTL  time.Duration
+	// protected are members that may not have roles revoked.
+	protected map[string]bool
+	// activity, if set, reports service account usage for removal impact
+	// estimates.
+	activity ActivitySource
+	// trace, if set, is called with each decision made while changing a
+	// policy.
+	trace func(resource, step string)
+	// metadataPath, if set, is the grant metadata sidecar kept up to date
+	// with the changes made. metadataMu serializes its updates.
+	metadataPath string
+	metadataMu   sync.Mutex
+
+	mu    sync.Mutex
+	stats ManagerStats
+}
+
+// ManagerStats counts the API calls made by a PolicyManager.
+type ManagerStats struct {
+	Reads   int
+	Writes  int
+	Retries int
+	// RetriesByReason breaks Retries down by the kind of error retried:
+	// RetryConflict, RetryRateLimited or RetryServerError.
+	RetriesByReason map[string]int
+}
+
+// Stats returns a snapshot of the manager's counters.
+func (m *PolicyManager) Stats() ManagerStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := m.stats
+	s.RetriesByReason = make(map[string]int)
+	for k, v := range m.stats.RetriesByReason {
+		s.RetriesByReason[k] = v
+	}
+	return s
+}
+
+// count applies f to the manager's counters.
+func (m *PolicyManager) count(f func(s *ManagerStats)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.stats.RetriesByReason == nil {
+		m.stats.RetriesByReason = make(map[string]int)
+	}
+	f(&m.stats)
+}
+
+// Option configures a PolicyManager.
+type Option func(*PolicyManager) error
+
+// WithMaxRetries sets how many times a conflicting or failed write is retried.
+func WithMaxRetries(n int) Option {
+	return func(m *PolicyManager) error {
+		if n < 0 {
+			return fmt.Errorf("max retries must not be negative, got %d", n)
+		}
+		m.maxRetries = n
+		return nil
+	}
+}
+
+// WithActor sets the identity recorded as the author of changes.
+func WithActor(actor string) Option {
+	return func(m *PolicyManager) error {
+		m.actor = actor
+		return nil
+	}
+}
+
+// WithGetOptions sends opts with every GetIamPolicy request instead of the
+// default, which requests policy version 3. The manager's target must
+// implement PolicyOptionsGetter.
+func WithGetOptions(opts *cloudresourcemanager.GetPolicyOptions) Option {
+	return func(m *PolicyManager) error {
+		if _, ok := m.target.(PolicyOptionsGetter); !ok {
+			return errors.New("policy target does not accept GetIamPolicy options")
+		}
+		m.getOptions = opts
+		return nil
+	}
+}
+
+// WithPolicyVersion sets the version of every policy written to v, 1 or 3,
+// instead of choosing version 3 only for policies with conditions.
+func WithPolicyVersion(v int) Option {
+	return func(m *PolicyManager) error {
+		if v != 1 && v != 3 {
+			return fmt.Errorf("policy version must be 1 or 3, got %d", v)
+		}
+		m.policyVersion = int64(v)
+		return nil
+	}
+}
+
+// ErrTooManyMembers is returned when a change would grant a role to more
+// members than the limit set with WithMaxMembersPerRole.
+var ErrTooManyMembers = errors.New("too many members for role")
+
+// WithMaxMembersPerRole limits each binding to n members. Changes that would
+// add members to a binding beyond the limit fail with ErrTooManyMembers;
+// grant the role to a group instead. Bindings already over the limit may
+// still lose members. Zero, the default, means no limit.
+func WithMaxMembersPerRole(n int) Option {
+	return func(m *PolicyManager) error {
+		if n < 0 {
+			return fmt.Errorf("max members per role must not be negative, got %d", n)
+		}
+		m.maxMembersPerRole = n
+		return nil
+	}
+}
+
+// checkMemberLimit returns an error wrapping ErrTooManyMembers if cs adds
+// members to a binding of policy that is over the manager's limit.
+func (m *PolicyManager) checkMemberLimit(policy *Policy, cs ChangeSet) error {
+	if m.maxMembersPerRole <= 0 {
+		return nil
+	}
+	for _, c := range cs.Changes {
+		if c.Op != OpAdd {
+			continue
+		}
+		b := GetConditionalBinding(policy, c.Role, c.Condition)
+		if b != nil && len(b.Members) > m.maxMembersPerRole {
+			return fmt.Errorf("%s would have %d members, limit is %d; grant the role to a group instead: %w",
+				c.Role, len(b.Members), m.maxMembersPerRole, ErrTooManyMembers)
+		}
+	}
+	return nil
+}
+
+// WithCanonicalize canonicalizes members with CanonicalizeMember before they
+// are added or removed. If lowerLocal is set, the local part of user and
+// group emails is lowercased too, for organizations whose addresses are
+// case-insensitive.
+func WithCanonicalize(lowerLocal bool) Option {
+	return func(m *PolicyManager) error {
+		m.canonicalize = func(member string) (string, error) {
+			return canonicalizeMember(member, lowerLocal)
+		}
+		return nil
+	}
+}
+
+// NewPolicyManager returns a PolicyManager that operates on target.
+func NewPolicyManager(target PolicyTarget, opts ...Option) (*PolicyManager, error) {
+	m := &PolicyManager{
+		target:     target,
+		resolver:   identityResolver{},
+		maxRetries: defaultMaxRetries,
+		backoff:    exponentialBackoff,
+		now:        time.Now,
+	}
+	for _, opt := range opts {
+		if err := opt(m); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// Close flushes and releases any resources held by the manager.
+func (m *PolicyManager) Close() error {
+	var firstErr error
+	for _, a := range m.audits {
+		if err := a.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// exponentialBackoff doubles the wait for each attempt, starting at 100ms.
+func exponentialBackoff(attempt int) time.Duration {
+	return (100 * time.Millisecond) << uint(attempt)
+}
+
+// GetPolicy gets the IAM policy of projectID, or of the resource set with
+// WithResource if projectID is empty. With WithCache, the policy may be
+// served from the cache.
+func (m *PolicyManager) GetPolicy(ctx context.Context, projectID string) (*Policy, error) {
+	projectID = resourceOrDefault(ctx, projectID)
+	if m.cache == nil {
+		return m.readPolicy(ctx, projectID)
+	}
+	cached, gen := m.cache.Get(projectID)
+	if cached != nil {
+		return cached, nil
+	}
+	policy, err := m.readPolicy(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	m.cache.Put(projectID, gen, policy)
+	return policy, nil
+}
+
+// readPolicy gets the IAM policy of projectID from the target, bypassing
+// the cache.
+func (m *PolicyManager) readPolicy(ctx context.Context, projectID string) (*Policy, error) {
+	m.count(func(s *ManagerStats) { s.Reads++ })
+	var policy *Policy
+	err := m.guard(projectID, func() error {
+		var err error
+		if m.getOptions != nil {
+			policy, err = m.target.(PolicyOptionsGetter).GetPolicyWithOptions(ctx, projectID, m.getOptions)
+		} else {
+			policy, err = m.target.GetPolicy(ctx, projectID)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// setPolicy sets the IAM policy of projectID and invalidates any cached copy.
+func (m *PolicyManager) setPolicy(ctx context.Context, projectID string, policy *Policy) (*Policy, error) {
+	m.count(func(s *ManagerStats) { s.Writes++ })
+	if m.cache != nil {
+		defer m.cache.Invalidate(projectID)
+	}
+	var written *Policy
+	err := m.guard(projectID, func() error {
+		var err error
+		written, err = m.target.SetPolicy(ctx, projectID, policy)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return written, nil
+}
+
+// Reasons a call is retried, as counted in ManagerStats.RetriesByReason.
+const (
+	RetryConflict    = "conflict"
+	RetryRateLimited = "rate_limited"
+	RetryServerError = "server_error"
+)
+
+// retryReason classifies err, reporting whether the failed call should be
+// retried and why.
+func retryReason(err error) (string, bool) {
+	var apiErr *googleapi.Error
+	if !errors.As(err, &apiErr) {
+		return "", false
+	}
+	switch {
+	case apiErr.Code == http.StatusConflict:
+		return RetryConflict, true
+	case apiErr.Code == http.StatusTooManyRequests:
+		return RetryRateLimited, true
+	case apiErr.Code >= 500:
+		return RetryServerError, true
+	}
+	return "", false
+}
+
+// modifyPolicy runs a read-modify-write cycle on the policy of projectID.
+// mutate edits the fetched policy in place. When the write fails with a
+// conflict or transient error the cycle is retried with a freshly fetched
+// policy, so mutate may be called more than once. If mutate leaves the
+// bindings, audit configs and version unchanged nothing is written. It
+// returns the resulting policy and the changes made.
+func (m *PolicyManager) modifyPolicy(ctx context.Context, projectID string, mutate func(*Policy) error) (*Policy, ChangeSet, error) {
+	projectID = resourceOrDefault(ctx, projectID)
+	for attempt := 0; ; attempt++ {
+		policy, err := m.readPolicy(ctx, projectID)
+		if err != nil {
+			return nil, ChangeSet{}, err
+		}
+		m.tracef(projectID, "read policy (etag %s)", policy.Etag)
+		before := copyPolicy(policy)
+		if err := mutate(policy); err != nil {
+			return nil, ChangeSet{}, err
+		}
+		cs := ChangeSet{
+			Project: projectID,
+			Changes: diffPolicies(before, policy),
+			Reason:  reasonFromContext(ctx),
+		}
+		if policyUnchanged(before, policy, cs.Changes) {
+			m.tracef(projectID, "no changes, policy not written")
+			return before, cs, nil
+		}
+		if err := m.checkMemberLimit(policy, cs); err != nil {
+			return nil, ChangeSet{}, err
+		}
+		if err := m.checkProtected(ctx, cs); err != nil {
+			return nil, ChangeSet{}, err
+		}
+		if err := m.checkGrantable(cs); err != nil {
+			return nil, ChangeSet{}, err
+		}
+		switch {
+		case m.policyVersion != 0:
+			policy.Version = m.policyVersion
+		case hasConditions(policy):
+			// Conditional bindings require policy version 3.
+			policy.Version = 3
+		}
+
+		written, err := m.setPolicy(ctx, projectID, policy)
+		if err == nil {
+			for _, c := range cs.Changes {
+				desc := bindingDescription(c.Role, c.Condition)
+				if c.Op == OpRemove {
+					m.tracef(projectID, "removed member %s from %s", c.Member, desc)
+				} else {
+					m.tracef(projectID, "added member %s to %s", c.Member, desc)
+				}
+			}
+			m.tracef(projectID, "wrote policy (etag %s→%s)", policy.Etag, written.Etag)
+			cs.Warnings = changeWarnings(policy, cs)
+			if err := m.updateGrantMetadata(cs, policy); err != nil {
+				cs.Warnings = append(cs.Warnings, fmt.Sprintf("grant metadata not updated: %v", err))
+			}
+			m.audit(ctx, cs, written.Etag)
+			return written, cs, nil
+		}
+		reason, ok := retryReason(err)
+		if !ok || attempt >= m.maxRetries {
+			return nil, ChangeSet{}, err
+		}
+		m.tracef(projectID, "write failed (%s), retrying", reason)
+		m.count(func(s *ManagerStats) {
+			s.Retries++
+			s.RetriesByReason[reason]++
+		})
+		select {
+		case <-ctx.Done():
+			return nil, ChangeSet{}, ctx.Err()
+		case <-time.After(m.backoff(attempt)):
+		}
+	}
+}
+
+// ModifyPolicy runs a custom edit of the policy of projectID with the same
+// conflict retries, change detection and auditing as the built-in helpers.
+// mutate edits the fetched policy in place; returning an error aborts the
+// edit without writing.
+//
+// mutate is called again with a freshly fetched policy each time the write
+// is retried, so it must be idempotent and must not depend on state left
+// behind by an earlier call.
+func (m *PolicyManager) ModifyPolicy(ctx context.Context, projectID string, mutate func(*Policy) error) error {
+	_, _, err := m.modifyPolicy(ctx, projectID, mutate)
+	return err
+}
+
+// member returns member as it should be written to a policy.
+func (m *PolicyManager) member(member string) (string, error) {
+	resolved, err := m.resolver.Resolve(member)
+	if err != nil {
+		return "", fmt.Errorf("resolving member %q: %w", member, err)
+	}
+	member = resolved
+	if m.canonicalize == nil {
+		return member, nil
+	}
+	return m.canonicalize(member)
+}
+
+// AddBinding grants role to member on projectID. Adding a member that
+// already has the role is a no-op.
+func (m *PolicyManager) AddBinding(ctx context.Context, projectID, member, role string) (ChangeSet, error) {
+	member, err := m.member(member)
+	if err != nil {
+		return ChangeSet{}, err
+	}
+	projectID = resourceOrDefault(ctx, projectID)
+	_, cs, err := m.modifyPolicy(ctx, projectID, func(policy *Policy) error {
+		m.addMemberTraced(projectID, policy, member, role, nil)
+		return nil
+	})
+	return cs, err
+}
+
+// RemoveMember revokes role from member on projectID. Removing a member that
+// doesn't have the role is a no-op.
+func (m *PolicyManager) RemoveMember(ctx context.Context, projectID, member, role string) (ChangeSet, error) {
+	member, err := m.member(member)
+	if err != nil {
+		return ChangeSet{}, err
+	}
+	projectID = resourceOrDefault(ctx, projectID)
+	_, cs, err := m.modifyPolicy(ctx, projectID, func(policy *Policy) error {
+		m.deleteMemberTraced(projectID, policy, member, role, nil)
+		return nil
+	})
+	return cs, err
+}
+
+// RemoveBinding revokes role from every member that holds it on projectID,
+// with or without a condition. See EstimateRemovalImpact to preview who
+// would be affected.
+func (m *PolicyManager) RemoveBinding(ctx context.Context, projectID, role string) (ChangeSet, error) {
+	_, cs, err := m.modifyPolicy(ctx, projectID, func(policy *Policy) error {
+		bindings := policy.Bindings[:0]
+		for _, b := range policy.Bindings {
+			if b.Role != role {
+				bindings = append(bindings, b)
+			}
+		}
+		policy.Bindings = bindings
+		return nil
+	})
+	return cs, err
+}
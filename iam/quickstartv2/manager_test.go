@@ -0,0 +1,228 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/api/cloudresourcemanager/v1"
+	"google.golang.org/api/googleapi"
+)
+
+func TestAddBindingRetriesConflict(t *testing.T) {
+	ctx := context.Background()
+	target := newFakeTarget()
+	target.put("my-project", &Policy{})
+	target.setErrs = []error{conflictErr}
+	m := newTestManager(t, target)
+
+	cs, err := m.AddBinding(ctx, "my-project", "user:alice@example.com", "roles/viewer")
+	if err != nil {
+		t.Fatalf("AddBinding: %v", err)
+	}
+	if len(cs.Changes) != 1 || cs.Changes[0].Op != OpAdd {
+		t.Errorf("AddBinding: got changes %+v, want one add", cs.Changes)
+	}
+	if target.sets != 2 {
+		t.Errorf("AddBinding: got %d SetPolicy calls, want 2", target.sets)
+	}
+}
+
+func TestRemoveMemberNoOp(t *testing.T) {
+	ctx := context.Background()
+	target := newFakeTarget()
+	target.put("my-project", &Policy{Bindings: []*Binding{
+		{Role: "roles/viewer", Members: []string{"user:bob@example.com"}},
+	}})
+	m := newTestManager(t, target)
+
+	cs, err := m.RemoveMember(ctx, "my-project", "user:alice@example.com", "roles/viewer")
+	if err != nil {
+		t.Fatalf("RemoveMember: %v", err)
+	}
+	if !cs.Empty() {
+		t.Errorf("RemoveMember: got changes %+v, want none", cs.Changes)
+	}
+	if target.sets != 0 {
+		t.Errorf("RemoveMember: got %d SetPolicy calls, want 0", target.sets)
+	}
+}
+
+func TestStatsRetriesByReason(t *testing.T) {
+	ctx := context.Background()
+	target := newFakeTarget()
+	target.put("my-project", &Policy{})
+	target.setErrs = []error{
+		conflictErr,
+		&googleapi.Error{Code: http.StatusServiceUnavailable, Message: "backend unavailable"},
+		conflictErr,
+	}
+	m := newTestManager(t, target)
+
+	if _, err := m.AddBinding(ctx, "my-project", "user:alice@example.com", "roles/viewer"); err != nil {
+		t.Fatalf("AddBinding: %v", err)
+	}
+
+	got := m.Stats()
+	want := ManagerStats{
+		Reads:           4,
+		Writes:          4,
+		Retries:         3,
+		RetriesByReason: map[string]int{RetryConflict: 2, RetryServerError: 1},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Stats: got diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestModifyPolicyRetriesMutate(t *testing.T) {
+	ctx := context.Background()
+	target := newFakeTarget()
+	target.put("my-project", &Policy{Bindings: []*Binding{
+		{Role: "roles/viewer", Members: []string{"user:bob@example.com"}},
+	}})
+	target.setErrs = []error{conflictErr}
+	m := newTestManager(t, target)
+
+	calls := 0
+	err := m.ModifyPolicy(ctx, "my-project", func(policy *Policy) error {
+		calls++
+		for _, b := range policy.Bindings {
+			if b.Role == "roles/viewer" {
+				b.Role = "roles/browser"
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ModifyPolicy: %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("ModifyPolicy: got %d mutate calls, want 2", calls)
+	}
+	want := []*Binding{{Role: "roles/browser", Members: []string{"user:bob@example.com"}}}
+	if diff := cmp.Diff(want, target.policy("my-project").Bindings); diff != "" {
+		t.Errorf("ModifyPolicy: got diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestWithPolicyVersion(t *testing.T) {
+	ctx := context.Background()
+	target := newFakeTarget()
+	target.put("my-project", &Policy{Version: 1})
+	m := newTestManager(t, target, WithPolicyVersion(3))
+
+	if _, err := m.AddBinding(ctx, "my-project", "user:alice@example.com", "roles/viewer"); err != nil {
+		t.Fatalf("AddBinding: %v", err)
+	}
+	if got := target.policy("my-project").Version; got != 3 {
+		t.Errorf("AddBinding: got version %d sent, want 3", got)
+	}
+
+	if _, err := NewPolicyManager(target, WithPolicyVersion(2)); err == nil {
+		t.Errorf("WithPolicyVersion(2): got nil error, want error")
+	}
+}
+
+func TestWithGetOptions(t *testing.T) {
+	ctx := context.Background()
+	target := newFakeTarget()
+	target.put("my-project", &Policy{})
+	opts := &cloudresourcemanager.GetPolicyOptions{RequestedPolicyVersion: 1}
+	m := newTestManager(t, target, WithGetOptions(opts))
+
+	if _, err := m.GetPolicy(ctx, "my-project"); err != nil {
+		t.Fatalf("GetPolicy: %v", err)
+	}
+	if target.getOpts != opts {
+		t.Errorf("GetPolicy: got options %+v forwarded, want %+v", target.getOpts, opts)
+	}
+
+	if _, err := NewPolicyManager(NewFileTarget("policy.json"), WithGetOptions(opts)); err == nil {
+		t.Errorf("WithGetOptions on a file target: got nil error, want error")
+	}
+}
+
+func TestWithMaxMembersPerRole(t *testing.T) {
+	ctx := context.Background()
+	target := newFakeTarget()
+	target.put("my-project", &Policy{Bindings: []*Binding{
+		{Role: "roles/viewer", Members: []string{"user:alice@example.com", "user:bob@example.com"}},
+	}})
+	m := newTestManager(t, target, WithMaxMembersPerRole(3))
+
+	if _, err := m.AddBinding(ctx, "my-project", "user:carol@example.com", "roles/viewer"); err != nil {
+		t.Fatalf("AddBinding(third member): %v", err)
+	}
+	_, err := m.AddBinding(ctx, "my-project", "user:dan@example.com", "roles/viewer")
+	if !errors.Is(err, ErrTooManyMembers) {
+		t.Errorf("AddBinding(fourth member): got %v, want ErrTooManyMembers", err)
+	}
+	if _, err := m.AddBinding(ctx, "my-project", "user:dan@example.com", "roles/editor"); err != nil {
+		t.Errorf("AddBinding(other role): %v", err)
+	}
+	if target.sets != 2 {
+		t.Errorf("got %d SetPolicy calls, want 2", target.sets)
+	}
+}
+
+func TestModifyPolicyAuditConfigsOnly(t *testing.T) {
+	ctx := context.Background()
+	target := newFakeTarget()
+	target.put("my-project", &Policy{Bindings: []*Binding{
+		{Role: "roles/viewer", Members: []string{"user:bob@example.com"}},
+	}})
+	m := newTestManager(t, target)
+
+	auditConfigs := []*cloudresourcemanager.AuditConfig{{
+		Service:         "storage.googleapis.com",
+		AuditLogConfigs: []*cloudresourcemanager.AuditLogConfig{{LogType: "DATA_READ"}},
+	}}
+	err := m.ModifyPolicy(ctx, "my-project", func(policy *Policy) error {
+		policy.AuditConfigs = auditConfigs
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ModifyPolicy: %v", err)
+	}
+	if target.sets != 1 {
+		t.Errorf("ModifyPolicy: got %d SetPolicy calls, want 1", target.sets)
+	}
+	if diff := cmp.Diff(auditConfigs, target.policy("my-project").AuditConfigs); diff != "" {
+		t.Errorf("ModifyPolicy: got diff (-want +got):\n%s", diff)
+	}
+
+	err = m.ModifyPolicy(ctx, "my-project", func(policy *Policy) error {
+		policy.Version = 3
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ModifyPolicy(version): %v", err)
+	}
+	if target.sets != 2 {
+		t.Errorf("ModifyPolicy(version): got %d SetPolicy calls, want 2", target.sets)
+	}
+
+	if err := m.ModifyPolicy(ctx, "my-project", func(policy *Policy) error { return nil }); err != nil {
+		t.Fatalf("ModifyPolicy(no-op): %v", err)
+	}
+	if target.sets != 2 {
+		t.Errorf("ModifyPolicy(no-op): got %d SetPolicy calls, want 2", target.sets)
+	}
+}
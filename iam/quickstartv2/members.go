@@ -0,0 +1,215 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MemberType is the kind of principal an IAM member string identifies.
+type MemberType string
+
+// Member types, named after the prefix used in policies.
+const (
+	MemberUser                  MemberType = "user"
+	MemberGroup                 MemberType = "group"
+	MemberServiceAccount        MemberType = "serviceAccount"
+	MemberDomain                MemberType = "domain"
+	MemberAllUsers              MemberType = "allUsers"
+	MemberAllAuthenticatedUsers MemberType = "allAuthenticatedUsers"
+	// MemberDeleted is a principal that was deleted after being granted a
+	// role, such as "deleted:user:alice@example.com?uid=123".
+	MemberDeleted MemberType = "deleted"
+	// MemberPrincipal, MemberPrincipalSet and MemberPrincipalHierarchy are
+	// workforce and workload identity federation principals, written as
+	// URIs such as
+	// "principal://iam.googleapis.com/locations/global/workforcePools/POOL/subject/SUBJECT".
+	// Their identifier is the URI without the scheme.
+	MemberPrincipal          MemberType = "principal"
+	MemberPrincipalSet       MemberType = "principalSet"
+	MemberPrincipalHierarchy MemberType = "principalHierarchy"
+)
+
+// federationHost is the host of every federation principal URI.
+const federationHost = "iam.googleapis.com/"
+
+// ParseMember splits member into its type and identifier, such as
+// (MemberUser, "alice@example.com") for "user:alice@example.com". The
+// identifier is empty for allUsers and allAuthenticatedUsers.
+func ParseMember(member string) (MemberType, string, error) {
+	switch member {
+	case string(MemberAllUsers):
+		return MemberAllUsers, "", nil
+	case string(MemberAllAuthenticatedUsers):
+		return MemberAllAuthenticatedUsers, "", nil
+	}
+
+	i := strings.Index(member, ":")
+	if i < 0 {
+		return "", "", fmt.Errorf("invalid member %q: missing type prefix, such as \"user:\"", member)
+	}
+	prefix, id := member[:i], member[i+1:]
+	if id == "" {
+		return "", "", fmt.Errorf("invalid member %q: empty identifier", member)
+	}
+
+	switch t := MemberType(prefix); t {
+	case MemberUser, MemberGroup, MemberServiceAccount:
+		if !validEmail(id) {
+			return "", "", fmt.Errorf("invalid member %q: %q is not an email address", member, id)
+		}
+		return t, id, nil
+	case MemberDomain:
+		if strings.Contains(id, "@") || !strings.Contains(id, ".") {
+			return "", "", fmt.Errorf("invalid member %q: %q is not a domain", member, id)
+		}
+		return t, id, nil
+	case MemberPrincipal, MemberPrincipalSet, MemberPrincipalHierarchy:
+		path := strings.TrimPrefix(id, "//"+federationHost)
+		if len(path) == len(id) || path == "" || strings.ContainsAny(path, " \t\n") {
+			return "", "", fmt.Errorf("invalid member %q: want %s://%sPATH", member, prefix, federationHost)
+		}
+		return t, strings.TrimPrefix(id, "//"), nil
+	case MemberDeleted:
+		if _, _, err := ParseMember(strings.SplitN(id, "?", 2)[0]); err != nil {
+			return "", "", fmt.Errorf("invalid deleted member %q: %v", member, err)
+		}
+		return t, id, nil
+	}
+	return "", "", fmt.Errorf("invalid member %q: unknown type %q", member, prefix)
+}
+
+// ValidateMember returns an error if member is not a well-formed IAM member.
+func ValidateMember(member string) error {
+	_, _, err := ParseMember(member)
+	return err
+}
+
+// validEmail reports whether s looks like an email address.
+func validEmail(s string) bool {
+	at := strings.LastIndex(s, "@")
+	return at > 0 && at < len(s)-1 && !strings.ContainsAny(s, " \t\n")
+}
+
+// ValidateRole returns an error if role is not a predefined role name
+// ("roles/NAME") or a custom role name ("projects/ID/roles/NAME" or
+// "organizations/ID/roles/NAME").
+func ValidateRole(role string) error {
+	parts := strings.Split(role, "/")
+	switch {
+	case len(parts) == 2 && parts[0] == "roles" && parts[1] != "":
+		return nil
+	case len(parts) == 4 && (parts[0] == "projects" || parts[0] == "organizations") &&
+		parts[1] != "" && parts[2] == "roles" && parts[3] != "":
+		return nil
+	}
+	return fmt.Errorf("invalid role %q: want roles/NAME, projects/ID/roles/NAME or organizations/ID/roles/NAME", role)
+}
+
+// CanonicalizeMember trims whitespace around member and its type prefix,
+// fixes the case of the prefix and lowercases the domain of email
+// addresses, so that "user: Alice@Example.COM " becomes
+// "user:Alice@example.com". The result is validated with ValidateMember.
+func CanonicalizeMember(member string) (string, error) {
+	return canonicalizeMember(member, false)
+}
+
+// canonicalizeMember implements CanonicalizeMember, also lowercasing the
+// local part of user and group emails if lowerLocal is set. Service account
+// emails and domains are always lowercased.
+func canonicalizeMember(member string, lowerLocal bool) (string, error) {
+	member = strings.TrimSpace(member)
+	i := strings.Index(member, ":")
+	if i < 0 {
+		for _, t := range []MemberType{MemberAllUsers, MemberAllAuthenticatedUsers} {
+			if strings.EqualFold(member, string(t)) {
+				return string(t), nil
+			}
+		}
+		return "", ValidateMember(member)
+	}
+
+	prefix, id := strings.TrimSpace(member[:i]), strings.TrimSpace(member[i+1:])
+	t := MemberType(prefix)
+	for _, known := range []MemberType{
+		MemberUser, MemberGroup, MemberServiceAccount, MemberDomain, MemberDeleted,
+		MemberPrincipal, MemberPrincipalSet, MemberPrincipalHierarchy,
+	} {
+		if strings.EqualFold(prefix, string(known)) {
+			t = known
+		}
+	}
+	switch t {
+	case MemberUser, MemberGroup:
+		if at := strings.LastIndex(id, "@"); at >= 0 {
+			local := id[:at]
+			if lowerLocal {
+				local = strings.ToLower(local)
+			}
+			id = local + strings.ToLower(id[at:])
+		}
+	case MemberServiceAccount, MemberDomain:
+		id = strings.ToLower(id)
+	}
+
+	canonical := string(t) + ":" + id
+	if err := ValidateMember(canonical); err != nil {
+		return "", err
+	}
+	return canonical, nil
+}
+
+// InvalidMember is a member in a policy that ValidateMember rejects.
+type InvalidMember struct {
+	Role   string
+	Member string
+	// Problem describes why the member is invalid.
+	Problem string
+}
+
+// AuditMembers returns the members of policy that don't parse, such as
+// empty strings or emails without a type prefix, in binding order.
+func AuditMembers(policy *Policy) []InvalidMember {
+	var invalid []InvalidMember
+	for _, b := range policy.Bindings {
+		for _, m := range b.Members {
+			if err := ValidateMember(m); err != nil {
+				invalid = append(invalid, InvalidMember{Role: b.Role, Member: m, Problem: err.Error()})
+			}
+		}
+	}
+	return invalid
+}
+
+// RemoveInvalidMembers removes the members reported by AuditMembers from
+// policy, dropping bindings left empty. It can be passed to ModifyPolicy.
+func RemoveInvalidMembers(policy *Policy) error {
+	bindings := policy.Bindings[:0]
+	for _, b := range policy.Bindings {
+		members := b.Members[:0]
+		for _, m := range b.Members {
+			if ValidateMember(m) == nil {
+				members = append(members, m)
+			}
+		}
+		b.Members = members
+		if len(b.Members) > 0 {
+			bindings = append(bindings, b)
+		}
+	}
+	policy.Bindings = bindings
+	return nil
+}
@@ -0,0 +1,174 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCanonicalizeMember(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"user: Alice@Example.COM ", "user:Alice@example.com"},
+		{"User:bob@EXAMPLE.com", "user:bob@example.com"},
+		{" serviceaccount:App@My-Project.iam.gserviceaccount.com", "serviceAccount:app@my-project.iam.gserviceaccount.com"},
+		{"domain:Example.COM", "domain:example.com"},
+		{"ALLUSERS", "allUsers"},
+	}
+	for _, test := range tests {
+		got, err := CanonicalizeMember(test.in)
+		if err != nil {
+			t.Errorf("CanonicalizeMember(%q): %v", test.in, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("CanonicalizeMember(%q): got %q, want %q", test.in, got, test.want)
+		}
+	}
+
+	for _, bad := range []string{"", "alice@example.com", "user:", "user:alice", "robot:alice@example.com"} {
+		if got, err := CanonicalizeMember(bad); err == nil {
+			t.Errorf("CanonicalizeMember(%q): got %q, want error", bad, got)
+		}
+	}
+}
+
+func TestAddBindingCanonicalize(t *testing.T) {
+	ctx := context.Background()
+	target := newFakeTarget()
+	target.put("my-project", &Policy{Bindings: []*Binding{
+		{Role: "roles/viewer", Members: []string{"user:alice@example.com"}},
+	}})
+	m := newTestManager(t, target, WithCanonicalize(true))
+
+	cs, err := m.AddBinding(ctx, "my-project", "user: Alice@Example.COM ", "roles/viewer")
+	if err != nil {
+		t.Fatalf("AddBinding: %v", err)
+	}
+	if !cs.Empty() {
+		t.Errorf("AddBinding: got changes %+v, want none for an existing member in another case", cs.Changes)
+	}
+}
+
+func TestParseMemberFederation(t *testing.T) {
+	tests := []struct {
+		member   string
+		wantType MemberType
+		wantID   string
+	}{
+		{
+			"principal://iam.googleapis.com/locations/global/workforcePools/my-pool/subject/alice",
+			MemberPrincipal,
+			"iam.googleapis.com/locations/global/workforcePools/my-pool/subject/alice",
+		},
+		{
+			"principalSet://iam.googleapis.com/projects/123/locations/global/workloadIdentityPools/my-pool/attribute.repository/my-org/my-repo",
+			MemberPrincipalSet,
+			"iam.googleapis.com/projects/123/locations/global/workloadIdentityPools/my-pool/attribute.repository/my-org/my-repo",
+		},
+		{
+			"principalHierarchy://iam.googleapis.com/organizations/123/folders/456",
+			MemberPrincipalHierarchy,
+			"iam.googleapis.com/organizations/123/folders/456",
+		},
+	}
+	for _, test := range tests {
+		gotType, gotID, err := ParseMember(test.member)
+		if err != nil {
+			t.Errorf("ParseMember(%q): %v", test.member, err)
+			continue
+		}
+		if gotType != test.wantType || gotID != test.wantID {
+			t.Errorf("ParseMember(%q): got (%q, %q), want (%q, %q)", test.member, gotType, gotID, test.wantType, test.wantID)
+		}
+	}
+
+	for _, bad := range []string{
+		"principal://",
+		"principal://iam.googleapis.com/",
+		"principal:iam.googleapis.com/locations/global/workforcePools/my-pool/subject/alice",
+		"principalSet://example.com/pools/my-pool",
+	} {
+		if err := ValidateMember(bad); err == nil {
+			t.Errorf("ValidateMember(%q): got nil, want error", bad)
+		}
+	}
+}
+
+func TestGrantFederationMember(t *testing.T) {
+	ctx := context.Background()
+	target := newFakeTarget()
+	target.put("my-project", &Policy{})
+	m := newTestManager(t, target, WithCanonicalize(false))
+
+	member := "principalSet://iam.googleapis.com/locations/global/workforcePools/my-pool/group/eng"
+	cs, err := m.AddBinding(ctx, "my-project", member, "roles/viewer")
+	if err != nil {
+		t.Fatalf("AddBinding: %v", err)
+	}
+	if len(cs.Changes) != 1 || cs.Changes[0].Member != member {
+		t.Errorf("AddBinding: got changes %+v, want one add of %s", cs.Changes, member)
+	}
+	cs, err = m.RemoveMember(ctx, "my-project", member, "roles/viewer")
+	if err != nil {
+		t.Fatalf("RemoveMember: %v", err)
+	}
+	if len(cs.Changes) != 1 || cs.Changes[0].Op != OpRemove {
+		t.Errorf("RemoveMember: got changes %+v, want one remove", cs.Changes)
+	}
+}
+
+func TestAuditMembers(t *testing.T) {
+	ctx := context.Background()
+	target := newFakeTarget()
+	target.put("my-project", &Policy{Bindings: []*Binding{
+		{Role: "roles/viewer", Members: []string{
+			"user:alice@example.com",
+			"",
+			"bob@example.com",
+			"principal://iam.googleapis.com/locations/global/workforcePools/my-pool/subject/carol",
+		}},
+		{Role: "roles/editor", Members: []string{"user:"}},
+	}})
+	m := newTestManager(t, target)
+
+	policy, err := m.GetPolicy(ctx, "my-project")
+	if err != nil {
+		t.Fatalf("GetPolicy: %v", err)
+	}
+	var got []string
+	for _, im := range AuditMembers(policy) {
+		got = append(got, im.Role+" "+im.Member)
+	}
+	want := []string{"roles/viewer ", "roles/viewer bob@example.com", "roles/editor user:"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("AuditMembers: got diff (-want +got):\n%s", diff)
+	}
+
+	if err := m.ModifyPolicy(ctx, "my-project", RemoveInvalidMembers); err != nil {
+		t.Fatalf("ModifyPolicy(RemoveInvalidMembers): %v", err)
+	}
+	wantBindings := []*Binding{{Role: "roles/viewer", Members: []string{
+		"user:alice@example.com",
+		"principal://iam.googleapis.com/locations/global/workforcePools/my-pool/subject/carol",
+	}}}
+	if diff := cmp.Diff(wantBindings, target.policy("my-project").Bindings); diff != "" {
+		t.Errorf("RemoveInvalidMembers: got diff (-want +got):\n%s", diff)
+	}
+}
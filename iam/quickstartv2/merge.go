@@ -0,0 +1,144 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"strings"
+
+	"google.golang.org/api/cloudresourcemanager/v1"
+)
+
+// normalizeExpression collapses each run of whitespace outside string
+// literals in a CEL expression to a single space and trims the ends, so that
+// expressions differing only in formatting compare equal.
+func normalizeExpression(expr string) string {
+	var b strings.Builder
+	var quote rune
+	escaped, space := false, false
+	for _, r := range strings.TrimSpace(expr) {
+		switch {
+		case quote != 0:
+			b.WriteRune(r)
+			if escaped {
+				escaped = false
+			} else if r == '\\' {
+				escaped = true
+			} else if r == quote {
+				quote = 0
+			}
+			continue
+		case r == ' ' || r == '\t' || r == '\n' || r == '\r':
+			space = true
+			continue
+		}
+		if space {
+			b.WriteByte(' ')
+			space = false
+		}
+		if r == '"' || r == '\'' {
+			quote = r
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// mergeKey identifies bindings that MergeBindings combines: the same role
+// and, for conditional bindings, the same title and normalized expression.
+func mergeKey(b *Binding) string {
+	if b.Condition == nil {
+		return b.Role
+	}
+	return b.Role + "\x00" + b.Condition.Title + "\x00" + normalizeExpression(b.Condition.Expression)
+}
+
+// MergeBindings returns the union of the given binding lists. Bindings with
+// the same role and condition are combined into one with the union of their
+// members. Conditions are the same when their titles match and their
+// expressions match after normalizing whitespace; semantic equivalence of
+// CEL expressions, such as reordered operands, is not detected. The first
+// binding's condition, including its description, is kept. The inputs are
+// not modified.
+func MergeBindings(lists ...[]*Binding) []*Binding {
+	var merged []*Binding
+	index := make(map[string]*Binding)
+	for _, list := range lists {
+		for _, b := range list {
+			k := mergeKey(b)
+			if m, ok := index[k]; ok {
+				m.Members = dedupeStrings(append(m.Members, b.Members...))
+				continue
+			}
+			c := copyBinding(b)
+			c.Members = dedupeStrings(c.Members)
+			index[k] = c
+			merged = append(merged, c)
+		}
+	}
+	return merged
+}
+
+// MergePolicies combines policy fragments into one policy whose bindings
+// are merged with MergeBindings and whose audit configs are merged with
+// MergeAuditConfigs. The result has the highest version of the fragments
+// and no etag.
+func MergePolicies(fragments ...*Policy) *Policy {
+	out := &Policy{}
+	var lists [][]*Binding
+	for _, p := range fragments {
+		if p.Version > out.Version {
+			out.Version = p.Version
+		}
+		lists = append(lists, p.Bindings)
+		out.AuditConfigs = MergeAuditConfigs(out.AuditConfigs, p.AuditConfigs)
+	}
+	out.Bindings = MergeBindings(lists...)
+	return out
+}
+
+// MergeAuditConfigs returns the union of base and overlay. Configs for the
+// same service are combined, and within a service the log configs for the
+// same log type are combined with the union of their exempted members.
+// Services and log types keep the order in which they first appear. The
+// inputs are not modified.
+func MergeAuditConfigs(base, overlay []*cloudresourcemanager.AuditConfig) []*cloudresourcemanager.AuditConfig {
+	var merged []*cloudresourcemanager.AuditConfig
+	services := make(map[string]*cloudresourcemanager.AuditConfig)
+	logTypes := make(map[string]*cloudresourcemanager.AuditLogConfig)
+	for _, list := range [][]*cloudresourcemanager.AuditConfig{base, overlay} {
+		for _, ac := range list {
+			out, ok := services[ac.Service]
+			if !ok {
+				out = &cloudresourcemanager.AuditConfig{Service: ac.Service}
+				services[ac.Service] = out
+				merged = append(merged, out)
+			}
+			for _, lc := range ac.AuditLogConfigs {
+				k := ac.Service + "\x00" + lc.LogType
+				if m, ok := logTypes[k]; ok {
+					m.ExemptedMembers = dedupeStrings(append(m.ExemptedMembers, lc.ExemptedMembers...))
+					continue
+				}
+				c := &cloudresourcemanager.AuditLogConfig{
+					LogType:         lc.LogType,
+					ExemptedMembers: dedupeStrings(append([]string(nil), lc.ExemptedMembers...)),
+				}
+				logTypes[k] = c
+				out.AuditLogConfigs = append(out.AuditLogConfigs, c)
+			}
+		}
+	}
+	return merged
+}
@@ -0,0 +1,115 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/api/cloudresourcemanager/v1"
+)
+
+func TestMergePoliciesConditional(t *testing.T) {
+	a := &Policy{Version: 3, Bindings: []*Binding{{
+		Role:    "roles/viewer",
+		Members: []string{"user:alice@example.com"},
+		Condition: &cloudresourcemanager.Expr{
+			Title:      "temp-access",
+			Expression: `request.time < timestamp("2030-01-01T00:00:00Z")`,
+		},
+	}}}
+	b := &Policy{Version: 1, Bindings: []*Binding{{
+		Role:    "roles/viewer",
+		Members: []string{"user:bob@example.com", "user:alice@example.com"},
+		Condition: &cloudresourcemanager.Expr{
+			Title:      "temp-access",
+			Expression: "request.time  <\n  timestamp(\"2030-01-01T00:00:00Z\")",
+		},
+	}}}
+
+	got := MergePolicies(a, b)
+	want := &Policy{Version: 3, Bindings: []*Binding{{
+		Role:    "roles/viewer",
+		Members: []string{"user:alice@example.com", "user:bob@example.com"},
+		Condition: &cloudresourcemanager.Expr{
+			Title:      "temp-access",
+			Expression: `request.time < timestamp("2030-01-01T00:00:00Z")`,
+		},
+	}}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("MergePolicies: got diff (-want +got):\n%s", diff)
+	}
+	if len(a.Bindings[0].Members) != 1 {
+		t.Errorf("MergePolicies modified its input")
+	}
+}
+
+func TestNormalizeExpression(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"  a   ==\tb ", "a == b"},
+		{`resource.name == "a  b"`, `resource.name == "a  b"`},
+		{`x == 'it\'s   here'  &&  y`, `x == 'it\'s   here' && y`},
+	}
+	for _, test := range tests {
+		if got := normalizeExpression(test.in); got != test.want {
+			t.Errorf("normalizeExpression(%q): got %q, want %q", test.in, got, test.want)
+		}
+	}
+}
+
+func TestMergeAuditConfigs(t *testing.T) {
+	base := []*cloudresourcemanager.AuditConfig{{
+		Service: "storage.googleapis.com",
+		AuditLogConfigs: []*cloudresourcemanager.AuditLogConfig{
+			{LogType: "DATA_READ", ExemptedMembers: []string{"user:alice@example.com"}},
+		},
+	}}
+	overlay := []*cloudresourcemanager.AuditConfig{
+		{
+			Service: "storage.googleapis.com",
+			AuditLogConfigs: []*cloudresourcemanager.AuditLogConfig{
+				{LogType: "DATA_READ", ExemptedMembers: []string{"user:bob@example.com", "user:alice@example.com"}},
+				{LogType: "DATA_WRITE"},
+			},
+		},
+		{
+			Service:         "allServices",
+			AuditLogConfigs: []*cloudresourcemanager.AuditLogConfig{{LogType: "ADMIN_READ"}},
+		},
+	}
+
+	got := MergeAuditConfigs(base, overlay)
+	want := []*cloudresourcemanager.AuditConfig{
+		{
+			Service: "storage.googleapis.com",
+			AuditLogConfigs: []*cloudresourcemanager.AuditLogConfig{
+				{LogType: "DATA_READ", ExemptedMembers: []string{"user:alice@example.com", "user:bob@example.com"}},
+				{LogType: "DATA_WRITE"},
+			},
+		},
+		{
+			Service:         "allServices",
+			AuditLogConfigs: []*cloudresourcemanager.AuditLogConfig{{LogType: "ADMIN_READ"}},
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("MergeAuditConfigs: got diff (-want +got):\n%s", diff)
+	}
+	if n := len(base[0].AuditLogConfigs[0].ExemptedMembers); n != 1 {
+		t.Errorf("MergeAuditConfigs modified its input: got %d exempted members in base, want 1", n)
+	}
+}
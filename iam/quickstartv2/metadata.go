@@ -0,0 +1,190 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"time"
+)
+
+// GrantMetadata describes a grant made by this tool, kept in a sidecar file
+// next to the policy so that managed grants can be told apart from grants
+// made elsewhere.
+type GrantMetadata struct {
+	Resource string    `json:"resource"`
+	Role     string    `json:"role"`
+	Member   string    `json:"member"`
+	Reason   string    `json:"reason,omitempty"`
+	Ticket   string    `json:"ticket,omitempty"`
+	Owner    string    `json:"owner,omitempty"`
+	Created  time.Time `json:"created"`
+	// Labels are free-form annotations.
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// metadataFile is the on-disk format of the grant metadata sidecar.
+type metadataFile struct {
+	Grants []GrantMetadata `json:"grants"`
+}
+
+// RecordGrantMetadata stores md in the sidecar file at path, replacing any
+// metadata already recorded for the same resource, role and member.
+func RecordGrantMetadata(path string, md GrantMetadata) error {
+	if md.Resource == "" || md.Role == "" || md.Member == "" {
+		return errors.New("grant metadata needs a resource, role and member")
+	}
+	f, err := readMetadataFile(path)
+	if err != nil {
+		return err
+	}
+	f.put(md)
+	return writeMetadataFile(path, f)
+}
+
+// WithGrantMetadata makes the manager maintain the sidecar file at path:
+// every member it grants a role is recorded, with the change's reason, and
+// the record is removed once a change leaves the member without the role.
+// If the file can't be updated the change is still made, with a warning
+// in its ChangeSet.
+func WithGrantMetadata(path string) Option {
+	return func(m *PolicyManager) error {
+		m.metadataPath = path
+		return nil
+	}
+}
+
+// updateGrantMetadata records the changes in cs, which left the policy as
+// policy, in the manager's sidecar file, if it has one.
+func (m *PolicyManager) updateGrantMetadata(cs ChangeSet, policy *Policy) error {
+	if m.metadataPath == "" || cs.Empty() {
+		return nil
+	}
+	// Managers writing several projects at once share the file.
+	m.metadataMu.Lock()
+	defer m.metadataMu.Unlock()
+	f, err := readMetadataFile(m.metadataPath)
+	if err != nil {
+		return err
+	}
+	for _, c := range cs.Changes {
+		switch {
+		case c.Op == OpAdd:
+			f.put(GrantMetadata{Resource: cs.Project, Role: c.Role, Member: c.Member, Reason: cs.Reason, Created: m.now()})
+		case !policyHasRole(policy, c.Member, c.Role):
+			f.remove(cs.Project, c.Role, c.Member)
+		}
+	}
+	return writeMetadataFile(m.metadataPath, f)
+}
+
+// ListManagedGrants returns the grant metadata recorded in the sidecar file
+// at path, sorted by resource, role and member. A missing file has no
+// grants.
+func ListManagedGrants(path string) ([]GrantMetadata, error) {
+	f, err := readMetadataFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return f.Grants, nil
+}
+
+// ListUnmanagedGrants returns the grants in policy, the policy of
+// resource, that have no metadata in the sidecar file at path: those made
+// other than by this tool. Each Match is one member and role, sorted by
+// role and then member.
+func ListUnmanagedGrants(policy *Policy, resource, path string) ([]Match, error) {
+	f, err := readMetadataFile(path)
+	if err != nil {
+		return nil, err
+	}
+	managed := make(map[Match]bool)
+	for _, g := range f.Grants {
+		if g.Resource == resource {
+			managed[Match{Member: g.Member, Role: g.Role}] = true
+		}
+	}
+	var unmanaged []Match
+	for _, role := range policyRoles(policy) {
+		for _, member := range policyMembers(policy, role) {
+			if match := (Match{Member: member, Role: role}); !managed[match] {
+				unmanaged = append(unmanaged, match)
+			}
+		}
+	}
+	return unmanaged, nil
+}
+
+// put adds md, replacing the metadata of the same grant.
+func (f *metadataFile) put(md GrantMetadata) {
+	for i, g := range f.Grants {
+		if g.Resource == md.Resource && g.Role == md.Role && g.Member == md.Member {
+			f.Grants[i] = md
+			return
+		}
+	}
+	f.Grants = append(f.Grants, md)
+}
+
+// remove drops the metadata of member's grant of role on resource.
+func (f *metadataFile) remove(resource, role, member string) {
+	grants := f.Grants[:0]
+	for _, g := range f.Grants {
+		if g.Resource != resource || g.Role != role || g.Member != member {
+			grants = append(grants, g)
+		}
+	}
+	f.Grants = grants
+}
+
+// readMetadataFile reads the sidecar file at path. A missing file is empty.
+func readMetadataFile(path string) (*metadataFile, error) {
+	f := &metadataFile{}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return f, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ioutil.ReadFile: %v", err)
+	}
+	if err := json.Unmarshal(data, f); err != nil {
+		return nil, fmt.Errorf("invalid grant metadata file %s: %v", path, err)
+	}
+	return f, nil
+}
+
+// writeMetadataFile replaces the sidecar file at path with f, its grants
+// sorted by resource, role and member.
+func writeMetadataFile(path string, f *metadataFile) error {
+	sort.Slice(f.Grants, func(i, j int) bool {
+		a, b := f.Grants[i], f.Grants[j]
+		if a.Resource != b.Resource {
+			return a.Resource < b.Resource
+		}
+		if a.Role != b.Role {
+			return a.Role < b.Role
+		}
+		return a.Member < b.Member
+	})
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return fmt.Errorf("json.MarshalIndent: %v", err)
+	}
+	return writeFileAtomic(path, data)
+}
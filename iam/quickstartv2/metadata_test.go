@@ -0,0 +1,113 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestGrantMetadataRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "grants.json")
+	created := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	bob := GrantMetadata{
+		Resource: "my-project",
+		Role:     "roles/viewer",
+		Member:   "user:bob@example.com",
+		Owner:    "team-data",
+		Created:  created,
+	}
+	alice := GrantMetadata{
+		Resource: "my-project",
+		Role:     "roles/viewer",
+		Member:   "user:alice@example.com",
+		Reason:   "quarterly review",
+		Ticket:   "JIRA-123",
+		Owner:    "team-infra",
+		Created:  created,
+		Labels:   map[string]string{"env": "prod"},
+	}
+	for _, md := range []GrantMetadata{bob, alice} {
+		if err := RecordGrantMetadata(path, md); err != nil {
+			t.Fatalf("RecordGrantMetadata: %v", err)
+		}
+	}
+	bob.Ticket = "JIRA-456"
+	if err := RecordGrantMetadata(path, bob); err != nil {
+		t.Fatalf("RecordGrantMetadata(update): %v", err)
+	}
+
+	got, err := ListManagedGrants(path)
+	if err != nil {
+		t.Fatalf("ListManagedGrants: %v", err)
+	}
+	if diff := cmp.Diff([]GrantMetadata{alice, bob}, got); diff != "" {
+		t.Errorf("ListManagedGrants: got diff (-want +got):\n%s", diff)
+	}
+
+	if got, err := ListManagedGrants(filepath.Join(t.TempDir(), "missing.json")); err != nil || len(got) != 0 {
+		t.Errorf("ListManagedGrants(missing file): got (%v, %v), want no grants", got, err)
+	}
+}
+
+func TestWithGrantMetadata(t *testing.T) {
+	ctx := WithReason(context.Background(), "JIRA-123")
+	target := newFakeTarget()
+	target.put("my-project", &Policy{Bindings: []*Binding{
+		{Role: "roles/viewer", Members: []string{"user:legacy@example.com"}},
+	}})
+	path := filepath.Join(t.TempDir(), "grants.json")
+	m := newTestManager(t, target, WithGrantMetadata(path))
+
+	for _, member := range []string{"user:alice@example.com", "user:bob@example.com"} {
+		if _, err := m.AddBinding(ctx, "my-project", member, "roles/viewer"); err != nil {
+			t.Fatalf("AddBinding(%s): %v", member, err)
+		}
+	}
+	if _, err := m.RemoveMember(ctx, "my-project", "user:bob@example.com", "roles/viewer"); err != nil {
+		t.Fatalf("RemoveMember: %v", err)
+	}
+	got, err := ListManagedGrants(path)
+	if err != nil {
+		t.Fatalf("ListManagedGrants: %v", err)
+	}
+	want := []GrantMetadata{{
+		Resource: "my-project",
+		Role:     "roles/viewer",
+		Member:   "user:alice@example.com",
+		Reason:   "JIRA-123",
+		Created:  time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC),
+	}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("ListManagedGrants: got diff (-want +got):\n%s", diff)
+	}
+
+	unmanaged, err := ListUnmanagedGrants(target.policy("my-project"), "my-project", path)
+	if err != nil {
+		t.Fatalf("ListUnmanagedGrants: %v", err)
+	}
+	if diff := cmp.Diff([]Match{{Member: "user:legacy@example.com", Role: "roles/viewer"}}, unmanaged); diff != "" {
+		t.Errorf("ListUnmanagedGrants: got diff (-want +got):\n%s", diff)
+	}
+	// Grants recorded for another project don't count.
+	unmanaged, err = ListUnmanagedGrants(target.policy("my-project"), "other-project", path)
+	if err != nil || len(unmanaged) != 2 {
+		t.Errorf("ListUnmanagedGrants(other-project): got (%v, %v), want both grants", unmanaged, err)
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"sort"
+)
+
+// NormalizePolicy canonicalizes the members of each binding as
+// CanonicalizeMember does, so that members differing only in the case of
+// their type prefix or domain are recognized as the same, then removes
+// duplicate members and drops bindings that have no members. Members that
+// are not well formed are kept as they are. It modifies policy in place.
+func NormalizePolicy(policy *Policy) {
+	bindings := policy.Bindings[:0]
+	for _, b := range policy.Bindings {
+		for i, m := range b.Members {
+			if c, err := CanonicalizeMember(m); err == nil {
+				b.Members[i] = c
+			}
+		}
+		b.Members = dedupeStrings(b.Members)
+		if len(b.Members) > 0 {
+			bindings = append(bindings, b)
+		}
+	}
+	policy.Bindings = bindings
+}
+
+// CoalesceBindings merges bindings that have the same role and condition
+// into one. It modifies policy in place.
+func CoalesceBindings(policy *Policy) {
+	type key struct{ role, condition string }
+	first := make(map[key]*Binding)
+	bindings := policy.Bindings[:0]
+	for _, b := range policy.Bindings {
+		k := key{b.Role, conditionKey(b.Condition)}
+		if f, ok := first[k]; ok {
+			f.Members = dedupeStrings(append(f.Members, b.Members...))
+			continue
+		}
+		first[k] = b
+		bindings = append(bindings, b)
+	}
+	policy.Bindings = bindings
+}
+
+// SortMembers sorts the members of each binding, and the bindings by role and
+// then condition, so that equivalent policies serialize identically. It
+// modifies policy in place.
+func SortMembers(policy *Policy) {
+	for _, b := range policy.Bindings {
+		sort.Strings(b.Members)
+	}
+	sort.SliceStable(policy.Bindings, func(i, j int) bool {
+		a, b := policy.Bindings[i], policy.Bindings[j]
+		if a.Role != b.Role {
+			return a.Role < b.Role
+		}
+		return conditionKey(a.Condition) < conditionKey(b.Condition)
+	})
+}
+
+// canonicalPolicy returns a normalized, coalesced and sorted copy of policy.
+func canonicalPolicy(policy *Policy) *Policy {
+	p := copyPolicy(policy)
+	NormalizePolicy(p)
+	CoalesceBindings(p)
+	SortMembers(p)
+	return p
+}
+
+// dedupeStrings returns list without repeated values, keeping the first
+// occurrence of each.
+func dedupeStrings(list []string) []string {
+	seen := make(map[string]bool)
+	out := list[:0]
+	for _, s := range list {
+		if !seen[s] {
+			seen[s] = true
+			out = append(out, s)
+		}
+	}
+	return out
+}
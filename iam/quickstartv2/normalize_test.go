@@ -0,0 +1,69 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestNormalizePolicy(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		bindings []*Binding
+		want     []*Binding
+	}{
+		{
+			name:     "duplicates",
+			bindings: []*Binding{{Role: "roles/viewer", Members: []string{"user:alice@example.com", "user:bob@example.com", "user:alice@example.com"}}},
+			want:     []*Binding{{Role: "roles/viewer", Members: []string{"user:alice@example.com", "user:bob@example.com"}}},
+		},
+		{
+			name:     "case folding",
+			bindings: []*Binding{{Role: "roles/viewer", Members: []string{"User:alice@Example.COM", "user:alice@example.com", "serviceAccount:CI@my-project.iam.gserviceaccount.com"}}},
+			want:     []*Binding{{Role: "roles/viewer", Members: []string{"user:alice@example.com", "serviceAccount:ci@my-project.iam.gserviceaccount.com"}}},
+		},
+		{
+			name:     "local part kept",
+			bindings: []*Binding{{Role: "roles/viewer", Members: []string{"user:Alice@example.com", "user:alice@example.com"}}},
+			want:     []*Binding{{Role: "roles/viewer", Members: []string{"user:Alice@example.com", "user:alice@example.com"}}},
+		},
+		{
+			name:     "whitespace",
+			bindings: []*Binding{{Role: "roles/viewer", Members: []string{" group:dev@example.com ", "group:dev@example.com"}}},
+			want:     []*Binding{{Role: "roles/viewer", Members: []string{"group:dev@example.com"}}},
+		},
+		{
+			name:     "invalid member kept",
+			bindings: []*Binding{{Role: "roles/viewer", Members: []string{"alice", "alice"}}},
+			want:     []*Binding{{Role: "roles/viewer", Members: []string{"alice"}}},
+		},
+		{
+			name: "empty binding dropped",
+			bindings: []*Binding{
+				{Role: "roles/viewer", Members: nil},
+				{Role: "roles/editor", Members: []string{"user:bob@example.com"}},
+			},
+			want: []*Binding{{Role: "roles/editor", Members: []string{"user:bob@example.com"}}},
+		},
+	} {
+		policy := &Policy{Bindings: tc.bindings}
+		NormalizePolicy(policy)
+		if diff := cmp.Diff(tc.want, policy.Bindings); diff != "" {
+			t.Errorf("NormalizePolicy(%s): got diff (-want +got):\n%s", tc.name, diff)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// mergePatch applies an RFC 7386 JSON merge patch to doc and returns the
+// result. Objects are merged key by key, a null value deletes a key and
+// any other value, including an array, replaces the original.
+func mergePatch(doc, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	d, ok := doc.(map[string]interface{})
+	if !ok {
+		d = make(map[string]interface{})
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(d, k)
+			continue
+		}
+		d[k] = mergePatch(d[k], v)
+	}
+	return d
+}
+
+// bindingsDocument returns the unconditional bindings of policy as a JSON
+// object mapping each role to its members.
+func bindingsDocument(policy *Policy) map[string]interface{} {
+	roles := make(map[string]interface{})
+	for _, b := range policy.Bindings {
+		if b.Condition != nil {
+			continue
+		}
+		var members []interface{}
+		if existing, ok := roles[b.Role].([]interface{}); ok {
+			members = existing
+		}
+		for _, m := range b.Members {
+			members = append(members, m)
+		}
+		roles[b.Role] = members
+	}
+	return map[string]interface{}{"bindings": roles}
+}
+
+// applyBindingsDocument replaces the unconditional bindings of policy with
+// those described by doc, in the format returned by bindingsDocument. doc
+// must be an object with a "bindings" object, so that a patch can't remove
+// every binding by replacing the document or its "bindings" with null or
+// another type.
+func applyBindingsDocument(policy *Policy, doc interface{}) error {
+	d, ok := doc.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("patched document must be an object, got %v", doc)
+	}
+	roles, ok := d["bindings"].(map[string]interface{})
+	if !ok {
+		return fmt.Errorf(`"bindings" must be an object mapping roles to members, got %v`, d["bindings"])
+	}
+
+	var bindings []*Binding
+	for _, b := range policy.Bindings {
+		if b.Condition != nil {
+			bindings = append(bindings, b)
+		}
+	}
+	var names []string
+	for role := range roles {
+		names = append(names, role)
+	}
+	sort.Strings(names)
+	for _, role := range names {
+		list, ok := roles[role].([]interface{})
+		if !ok {
+			return fmt.Errorf("members of %s must be a list", role)
+		}
+		b := &Binding{Role: role}
+		for _, v := range list {
+			m, ok := v.(string)
+			if !ok {
+				return fmt.Errorf("member of %s must be a string, got %v", role, v)
+			}
+			b.Members = append(b.Members, m)
+		}
+		bindings = append(bindings, b)
+	}
+	policy.Bindings = bindings
+	return nil
+}
+
+// ApplyJSONPatch applies an RFC 7386 JSON merge patch to the unconditional
+// bindings of projectID and writes the result. The patch is merged into a
+// document that maps each role to its members, so it can change one role
+// without restating the others. For example,
+//
+//	{"bindings": {"roles/viewer": ["user:alice@example.com"], "roles/editor": null}}
+//
+// sets the members of roles/viewer and removes the roles/editor binding.
+// Conditional bindings are left unchanged. The patched policy is normalized
+// and validated before it is written. It returns the resulting policy.
+func ApplyJSONPatch(ctx context.Context, svc *PolicyManager, projectID string, patch []byte) (*Policy, error) {
+	var p interface{}
+	if err := json.Unmarshal(patch, &p); err != nil {
+		return nil, fmt.Errorf("invalid merge patch: %v", err)
+	}
+	policy, _, err := svc.modifyPolicy(ctx, projectID, func(policy *Policy) error {
+		if err := applyBindingsDocument(policy, mergePatch(bindingsDocument(policy), p)); err != nil {
+			return fmt.Errorf("invalid merge patch: %v", err)
+		}
+		NormalizePolicy(policy)
+		if problems := validatePolicy(policy); len(problems) > 0 {
+			return &ValidationError{Problems: problems}
+		}
+		return nil
+	})
+	return policy, err
+}
@@ -0,0 +1,83 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestApplyJSONPatch(t *testing.T) {
+	ctx := context.Background()
+	target := newFakeTarget()
+	target.put("my-project", &Policy{Bindings: []*Binding{
+		{Role: "roles/viewer", Members: []string{"user:bob@example.com"}},
+		{Role: "roles/editor", Members: []string{"user:carol@example.com"}},
+	}})
+	m := newTestManager(t, target)
+
+	patch := []byte(`{"bindings": {"roles/logging.logWriter": ["user:alice@example.com"], "roles/editor": null}}`)
+	policy, err := ApplyJSONPatch(ctx, m, "my-project", patch)
+	if err != nil {
+		t.Fatalf("ApplyJSONPatch: %v", err)
+	}
+
+	if diff := cmp.Diff([]string{"roles/logging.logWriter", "roles/viewer"}, policyRoles(policy)); diff != "" {
+		t.Errorf("roles after patch: got diff (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"user:alice@example.com"}, policyMembers(policy, "roles/logging.logWriter")); diff != "" {
+		t.Errorf("roles/logging.logWriter members: got diff (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"user:bob@example.com"}, policyMembers(policy, "roles/viewer")); diff != "" {
+		t.Errorf("roles/viewer members: got diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestApplyJSONPatchInvalid(t *testing.T) {
+	ctx := context.Background()
+	target := newFakeTarget()
+	target.put("my-project", &Policy{})
+	m := newTestManager(t, target)
+
+	patch := []byte(`{"bindings": {"roles/viewer": ["alice@example.com"]}}`)
+	if _, err := ApplyJSONPatch(ctx, m, "my-project", patch); err == nil {
+		t.Errorf("ApplyJSONPatch: got no error for an invalid member, want error")
+	}
+	if target.sets != 0 {
+		t.Errorf("ApplyJSONPatch: got %d SetPolicy calls, want 0", target.sets)
+	}
+}
+
+func TestApplyJSONPatchRejectsNonObject(t *testing.T) {
+	ctx := context.Background()
+	bindings := []*Binding{{Role: "roles/owner", Members: []string{"user:alice@example.com"}}}
+	for _, patch := range []string{`null`, `[]`, `42`, `"bindings"`, `{"bindings": null}`, `{"bindings": []}`, `{"bindings": 42}`} {
+		target := newFakeTarget()
+		target.put("my-project", &Policy{Bindings: bindings})
+		m := newTestManager(t, target)
+
+		if _, err := ApplyJSONPatch(ctx, m, "my-project", []byte(patch)); err == nil {
+			t.Errorf("ApplyJSONPatch(%s): got nil error, want error", patch)
+		}
+		if target.sets != 0 {
+			t.Errorf("ApplyJSONPatch(%s): got %d SetPolicy calls, want 0", patch, target.sets)
+		}
+		if diff := cmp.Diff(bindings, target.policy("my-project").Bindings); diff != "" {
+			t.Errorf("ApplyJSONPatch(%s): got diff (-want +got):\n%s", patch, diff)
+		}
+	}
+}
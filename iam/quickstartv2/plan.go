@@ -0,0 +1,132 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ErrPlanStale is returned by ApplyPlan when the policy has changed since
+// the plan was made.
+var ErrPlanStale = errors.New("policy changed since the plan was made")
+
+// Plan is a reviewed set of changes to a policy, tied to the version of the
+// policy it was computed from.
+type Plan struct {
+	Project string   `json:"project"`
+	Etag    string   `json:"etag"`
+	Changes []Change `json:"changes"`
+}
+
+// PlanJSON computes the changes that would turn the current policy of
+// projectID into desired, and returns them as a JSON Plan together with the
+// etag of the current policy. Nothing is written; pass the plan to ApplyPlan
+// once it has been approved.
+func PlanJSON(ctx context.Context, svc *PolicyManager, projectID string, desired *Policy) ([]byte, error) {
+	projectID = resourceOrDefault(ctx, projectID)
+	current, err := svc.GetPolicy(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	plan := Plan{
+		Project: projectID,
+		Etag:    current.Etag,
+		Changes: diffPolicies(current, desired),
+	}
+	data, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("json.MarshalIndent: %v", err)
+	}
+	return data, nil
+}
+
+// ApplyPlan applies a plan made by PlanJSON. It fails with ErrPlanStale,
+// writing nothing, if the policy's etag no longer matches the plan's.
+func ApplyPlan(ctx context.Context, svc *PolicyManager, planJSON []byte) error {
+	var plan Plan
+	if err := json.Unmarshal(planJSON, &plan); err != nil {
+		return fmt.Errorf("json.Unmarshal: %v", err)
+	}
+	if plan.Project == "" {
+		return errors.New("plan has no project")
+	}
+	if err := ValidateChangeSet(ChangeSet{Project: plan.Project, Changes: plan.Changes}); err != nil {
+		return err
+	}
+	_, _, err := svc.modifyPolicy(ctx, plan.Project, func(policy *Policy) error {
+		if policy.Etag != plan.Etag {
+			return ErrPlanStale
+		}
+		for _, c := range plan.Changes {
+			applyChange(policy, c)
+		}
+		return nil
+	})
+	return err
+}
+
+// PlanHierarchy computes, without writing anything, the changes that would
+// replace the bindings of each resource in desiredPerResource with the given
+// bindings. Every resource must be rootResource or one of its descendants;
+// the hierarchy is walked from rootResource down to check this. The result
+// holds a change set, possibly empty, for every resource in
+// desiredPerResource.
+func PlanHierarchy(ctx context.Context, svc *PolicyManager, rootResource string, desiredPerResource map[string][]*Binding) (map[string]ChangeSet, error) {
+	d, err := svc.descendants()
+	if err != nil {
+		return nil, err
+	}
+
+	plans := make(map[string]ChangeSet)
+	queue := []string{rootResource}
+	for len(queue) > 0 && len(plans) < len(desiredPerResource) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		resource := queue[0]
+		queue = queue[1:]
+		if desired, ok := desiredPerResource[resource]; ok {
+			current, err := svc.GetPolicy(ctx, resource)
+			if err != nil {
+				return nil, err
+			}
+			want := copyPolicy(current)
+			want.Bindings = desired
+			plans[resource] = ChangeSet{Project: resource, Changes: diffPolicies(current, want)}
+		}
+		children, err := d.Children(ctx, resource)
+		if err != nil {
+			return nil, err
+		}
+		queue = append(queue, children...)
+	}
+
+	var missing []string
+	for resource := range desiredPerResource {
+		if _, ok := plans[resource]; !ok {
+			missing = append(missing, resource)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return nil, fmt.Errorf("not under %s: %s", rootResource, strings.Join(missing, ", "))
+	}
+	return plans, nil
+}
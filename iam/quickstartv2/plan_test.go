@@ -0,0 +1,123 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestPlanJSONApplyPlan(t *testing.T) {
+	ctx := context.Background()
+	target := newFakeTarget()
+	target.put("my-project", &Policy{Bindings: []*Binding{
+		{Role: "roles/viewer", Members: []string{"user:bob@example.com"}},
+	}})
+	m := newTestManager(t, target)
+
+	desired := &Policy{Bindings: []*Binding{
+		{Role: "roles/viewer", Members: []string{"user:alice@example.com"}},
+	}}
+	plan, err := PlanJSON(ctx, m, "my-project", desired)
+	if err != nil {
+		t.Fatalf("PlanJSON: %v", err)
+	}
+	if target.sets != 0 {
+		t.Errorf("PlanJSON: got %d SetPolicy calls, want 0", target.sets)
+	}
+
+	if err := ApplyPlan(ctx, m, plan); err != nil {
+		t.Fatalf("ApplyPlan: %v", err)
+	}
+	got := target.policy("my-project").Bindings
+	want := []*Binding{{Role: "roles/viewer", Members: []string{"user:alice@example.com"}}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("ApplyPlan: got diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestApplyPlanStale(t *testing.T) {
+	ctx := context.Background()
+	target := newFakeTarget()
+	target.put("my-project", &Policy{})
+	m := newTestManager(t, target)
+
+	desired := &Policy{Bindings: []*Binding{
+		{Role: "roles/viewer", Members: []string{"user:alice@example.com"}},
+	}}
+	plan, err := PlanJSON(ctx, m, "my-project", desired)
+	if err != nil {
+		t.Fatalf("PlanJSON: %v", err)
+	}
+	if _, err := m.AddBinding(ctx, "my-project", "user:mallory@example.com", "roles/owner"); err != nil {
+		t.Fatalf("AddBinding: %v", err)
+	}
+
+	if err := ApplyPlan(ctx, m, plan); !errors.Is(err, ErrPlanStale) {
+		t.Errorf("ApplyPlan: got error %v, want ErrPlanStale", err)
+	}
+	if got := target.sets; got != 1 {
+		t.Errorf("got %d SetPolicy calls, want 1 (only AddBinding)", got)
+	}
+}
+
+func TestPlanHierarchy(t *testing.T) {
+	ctx := context.Background()
+	target := newFakeTarget()
+	target.children = map[string][]string{
+		"organizations/1": {"folders/2", "projects/a"},
+		"folders/2":       {"projects/b"},
+	}
+	viewer := &Binding{Role: "roles/viewer", Members: []string{"user:alice@example.com"}}
+	target.put("organizations/1", &Policy{})
+	target.put("folders/2", &Policy{Bindings: []*Binding{viewer}})
+	target.put("projects/a", &Policy{})
+	target.put("projects/b", &Policy{Bindings: []*Binding{viewer}})
+	m := newTestManager(t, target)
+
+	got, err := PlanHierarchy(ctx, m, "organizations/1", map[string][]*Binding{
+		"folders/2":  {viewer},
+		"projects/b": {{Role: "roles/editor", Members: []string{"user:alice@example.com"}}},
+	})
+	if err != nil {
+		t.Fatalf("PlanHierarchy: %v", err)
+	}
+	want := map[string]ChangeSet{
+		"folders/2": {Project: "folders/2"},
+		"projects/b": {Project: "projects/b", Changes: []Change{
+			{Op: OpAdd, Role: "roles/editor", Member: "user:alice@example.com"},
+			{Op: OpRemove, Role: "roles/viewer", Member: "user:alice@example.com"},
+		}},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("PlanHierarchy: got diff (-want +got):\n%s", diff)
+	}
+	if target.sets != 0 {
+		t.Errorf("PlanHierarchy: got %d SetPolicy calls, want 0", target.sets)
+	}
+
+	if _, err := PlanHierarchy(ctx, m, "folders/2", map[string][]*Binding{"projects/a": nil}); err == nil {
+		t.Errorf("PlanHierarchy(projects/a outside folders/2): got nil error, want error")
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := PlanHierarchy(cancelled, m, "organizations/1", map[string][]*Binding{"projects/a": nil}); !errors.Is(err, context.Canceled) {
+		t.Errorf("PlanHierarchy with cancelled context: got %v, want context.Canceled", err)
+	}
+}
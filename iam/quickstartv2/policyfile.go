@@ -0,0 +1,127 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// ValidationError lists every problem found while validating a policy.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%d problem(s): %s", len(e.Problems), strings.Join(e.Problems, "; "))
+}
+
+// LoadPolicyFile reads a policy, as written by
+// "gcloud projects get-iam-policy", from path. Files named *.yaml or *.yml
+// are read as gcloud's default YAML output and others as its
+// --format=json output.
+func LoadPolicyFile(path string) (*Policy, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("ioutil.ReadFile: %v", err)
+	}
+	if ext := filepath.Ext(path); ext == ".yaml" || ext == ".yml" {
+		policy, err := UnmarshalPolicyYAML(data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid policy file %s: %v", path, err)
+		}
+		return policy, nil
+	}
+	policy := &Policy{}
+	if err := json.Unmarshal(data, policy); err != nil {
+		return nil, fmt.Errorf("invalid policy file %s: %v", path, err)
+	}
+	return policy, nil
+}
+
+// validatePolicy checks the roles and members of every binding in policy.
+func validatePolicy(policy *Policy) []string {
+	var problems []string
+	for i, b := range policy.Bindings {
+		if err := ValidateRole(b.Role); err != nil {
+			problems = append(problems, fmt.Sprintf("binding %d: %v", i, err))
+		}
+		if len(b.Members) == 0 {
+			problems = append(problems, fmt.Sprintf("binding %d (%s): no members", i, b.Role))
+		}
+		for _, m := range b.Members {
+			if err := ValidateMember(m); err != nil {
+				problems = append(problems, fmt.Sprintf("binding %d (%s): %v", i, b.Role, err))
+			}
+		}
+	}
+	return problems
+}
+
+// ValidatePolicyFile checks, without any API calls, that the policy file at
+// path parses and that all of its roles and members are well formed. All
+// problems are reported together in a *ValidationError.
+func ValidatePolicyFile(path string) error {
+	policy, err := LoadPolicyFile(path)
+	if err != nil {
+		return err
+	}
+	if problems := validatePolicy(policy); len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// ValidatePolicyFileOnline runs ValidatePolicyFile and also checks that every
+// role the file references exists, using the manager's role service. All
+// problems, including every unknown role, are reported together in a
+// *ValidationError. If the role service can list roles, predefined roles
+// are checked against LoadPredefinedRoles instead of one at a time.
+func (m *PolicyManager) ValidatePolicyFileOnline(ctx context.Context, path string) error {
+	policy, err := LoadPolicyFile(path)
+	if err != nil {
+		return err
+	}
+	problems := validatePolicy(policy)
+	predefined := m.predefinedRoleSet(ctx)
+	for _, role := range policyRoles(policy) {
+		if ValidateRole(role) != nil {
+			continue // Already reported.
+		}
+		if predefined != nil && strings.HasPrefix(role, "roles/") {
+			if !predefined[role] {
+				problems = append(problems, fmt.Sprintf("unknown role %q", role))
+			}
+			continue
+		}
+		_, err := m.lookupRole(ctx, role)
+		if errors.Is(err, ErrRoleNotFound) {
+			problems = append(problems, fmt.Sprintf("unknown role %q", role))
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"google.golang.org/api/iam/v1"
+	"google.golang.org/api/option"
+)
+
+// writeTempFile writes content to a new file named name in a temporary
+// directory that is removed when the test ends.
+func writeTempFile(t *testing.T, name, content string) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "policyfile")
+	if err != nil {
+		t.Fatalf("ioutil.TempDir: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("ioutil.WriteFile: %v", err)
+	}
+	return path
+}
+
+const bogusRolePolicy = `{
+  "bindings": [
+    {"role": "roles/viewer", "members": ["user:alice@example.com"]},
+    {"role": "roles/veiwer", "members": ["user:bob@example.com"]},
+    {"role": "roles/logging.logWritter", "members": ["user:bob@example.com"]}
+  ]
+}`
+
+func TestValidatePolicyFile(t *testing.T) {
+	path := writeTempFile(t, "policy.json", `{
+  "bindings": [
+    {"role": "viewer", "members": ["alice@example.com"]}
+  ]
+}`)
+	err := ValidatePolicyFile(path)
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("ValidatePolicyFile: got %v, want *ValidationError", err)
+	}
+	if len(verr.Problems) != 2 {
+		t.Errorf("ValidatePolicyFile: got problems %q, want a bad role and a bad member", verr.Problems)
+	}
+
+	if err := ValidatePolicyFile(writeTempFile(t, "policy.json", bogusRolePolicy)); err != nil {
+		t.Errorf("ValidatePolicyFile: got %v, want no error for well-formed roles", err)
+	}
+}
+
+func TestValidatePolicyFileOnline(t *testing.T) {
+	ctx := context.Background()
+	path := writeTempFile(t, "policy.json", bogusRolePolicy)
+	roles := newFakeRoles(map[string][]string{"roles/viewer": nil})
+	m := newTestManager(t, newFakeTarget(), WithRoleService(roles))
+
+	err := m.ValidatePolicyFileOnline(ctx, path)
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("ValidatePolicyFileOnline: got %v, want *ValidationError", err)
+	}
+	if len(verr.Problems) != 2 || !strings.Contains(err.Error(), "roles/veiwer") || !strings.Contains(err.Error(), "roles/logging.logWritter") {
+		t.Errorf("ValidatePolicyFileOnline: got %v, want both unknown roles reported", err)
+	}
+
+	// A second run is served from the cache.
+	calls := roles.calls
+	m.ValidatePolicyFileOnline(ctx, path)
+	if roles.calls != calls {
+		t.Errorf("second ValidatePolicyFileOnline: got %d more role lookups, want 0", roles.calls-calls)
+	}
+}
+
+func TestIAMRoleServiceNotFound(t *testing.T) {
+	ctx := context.Background()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/roles/viewer") {
+			w.Write([]byte(`{"name": "roles/viewer"}`))
+			return
+		}
+		http.Error(w, `{"error": {"code": 404, "message": "role not found"}}`, http.StatusNotFound)
+	}))
+	defer ts.Close()
+	iamService, err := iam.NewService(ctx, option.WithEndpoint(ts.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatalf("iam.NewService: %v", err)
+	}
+	rs := NewIAMRoleService(iamService)
+
+	if _, err := rs.GetRole(ctx, "roles/viewer"); err != nil {
+		t.Errorf("GetRole(roles/viewer): %v", err)
+	}
+	if _, err := rs.GetRole(ctx, "roles/bogus"); !errors.Is(err, ErrRoleNotFound) {
+		t.Errorf("GetRole(roles/bogus): got %v, want ErrRoleNotFound", err)
+	}
+}
@@ -0,0 +1,195 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"google.golang.org/api/iam/v1"
+)
+
+// Role is a predefined role, as listed by the IAM API.
+type Role struct {
+	Name        string `json:"name"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	Stage       string `json:"stage,omitempty"`
+	Deleted     bool   `json:"deleted,omitempty"`
+	// Permissions are the permissions the role grants.
+	Permissions []string `json:"permissions,omitempty"`
+}
+
+// ErrStaleRoles is returned by LoadPredefinedRoles, together with the
+// cached roles, when listing the roles failed and an expired cache was used
+// instead.
+var ErrStaleRoles = errors.New("using stale predefined roles")
+
+// RoleLister is implemented by role services that can list every
+// predefined role.
+type RoleLister interface {
+	ListRoles(ctx context.Context) ([]*iam.Role, error)
+}
+
+// ListRoles lists every predefined role with its permissions.
+func (s *iamRoleService) ListRoles(ctx context.Context) ([]*iam.Role, error) {
+	var roles []*iam.Role
+	// The default BASIC view leaves out the included permissions.
+	err := s.svc.Roles.List().View("FULL").Pages(ctx, func(resp *iam.ListRolesResponse) error {
+		roles = append(roles, resp.Roles...)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Roles.List: %w", err)
+	}
+	return roles, nil
+}
+
+// WithPredefinedRolesCache makes LoadPredefinedRoles keep the list of
+// predefined roles in a JSON file at path and reuse it for ttl before
+// listing the roles again.
+func WithPredefinedRolesCache(path string, ttl time.Duration) Option {
+	return func(m *PolicyManager) error {
+		m.rolesCachePath = path
+		m.rolesCacheTTL = ttl
+		return nil
+	}
+}
+
+// predefinedRolesFile is the format of the predefined roles cache.
+type predefinedRolesFile struct {
+	Fetched time.Time `json:"fetched"`
+	Roles   []Role    `json:"roles"`
+}
+
+// LoadPredefinedRoles returns every predefined role, sorted by name, listed
+// with the manager's role service. With WithPredefinedRolesCache the list
+// is read from the cache file while it is fresh, and the file is rewritten
+// after each listing; if the listing fails, the stale cached roles are
+// returned with an error wrapping ErrStaleRoles, so validation keeps working
+// offline after one successful fetch.
+func (m *PolicyManager) LoadPredefinedRoles(ctx context.Context) ([]Role, error) {
+	var cached *predefinedRolesFile
+	if m.rolesCachePath != "" {
+		var err error
+		if cached, err = readPredefinedRoles(m.rolesCachePath); err != nil {
+			return nil, err
+		}
+		if cached != nil && m.now().Sub(cached.Fetched) < m.rolesCacheTTL {
+			return cached.Roles, nil
+		}
+	}
+
+	roles, err := m.listPredefinedRoles(ctx)
+	if err != nil {
+		if cached != nil {
+			return cached.Roles, fmt.Errorf("listing predefined roles: %v: %w cached at %s", err, ErrStaleRoles, cached.Fetched.Format(time.RFC3339))
+		}
+		return nil, err
+	}
+	if m.rolesCachePath != "" {
+		data, err := json.MarshalIndent(predefinedRolesFile{Fetched: m.now(), Roles: roles}, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("json.MarshalIndent: %v", err)
+		}
+		if err := writeFileAtomic(m.rolesCachePath, data); err != nil {
+			return nil, err
+		}
+	}
+	return roles, nil
+}
+
+// listPredefinedRoles lists the predefined roles with the role service.
+func (m *PolicyManager) listPredefinedRoles(ctx context.Context) ([]Role, error) {
+	if m.roles == nil {
+		return nil, errors.New("no role service configured, see WithRoleService")
+	}
+	lister, ok := m.roles.svc.(RoleLister)
+	if !ok {
+		return nil, errors.New("role service cannot list roles")
+	}
+	listed, err := lister.ListRoles(ctx)
+	if err != nil {
+		return nil, err
+	}
+	roles := make([]Role, 0, len(listed))
+	for _, r := range listed {
+		roles = append(roles, Role{Name: r.Name, Title: r.Title, Description: r.Description, Stage: r.Stage, Deleted: r.Deleted, Permissions: r.IncludedPermissions})
+	}
+	sort.Slice(roles, func(i, j int) bool { return roles[i].Name < roles[j].Name })
+	return roles, nil
+}
+
+// readPredefinedRoles reads the cache at path, or returns nil if there is
+// none.
+func readPredefinedRoles(path string) (*predefinedRolesFile, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ioutil.ReadFile: %v", err)
+	}
+	f := &predefinedRolesFile{}
+	if err := json.Unmarshal(data, f); err != nil {
+		return nil, fmt.Errorf("invalid predefined roles cache %s: %v", path, err)
+	}
+	return f, nil
+}
+
+// ResolveRole returns the full name of a predefined role given by its full
+// name, such as "roles/storage.admin", or its short name, such as
+// "storage.admin". It fails with ErrRoleNotFound if no predefined role has
+// that name. Roles are looked up with LoadPredefinedRoles.
+func (m *PolicyManager) ResolveRole(ctx context.Context, name string) (string, error) {
+	roles, err := m.LoadPredefinedRoles(ctx)
+	if err != nil && !errors.Is(err, ErrStaleRoles) {
+		return "", err
+	}
+	full := "roles/" + strings.TrimPrefix(name, "roles/")
+	i := sort.Search(len(roles), func(i int) bool { return roles[i].Name >= full })
+	if i == len(roles) || roles[i].Name != full {
+		return "", fmt.Errorf("%s: %w", name, ErrRoleNotFound)
+	}
+	return full, nil
+}
+
+// predefinedRoleSet returns the names of the predefined roles, or nil if
+// they can't be listed, in which case callers fall back to looking up
+// roles one at a time.
+func (m *PolicyManager) predefinedRoleSet(ctx context.Context) map[string]bool {
+	if m.roles == nil {
+		return nil
+	}
+	if _, ok := m.roles.svc.(RoleLister); !ok {
+		return nil
+	}
+	roles, err := m.LoadPredefinedRoles(ctx)
+	if err != nil && !errors.Is(err, ErrStaleRoles) {
+		return nil
+	}
+	set := make(map[string]bool, len(roles))
+	for _, r := range roles {
+		set[r.Name] = true
+	}
+	return set
+}
@@ -0,0 +1,153 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/api/iam/v1"
+	"google.golang.org/api/option"
+)
+
+// fakeRoleLister is a fakeRoles that can also list its roles.
+type fakeRoleLister struct {
+	*fakeRoles
+	lists int
+	err   error
+}
+
+func (f *fakeRoleLister) ListRoles(ctx context.Context) ([]*iam.Role, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.lists++
+	if f.err != nil {
+		return nil, f.err
+	}
+	var roles []*iam.Role
+	for _, r := range f.roles {
+		roles = append(roles, r)
+	}
+	sort.Slice(roles, func(i, j int) bool { return roles[i].Name > roles[j].Name })
+	return roles, nil
+}
+
+func TestLoadPredefinedRoles(t *testing.T) {
+	ctx := context.Background()
+	rs := &fakeRoleLister{fakeRoles: newFakeRoles(map[string][]string{
+		"roles/viewer":        nil,
+		"roles/storage.admin": nil,
+	})}
+	path := filepath.Join(t.TempDir(), "roles.json")
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	newManager := func() *PolicyManager {
+		m := newTestManager(t, newFakeTarget(), WithRoleService(rs), WithPredefinedRolesCache(path, time.Hour))
+		m.now = func() time.Time { return now }
+		return m
+	}
+
+	got, err := newManager().LoadPredefinedRoles(ctx)
+	if err != nil {
+		t.Fatalf("LoadPredefinedRoles: %v", err)
+	}
+	want := []Role{{Name: "roles/storage.admin"}, {Name: "roles/viewer"}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("LoadPredefinedRoles: got diff (-want +got):\n%s", diff)
+	}
+
+	// A second manager reads the fresh cache file, even offline.
+	rs.err = errors.New("offline")
+	m := newManager()
+	if got, err := m.LoadPredefinedRoles(ctx); err != nil || len(got) != 2 {
+		t.Errorf("LoadPredefinedRoles(cached): got (%v, %v), want the two cached roles", got, err)
+	}
+	if rs.lists != 1 {
+		t.Errorf("LoadPredefinedRoles(cached): got %d list calls, want 1", rs.lists)
+	}
+	if got, err := m.ResolveRole(ctx, "storage.admin"); err != nil || got != "roles/storage.admin" {
+		t.Errorf("ResolveRole(storage.admin): got (%q, %v), want roles/storage.admin", got, err)
+	}
+	if _, err := m.ResolveRole(ctx, "storage.owner"); !errors.Is(err, ErrRoleNotFound) {
+		t.Errorf("ResolveRole(storage.owner): got %v, want ErrRoleNotFound", err)
+	}
+
+	// Once stale, the roles are listed again, falling back to the stale
+	// cache if that fails.
+	now = now.Add(2 * time.Hour)
+	if got, err := m.LoadPredefinedRoles(ctx); !errors.Is(err, ErrStaleRoles) || len(got) != 2 {
+		t.Errorf("LoadPredefinedRoles(stale, offline): got (%v, %v), want the two cached roles and ErrStaleRoles", got, err)
+	}
+	if got, err := m.ResolveRole(ctx, "viewer"); err != nil || got != "roles/viewer" {
+		t.Errorf("ResolveRole(stale, offline): got (%q, %v), want roles/viewer", got, err)
+	}
+	rs.err = nil
+	if _, err := m.LoadPredefinedRoles(ctx); err != nil {
+		t.Fatalf("LoadPredefinedRoles(stale): %v", err)
+	}
+	if rs.lists != 4 {
+		t.Errorf("LoadPredefinedRoles(stale): got %d list calls, want 4", rs.lists)
+	}
+	cached, err := readPredefinedRoles(path)
+	if err != nil {
+		t.Fatalf("readPredefinedRoles: %v", err)
+	}
+	if !cached.Fetched.Equal(now) {
+		t.Errorf("cache: got fetch time %v, want %v", cached.Fetched, now)
+	}
+
+	policyPath := writeTempFile(t, "policy.json", `{"bindings": [
+		{"role": "roles/viewer", "members": ["user:alice@example.com"]},
+		{"role": "roles/bogus", "members": ["user:bob@example.com"]}
+	]}`)
+	var verr *ValidationError
+	if err := m.ValidatePolicyFileOnline(ctx, policyPath); !errors.As(err, &verr) || len(verr.Problems) != 1 {
+		t.Errorf("ValidatePolicyFileOnline: got %v, want one unknown role", err)
+	}
+	if rs.calls != 0 {
+		t.Errorf("ValidatePolicyFileOnline: got %d GetRole calls, want 0", rs.calls)
+	}
+}
+
+func TestIAMRoleServiceListRoles(t *testing.T) {
+	ctx := context.Background()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.URL.Query().Get("view"); got != "FULL" {
+			t.Errorf("Roles.List: got view %q, want FULL", got)
+		}
+		w.Write([]byte(`{"roles": [{"name": "roles/viewer", "includedPermissions": ["resourcemanager.projects.get"]}]}`))
+	}))
+	defer ts.Close()
+	iamService, err := iam.NewService(ctx, option.WithEndpoint(ts.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatalf("iam.NewService: %v", err)
+	}
+	m := newTestManager(t, newFakeTarget(), WithRoleService(NewIAMRoleService(iamService)))
+
+	got, err := m.LoadPredefinedRoles(ctx)
+	if err != nil {
+		t.Fatalf("LoadPredefinedRoles: %v", err)
+	}
+	want := []Role{{Name: "roles/viewer", Permissions: []string{"resourcemanager.projects.get"}}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("LoadPredefinedRoles: got diff (-want +got):\n%s", diff)
+	}
+}
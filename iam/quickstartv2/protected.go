@@ -0,0 +1,71 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrProtectedMember is returned when a change would revoke a role from a
+// member protected with WithProtectedMembers.
+var ErrProtectedMember = errors.New("member is protected")
+
+// overrideProtectionKey is the context key for overriding member
+// protection.
+type overrideProtectionKey struct{}
+
+// WithProtectedMembers protects members, such as break-glass accounts that
+// must always keep their emergency access, from having any role revoked.
+// Every write that would remove a grant from one of them fails with
+// ErrProtectedMember, unless its context is from OverrideProtection.
+func WithProtectedMembers(members ...string) Option {
+	return func(m *PolicyManager) error {
+		if m.protected == nil {
+			m.protected = make(map[string]bool)
+		}
+		for _, member := range members {
+			if err := ValidateMember(member); err != nil {
+				return err
+			}
+			m.protected[member] = true
+		}
+		return nil
+	}
+}
+
+// OverrideProtection returns a copy of ctx with which changes may revoke
+// roles from protected members.
+func OverrideProtection(ctx context.Context) context.Context {
+	return context.WithValue(ctx, overrideProtectionKey{}, true)
+}
+
+// checkProtected returns an error wrapping ErrProtectedMember if cs removes
+// a grant from a protected member and ctx doesn't override protection.
+func (m *PolicyManager) checkProtected(ctx context.Context, cs ChangeSet) error {
+	if len(m.protected) == 0 {
+		return nil
+	}
+	if override, _ := ctx.Value(overrideProtectionKey{}).(bool); override {
+		return nil
+	}
+	for _, c := range cs.Changes {
+		if c.Op == OpRemove && m.protected[c.Member] {
+			return fmt.Errorf("revoking %s from %s: %w", c.Role, c.Member, ErrProtectedMember)
+		}
+	}
+	return nil
+}
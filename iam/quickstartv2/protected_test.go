@@ -0,0 +1,54 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestWithProtectedMembers(t *testing.T) {
+	ctx := context.Background()
+	target := newFakeTarget()
+	target.put("my-project", &Policy{Bindings: []*Binding{
+		{Role: "roles/owner", Members: []string{"user:breakglass@example.com", "user:alice@example.com"}},
+	}})
+	m := newTestManager(t, target, WithProtectedMembers("user:breakglass@example.com"))
+
+	if _, err := m.RemoveMember(ctx, "my-project", "user:breakglass@example.com", "roles/owner"); !errors.Is(err, ErrProtectedMember) {
+		t.Errorf("RemoveMember(protected): got %v, want ErrProtectedMember", err)
+	}
+	if _, err := m.RemoveBinding(ctx, "my-project", "roles/owner"); !errors.Is(err, ErrProtectedMember) {
+		t.Errorf("RemoveBinding: got %v, want ErrProtectedMember", err)
+	}
+	if target.sets != 0 {
+		t.Errorf("got %d SetPolicy calls, want 0", target.sets)
+	}
+	if _, err := m.RemoveMember(ctx, "my-project", "user:alice@example.com", "roles/owner"); err != nil {
+		t.Errorf("RemoveMember(unprotected): %v", err)
+	}
+
+	if _, err := m.RemoveMember(OverrideProtection(ctx), "my-project", "user:breakglass@example.com", "roles/owner"); err != nil {
+		t.Fatalf("RemoveMember(override): %v", err)
+	}
+	if got := target.policy("my-project").Bindings; len(got) != 0 {
+		t.Errorf("RemoveMember(override): got bindings %v, want none", got)
+	}
+
+	if _, err := NewPolicyManager(target, WithProtectedMembers("breakglass@example.com")); err == nil {
+		t.Errorf("WithProtectedMembers(invalid member): got nil error, want error")
+	}
+}
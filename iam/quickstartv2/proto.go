@@ -0,0 +1,78 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import "google.golang.org/api/cloudresourcemanager/v1"
+
+// ProtoBinding is a policy binding without the REST API types or their JSON
+// encoding, for mapping to the messages of a caller's own gRPC API.
+type ProtoBinding struct {
+	Role    string
+	Members []string
+	// Condition is nil for an unconditional binding.
+	Condition *ProtoCondition
+}
+
+// ProtoCondition is the condition of a ProtoBinding.
+type ProtoCondition struct {
+	Title       string
+	Description string
+	Expression  string
+	Location    string
+}
+
+// ToProtoBindings converts the bindings of policy to ProtoBindings. The
+// results share nothing with policy.
+func ToProtoBindings(policy *Policy) []*ProtoBinding {
+	var out []*ProtoBinding
+	for _, b := range policy.Bindings {
+		pb := &ProtoBinding{
+			Role:    b.Role,
+			Members: append([]string(nil), b.Members...),
+		}
+		if c := b.Condition; c != nil {
+			pb.Condition = &ProtoCondition{
+				Title:       c.Title,
+				Description: c.Description,
+				Expression:  c.Expression,
+				Location:    c.Location,
+			}
+		}
+		out = append(out, pb)
+	}
+	return out
+}
+
+// FromProtoBindings converts bindings back to the REST API type, the
+// inverse of ToProtoBindings.
+func FromProtoBindings(bindings []*ProtoBinding) []*Binding {
+	var out []*Binding
+	for _, pb := range bindings {
+		b := &Binding{
+			Role:    pb.Role,
+			Members: append([]string(nil), pb.Members...),
+		}
+		if c := pb.Condition; c != nil {
+			b.Condition = &cloudresourcemanager.Expr{
+				Title:       c.Title,
+				Description: c.Description,
+				Expression:  c.Expression,
+				Location:    c.Location,
+			}
+		}
+		out = append(out, b)
+	}
+	return out
+}
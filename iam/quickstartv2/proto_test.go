@@ -0,0 +1,52 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/api/cloudresourcemanager/v1"
+)
+
+func TestProtoBindingsRoundTrip(t *testing.T) {
+	policy := &Policy{Bindings: []*Binding{
+		{Role: "roles/viewer", Members: []string{"user:alice@example.com", "group:eng@example.com"}},
+		{
+			Role:    "roles/storage.admin",
+			Members: []string{"user:bob@example.com"},
+			Condition: &cloudresourcemanager.Expr{
+				Title:       "temporary-access",
+				Description: "Until the end of the year",
+				Expression:  `request.time < timestamp("2021-01-01T00:00:00Z")`,
+			},
+		},
+	}}
+
+	pbs := ToProtoBindings(policy)
+	if got := pbs[1].Condition; got == nil || got.Title != "temporary-access" {
+		t.Errorf("ToProtoBindings: got condition %+v, want title temporary-access", got)
+	}
+	pbs[0].Members[0] = "user:mallory@example.com"
+	if policy.Bindings[0].Members[0] != "user:alice@example.com" {
+		t.Errorf("ToProtoBindings: result shares members with the policy")
+	}
+	pbs[0].Members[0] = "user:alice@example.com"
+
+	got := FromProtoBindings(pbs)
+	if diff := cmp.Diff(policy.Bindings, got); diff != "" {
+		t.Errorf("FromProtoBindings(ToProtoBindings): got diff (-want +got):\n%s", diff)
+	}
+}
@@ -44,6 +44,14 @@ func main() {
 		log.Fatalf("cloudresourcemanager.NewService: %v", err)
 	}
 
+	// [START_EXCLUDE]
+	// Runs one of the command-line tool's other commands instead, if its
+	// flags are set. See cli.go.
+	if runCLI(ctx, crmService, *projectID, *member) {
+		return
+	}
+	// [END_EXCLUDE]
+
 	// Grants your member the "Log writer" role for your project
 	addBinding(crmService, *projectID, *member, role)
 
@@ -0,0 +1,193 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"sort"
+)
+
+// policyRoles returns the distinct roles granted in policy, sorted.
+func policyRoles(policy *Policy) []string {
+	seen := make(map[string]bool)
+	var roles []string
+	for _, b := range policy.Bindings {
+		if !seen[b.Role] {
+			seen[b.Role] = true
+			roles = append(roles, b.Role)
+		}
+	}
+	sort.Strings(roles)
+	return roles
+}
+
+// policyMembers returns the distinct members granted role in policy, with or
+// without a condition, sorted.
+func policyMembers(policy *Policy, role string) []string {
+	seen := make(map[string]bool)
+	var members []string
+	for _, b := range policy.Bindings {
+		if b.Role != role {
+			continue
+		}
+		for _, m := range b.Members {
+			if !seen[m] {
+				seen[m] = true
+				members = append(members, m)
+			}
+		}
+	}
+	sort.Strings(members)
+	return members
+}
+
+// policyHasRole reports whether member is granted role in policy, with or
+// without a condition.
+func policyHasRole(policy *Policy, member, role string) bool {
+	for _, b := range policy.Bindings {
+		if b.Role != role {
+			continue
+		}
+		for _, m := range b.Members {
+			if m == member {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// policyRolesForMember returns the distinct roles granted to member in
+// policy, sorted.
+func policyRolesForMember(policy *Policy, member string) []string {
+	seen := make(map[string]bool)
+	var roles []string
+	for _, b := range policy.Bindings {
+		for _, m := range b.Members {
+			if m == member && !seen[b.Role] {
+				seen[b.Role] = true
+				roles = append(roles, b.Role)
+			}
+		}
+	}
+	sort.Strings(roles)
+	return roles
+}
+
+// Roles returns the distinct roles granted on projectID, sorted.
+func (m *PolicyManager) Roles(ctx context.Context, projectID string) ([]string, error) {
+	policy, err := m.GetPolicy(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	return policyRoles(policy), nil
+}
+
+// Members returns the members granted role on projectID, sorted.
+func (m *PolicyManager) Members(ctx context.Context, projectID, role string) ([]string, error) {
+	policy, err := m.GetPolicy(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	return policyMembers(policy, role), nil
+}
+
+// HasRole reports whether member is granted role on projectID.
+func (m *PolicyManager) HasRole(ctx context.Context, projectID, member, role string) (bool, error) {
+	policy, err := m.GetPolicy(ctx, projectID)
+	if err != nil {
+		return false, err
+	}
+	return policyHasRole(policy, member, role), nil
+}
+
+// ListRolesForMember returns the roles granted to member on projectID, sorted.
+func (m *PolicyManager) ListRolesForMember(ctx context.Context, projectID, member string) ([]string, error) {
+	policy, err := m.GetPolicy(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	return policyRolesForMember(policy, member), nil
+}
+
+// CollectiveRoles returns the distinct roles, sorted, held by any of members
+// on projectID. The policy is fetched once.
+func CollectiveRoles(ctx context.Context, svc *PolicyManager, projectID string, members []string) ([]string, error) {
+	policy, err := svc.GetPolicy(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	var roles []string
+	for _, m := range members {
+		roles = append(roles, policyRolesForMember(policy, m)...)
+	}
+	sort.Strings(roles)
+	return dedupeStrings(roles), nil
+}
+
+// PolicySnapshot answers read queries against a policy fetched once, without
+// further API calls. It is safe for concurrent use.
+type PolicySnapshot struct {
+	project string
+	policy  *Policy
+}
+
+// Snapshot fetches the policy of projectID once for use by many queries.
+func (m *PolicyManager) Snapshot(ctx context.Context, projectID string) (*PolicySnapshot, error) {
+	policy, err := m.GetPolicy(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	return &PolicySnapshot{project: resourceOrDefault(ctx, projectID), policy: copyPolicy(policy)}, nil
+}
+
+// Project returns the project the snapshot was taken of.
+func (s *PolicySnapshot) Project() string { return s.project }
+
+// Policy returns a copy of the snapshotted policy.
+func (s *PolicySnapshot) Policy() *Policy { return copyPolicy(s.policy) }
+
+// Roles returns the distinct roles granted in the snapshot, sorted.
+func (s *PolicySnapshot) Roles() []string { return policyRoles(s.policy) }
+
+// Members returns the members granted role in the snapshot, sorted.
+func (s *PolicySnapshot) Members(role string) []string { return policyMembers(s.policy, role) }
+
+// HasRole reports whether member is granted role in the snapshot.
+func (s *PolicySnapshot) HasRole(member, role string) bool {
+	return policyHasRole(s.policy, member, role)
+}
+
+// ListRolesForMember returns the roles granted to member in the snapshot,
+// sorted.
+func (s *PolicySnapshot) ListRolesForMember(member string) []string {
+	return policyRolesForMember(s.policy, member)
+}
+
+// ListIfChanged fetches the policy of projectID and returns it with changed
+// set, unless its etag equals knownEtag, in which case it returns a nil
+// policy and changed false. GetIamPolicy has no conditional form, so the
+// policy is always fetched; the cache is bypassed so that a change is never
+// missed.
+func ListIfChanged(ctx context.Context, svc *PolicyManager, projectID, knownEtag string) (*Policy, bool, error) {
+	policy, err := svc.readPolicy(ctx, resourceOrDefault(ctx, projectID))
+	if err != nil {
+		return nil, false, err
+	}
+	if knownEtag != "" && policy.Etag == knownEtag {
+		return nil, false, nil
+	}
+	return policy, true, nil
+}
@@ -0,0 +1,118 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSnapshotSingleFetch(t *testing.T) {
+	ctx := context.Background()
+	target := newFakeTarget()
+	target.put("my-project", &Policy{Bindings: []*Binding{
+		{Role: "roles/viewer", Members: []string{"user:bob@example.com", "user:alice@example.com"}},
+		{Role: "roles/editor", Members: []string{"user:alice@example.com"}},
+	}})
+	m := newTestManager(t, target)
+
+	snap, err := m.Snapshot(ctx, "my-project")
+	if err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			snap.Roles()
+			snap.Members("roles/viewer")
+			snap.HasRole("user:alice@example.com", "roles/editor")
+			snap.ListRolesForMember("user:alice@example.com")
+		}()
+	}
+	wg.Wait()
+
+	if diff := cmp.Diff([]string{"roles/editor", "roles/viewer"}, snap.Roles()); diff != "" {
+		t.Errorf("Roles: got diff (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"user:alice@example.com", "user:bob@example.com"}, snap.Members("roles/viewer")); diff != "" {
+		t.Errorf("Members: got diff (-want +got):\n%s", diff)
+	}
+	if snap.HasRole("user:bob@example.com", "roles/editor") {
+		t.Errorf("HasRole(bob, roles/editor): got true, want false")
+	}
+	if diff := cmp.Diff([]string{"roles/editor", "roles/viewer"}, snap.ListRolesForMember("user:alice@example.com")); diff != "" {
+		t.Errorf("ListRolesForMember: got diff (-want +got):\n%s", diff)
+	}
+	if target.gets != 1 {
+		t.Errorf("got %d GetPolicy calls, want 1", target.gets)
+	}
+}
+
+func TestCollectiveRoles(t *testing.T) {
+	ctx := context.Background()
+	target := newFakeTarget()
+	target.put("my-project", &Policy{Bindings: []*Binding{
+		{Role: "roles/viewer", Members: []string{"user:alice@example.com", "user:bob@example.com"}},
+		{Role: "roles/storage.admin", Members: []string{"user:bob@example.com"}},
+		{Role: "roles/logging.viewer", Members: []string{"user:alice@example.com"}},
+		{Role: "roles/owner", Members: []string{"user:carol@example.com"}},
+	}})
+	m := newTestManager(t, target)
+
+	got, err := CollectiveRoles(ctx, m, "my-project", []string{"user:alice@example.com", "user:bob@example.com", "user:dan@example.com"})
+	if err != nil {
+		t.Fatalf("CollectiveRoles: %v", err)
+	}
+	want := []string{"roles/logging.viewer", "roles/storage.admin", "roles/viewer"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("CollectiveRoles: got diff (-want +got):\n%s", diff)
+	}
+	if target.gets != 1 {
+		t.Errorf("CollectiveRoles: got %d policy reads, want 1", target.gets)
+	}
+}
+
+func TestListIfChanged(t *testing.T) {
+	ctx := context.Background()
+	target := newFakeTarget()
+	target.put("my-project", &Policy{Bindings: []*Binding{
+		{Role: "roles/viewer", Members: []string{"user:alice@example.com"}},
+	}})
+	m := newTestManager(t, target)
+
+	policy, changed, err := ListIfChanged(ctx, m, "my-project", "")
+	if err != nil || !changed || policy == nil {
+		t.Fatalf("ListIfChanged(no etag): got (%v, %v, %v), want the policy", policy, changed, err)
+	}
+
+	got, changed, err := ListIfChanged(ctx, m, "my-project", policy.Etag)
+	if err != nil {
+		t.Fatalf("ListIfChanged(unchanged): %v", err)
+	}
+	if changed || got != nil {
+		t.Errorf("ListIfChanged(unchanged): got (%v, %v), want (nil, false)", got, changed)
+	}
+
+	target.put("my-project", &Policy{})
+	if got, changed, err := ListIfChanged(ctx, m, "my-project", policy.Etag); err != nil || !changed || got == nil {
+		t.Errorf("ListIfChanged(changed): got (%v, %v, %v), want the new policy", got, changed, err)
+	}
+}
@@ -0,0 +1,46 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import "context"
+
+// ReconcileAdditive makes every grant in desired present on projectID, in a
+// single write, and returns the changes made. It never removes anything:
+// members and bindings on the project that desired doesn't mention are left
+// in place. Authoritative updates, such as a plan from PlanJSON or
+// PlanHierarchy, instead make the policy match desired exactly and so also
+// remove grants; use ReconcileAdditive until the desired bindings are known
+// to be complete.
+func ReconcileAdditive(ctx context.Context, svc *PolicyManager, projectID string, desired []*Binding) (ChangeSet, error) {
+	for _, b := range desired {
+		if err := ValidateRole(b.Role); err != nil {
+			return ChangeSet{}, err
+		}
+		for _, m := range b.Members {
+			if err := ValidateMember(m); err != nil {
+				return ChangeSet{}, err
+			}
+		}
+	}
+	_, cs, err := svc.modifyPolicy(ctx, projectID, func(policy *Policy) error {
+		for _, b := range desired {
+			for _, m := range b.Members {
+				addMember(policy, m, b.Role, b.Condition)
+			}
+		}
+		return nil
+	})
+	return cs, err
+}
@@ -0,0 +1,54 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestReconcileAdditive(t *testing.T) {
+	ctx := context.Background()
+	target := newFakeTarget()
+	target.put("my-project", &Policy{Bindings: []*Binding{
+		{Role: "roles/viewer", Members: []string{"user:alice@example.com", "user:legacy@example.com"}},
+		{Role: "roles/owner", Members: []string{"user:root@example.com"}},
+	}})
+	m := newTestManager(t, target)
+
+	desired := []*Binding{
+		{Role: "roles/viewer", Members: []string{"user:alice@example.com", "user:bob@example.com"}},
+		{Role: "roles/logging.viewer", Members: []string{"group:sre@example.com"}},
+	}
+	cs, err := ReconcileAdditive(ctx, m, "my-project", desired)
+	if err != nil {
+		t.Fatalf("ReconcileAdditive: %v", err)
+	}
+	want := []Change{
+		{Op: OpAdd, Role: "roles/logging.viewer", Member: "group:sre@example.com"},
+		{Op: OpAdd, Role: "roles/viewer", Member: "user:bob@example.com"},
+	}
+	if diff := cmp.Diff(want, cs.Changes); diff != "" {
+		t.Errorf("ReconcileAdditive: got diff (-want +got):\n%s", diff)
+	}
+	policy := target.policy("my-project")
+	for _, kept := range [][2]string{{"user:legacy@example.com", "roles/viewer"}, {"user:root@example.com", "roles/owner"}} {
+		if !policyHasRole(policy, kept[0], kept[1]) {
+			t.Errorf("ReconcileAdditive removed %s from %s", kept[0], kept[1])
+		}
+	}
+}
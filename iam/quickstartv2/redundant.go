@@ -0,0 +1,102 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+)
+
+// basicRoleContains maps each basic role to the basic roles whose
+// permissions it includes.
+var basicRoleContains = map[string][]string{
+	"roles/owner":  {"roles/editor", "roles/viewer"},
+	"roles/editor": {"roles/viewer"},
+}
+
+// DetectRedundantGrants returns the grants on projectID, sorted, that are
+// fully covered by a broader role the same member also holds without a
+// condition, such as roles/viewer alongside roles/owner. Basic roles are
+// compared only with a built-in containment table. If the manager has a
+// role service, other pairs of roles are compared by permissions, and a
+// role is redundant when a role with strictly more permissions includes all
+// of its own.
+func DetectRedundantGrants(ctx context.Context, svc *PolicyManager, projectID string) ([]Match, error) {
+	policy, err := svc.GetPolicy(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	held := make(map[string][]string)
+	unconditional := make(map[string][]string)
+	for _, b := range policy.Bindings {
+		for _, m := range b.Members {
+			if !containsString(held[m], b.Role) {
+				held[m] = append(held[m], b.Role)
+			}
+			if b.Condition == nil && !containsString(unconditional[m], b.Role) {
+				unconditional[m] = append(unconditional[m], b.Role)
+			}
+		}
+	}
+
+	var redundant []Match
+	for member, roles := range held {
+		for _, narrow := range roles {
+			for _, broad := range unconditional[member] {
+				if broad == narrow {
+					continue
+				}
+				covered, err := roleCovers(ctx, svc, broad, narrow)
+				if err != nil {
+					return nil, err
+				}
+				if covered {
+					redundant = append(redundant, Match{Member: member, Role: narrow})
+					break
+				}
+			}
+		}
+	}
+	sortMatches(redundant)
+	return redundant, nil
+}
+
+// roleCovers reports whether broad grants every permission of narrow and
+// more.
+func roleCovers(ctx context.Context, svc *PolicyManager, broad, narrow string) (bool, error) {
+	if containsString(basicRoleContains[broad], narrow) {
+		return true, nil
+	}
+	if svc.roles == nil || isPrimitiveRole(broad) || isPrimitiveRole(narrow) {
+		return false, nil
+	}
+	broadPerms, err := svc.RolePermissions(ctx, broad)
+	if err != nil {
+		return false, err
+	}
+	narrowPerms, err := svc.RolePermissions(ctx, narrow)
+	if err != nil {
+		return false, err
+	}
+	if len(narrowPerms) == 0 || len(narrowPerms) >= len(broadPerms) {
+		return false, nil
+	}
+	for _, p := range narrowPerms {
+		if !containsString(broadPerms, p) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,58 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/api/cloudresourcemanager/v1"
+)
+
+func TestDetectRedundantGrants(t *testing.T) {
+	ctx := context.Background()
+	target := newFakeTarget()
+	target.put("my-project", &Policy{Bindings: []*Binding{
+		{Role: "roles/owner", Members: []string{"user:alice@example.com"}},
+		{Role: "roles/viewer", Members: []string{"user:alice@example.com", "user:bob@example.com"}},
+		{Role: "roles/storage.admin", Members: []string{"user:bob@example.com"}},
+		{Role: "roles/storage.objectViewer", Members: []string{"user:bob@example.com", "user:carol@example.com"}},
+		{
+			Role:      "roles/storage.admin",
+			Members:   []string{"user:carol@example.com"},
+			Condition: &cloudresourcemanager.Expr{Title: "temporary-access", Expression: `request.time < timestamp("2020-06-01T00:00:00Z")`},
+		},
+	}})
+	roles := newFakeRoles(map[string][]string{
+		"roles/storage.admin":        {"storage.buckets.get", "storage.objects.get", "storage.objects.list"},
+		"roles/storage.objectViewer": {"storage.objects.get", "storage.objects.list"},
+	})
+	m := newTestManager(t, target, WithRoleService(roles))
+
+	got, err := DetectRedundantGrants(ctx, m, "my-project")
+	if err != nil {
+		t.Fatalf("DetectRedundantGrants: %v", err)
+	}
+	// Carol's storage.admin grant is conditional, so it doesn't cover her
+	// unconditional objectViewer grant.
+	want := []Match{
+		{Member: "user:alice@example.com", Role: "roles/viewer"},
+		{Member: "user:bob@example.com", Role: "roles/storage.objectViewer"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("DetectRedundantGrants: got diff (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,203 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// AccessReport is the IAM access of a set of projects, fetched at once.
+type AccessReport struct {
+	// Projects holds one entry per requested project, sorted by project.
+	Projects []ProjectAccess `json:"projects"`
+	// MemberProjects maps each member to the projects, sorted, where it is
+	// granted any role.
+	MemberProjects map[string][]string `json:"memberProjects"`
+	Summary        ReportSummary       `json:"summary"`
+	// RelativeExpiry, if set, makes WriteTable and WriteCSV render the
+	// condition of a temporary grant as the time left until it expires,
+	// such as "expires in 3d 4h", measured from the time it returns. Other
+	// conditions are rendered as their raw expression.
+	RelativeExpiry func() time.Time `json:"-"`
+}
+
+// ProjectAccess is the part of an AccessReport for one project.
+type ProjectAccess struct {
+	Project  string     `json:"project"`
+	Bindings []*Binding `json:"bindings,omitempty"`
+	Stats    Stats      `json:"stats"`
+	// Error is set if the project's policy could not be fetched.
+	Error string `json:"error,omitempty"`
+}
+
+// ReportSummary totals an AccessReport.
+type ReportSummary struct {
+	Projects int `json:"projects"`
+	Failed   int `json:"failed"`
+	Bindings int `json:"bindings"`
+	// Members is the number of distinct members across all projects.
+	Members int `json:"members"`
+}
+
+// BuildAccessReport fetches the policies of projectIDs, at most concurrency
+// at a time, and assembles them into a report. A project whose policy can't
+// be fetched is recorded with its error rather than failing the report. It
+// returns an error only if ctx is done before every policy is fetched.
+func BuildAccessReport(ctx context.Context, svc *PolicyManager, projectIDs []string, concurrency int) (*AccessReport, error) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	results := make([]ProjectAccess, len(projectIDs))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, projectID := range projectIDs {
+		i, projectID := i, projectID
+		select {
+		case <-ctx.Done():
+		case sem <- struct{}{}:
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i] = ProjectAccess{Project: projectID}
+			policy, err := svc.GetPolicy(ctx, projectID)
+			if err != nil {
+				results[i].Error = err.Error()
+				return
+			}
+			results[i].Bindings = policy.Bindings
+			results[i].Stats = PolicyStats(policy)
+		}()
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(results, func(i, j int) bool { return results[i].Project < results[j].Project })
+	report := &AccessReport{Projects: results, MemberProjects: make(map[string][]string)}
+	for _, pa := range results {
+		report.Summary.Projects++
+		if pa.Error != "" {
+			report.Summary.Failed++
+			continue
+		}
+		report.Summary.Bindings += pa.Stats.Bindings
+		seen := make(map[string]bool)
+		for _, b := range pa.Bindings {
+			for _, m := range b.Members {
+				if !seen[m] {
+					seen[m] = true
+					report.MemberProjects[m] = append(report.MemberProjects[m], pa.Project)
+				}
+			}
+		}
+	}
+	report.Summary.Members = len(report.MemberProjects)
+	return report, nil
+}
+
+// WriteTable writes one row per member of every binding in r to w, followed
+// by the projects that could not be fetched.
+func (r *AccessReport) WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "PROJECT\tROLE\tMEMBER\tCONDITION")
+	for _, pa := range r.Projects {
+		for _, row := range pa.rows(r.RelativeExpiry) {
+			fmt.Fprintln(tw, strings.Join(row, "\t"))
+		}
+	}
+	for _, pa := range r.Projects {
+		if pa.Error != "" {
+			fmt.Fprintf(tw, "%s\terror: %s\n", pa.Project, pa.Error)
+		}
+	}
+	return tw.Flush()
+}
+
+// WriteJSON writes r to w as indented JSON.
+func (r *AccessReport) WriteJSON(w io.Writer) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("json.MarshalIndent: %v", err)
+	}
+	if _, err := w.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("Write: %v", err)
+	}
+	return nil
+}
+
+// WriteCSV writes r to w as CSV with the columns project, role, member,
+// condition and error. Projects that could not be fetched have a single row
+// with only the project and error set.
+func (r *AccessReport) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"project", "role", "member", "condition", "error"}); err != nil {
+		return fmt.Errorf("csv.Write: %v", err)
+	}
+	for _, pa := range r.Projects {
+		rows := pa.rows(r.RelativeExpiry)
+		if pa.Error != "" {
+			rows = [][]string{{pa.Project, "", "", "", pa.Error}}
+		}
+		for _, row := range rows {
+			if len(row) < 5 {
+				row = append(row, "")
+			}
+			if err := cw.Write(row); err != nil {
+				return fmt.Errorf("csv.Write: %v", err)
+			}
+		}
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return fmt.Errorf("csv.Flush: %v", err)
+	}
+	return nil
+}
+
+// rows returns a project, role, member and condition row for each member of
+// each binding of pa. The condition is its title, or rendered relative to
+// now if now is set.
+func (pa ProjectAccess) rows(now func() time.Time) [][]string {
+	var rows [][]string
+	for _, b := range pa.Bindings {
+		cond := ""
+		switch {
+		case b.Condition == nil:
+		case now != nil:
+			cond = RelativeExpiry(b.Condition.Expression, now())
+		default:
+			cond = b.Condition.Title
+		}
+		for _, m := range b.Members {
+			rows = append(rows, []string{pa.Project, b.Role, m, cond})
+		}
+	}
+	return rows
+}
@@ -0,0 +1,190 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/api/cloudresourcemanager/v1"
+)
+
+func TestBuildAccessReport(t *testing.T) {
+	ctx := context.Background()
+	target := newFakeTarget()
+	target.put("project-a", &Policy{Bindings: []*Binding{
+		{Role: "roles/viewer", Members: []string{"user:alice@example.com", "user:bob@example.com"}},
+	}})
+	target.put("project-b", &Policy{Bindings: []*Binding{
+		{Role: "roles/editor", Members: []string{"user:alice@example.com"}},
+	}})
+	m := newTestManager(t, target)
+
+	report, err := BuildAccessReport(ctx, m, []string{"project-b", "missing", "project-a"}, 2)
+	if err != nil {
+		t.Fatalf("BuildAccessReport: %v", err)
+	}
+
+	wantIndex := map[string][]string{
+		"user:alice@example.com": {"project-a", "project-b"},
+		"user:bob@example.com":   {"project-a"},
+	}
+	if diff := cmp.Diff(wantIndex, report.MemberProjects); diff != "" {
+		t.Errorf("MemberProjects: got diff (-want +got):\n%s", diff)
+	}
+	wantSummary := ReportSummary{Projects: 3, Failed: 1, Bindings: 2, Members: 2}
+	if diff := cmp.Diff(wantSummary, report.Summary); diff != "" {
+		t.Errorf("Summary: got diff (-want +got):\n%s", diff)
+	}
+	if got := report.Projects[0]; got.Project != "missing" || got.Error == "" {
+		t.Errorf("Projects[0]: got %+v, want missing with an error", got)
+	}
+
+	var buf bytes.Buffer
+	if err := report.WriteCSV(&buf); err != nil {
+		t.Fatalf("WriteCSV: %v", err)
+	}
+	wantCSV := "project,role,member,condition,error\n" +
+		"missing,,,," + report.Projects[0].Error + "\n" +
+		"project-a,roles/viewer,user:alice@example.com,,\n" +
+		"project-a,roles/viewer,user:bob@example.com,,\n" +
+		"project-b,roles/editor,user:alice@example.com,,\n"
+	if diff := cmp.Diff(wantCSV, buf.String()); diff != "" {
+		t.Errorf("WriteCSV: got diff (-want +got):\n%s", diff)
+	}
+
+	buf.Reset()
+	if err := report.WriteTable(&buf); err != nil {
+		t.Fatalf("WriteTable: %v", err)
+	}
+	wantTable := "PROJECT    ROLE          MEMBER                  CONDITION\n" +
+		"project-a  roles/viewer  user:alice@example.com  \n" +
+		"project-a  roles/viewer  user:bob@example.com    \n" +
+		"project-b  roles/editor  user:alice@example.com  \n" +
+		"missing    error: " + report.Projects[0].Error + "\n"
+	if diff := cmp.Diff(wantTable, buf.String()); diff != "" {
+		t.Errorf("WriteTable: got diff (-want +got):\n%s", diff)
+	}
+
+	buf.Reset()
+	if err := report.WriteJSON(&buf); err != nil {
+		t.Fatalf("WriteJSON: %v", err)
+	}
+	wantJSON := `{
+		"projects": [
+			{
+				"project": "missing",
+				"stats": {"Bindings": 0, "Members": 0, "MembersPerRole": null, "ConditionalBindings": 0, "PrimitiveRoleGrants": 0},
+				"error": "` + report.Projects[0].Error + `"
+			},
+			{
+				"project": "project-a",
+				"bindings": [{"members": ["user:alice@example.com", "user:bob@example.com"], "role": "roles/viewer"}],
+				"stats": {"Bindings": 1, "Members": 2, "MembersPerRole": {"roles/viewer": 2}, "ConditionalBindings": 0, "PrimitiveRoleGrants": 2}
+			},
+			{
+				"project": "project-b",
+				"bindings": [{"members": ["user:alice@example.com"], "role": "roles/editor"}],
+				"stats": {"Bindings": 1, "Members": 1, "MembersPerRole": {"roles/editor": 1}, "ConditionalBindings": 0, "PrimitiveRoleGrants": 1}
+			}
+		],
+		"memberProjects": {
+			"user:alice@example.com": ["project-a", "project-b"],
+			"user:bob@example.com": ["project-a"]
+		},
+		"summary": {"projects": 3, "failed": 1, "bindings": 2, "members": 2}
+	}`
+	var got, want interface{}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("WriteJSON: invalid JSON: %v", err)
+	}
+	if err := json.Unmarshal([]byte(wantJSON), &want); err != nil {
+		t.Fatalf("json.Unmarshal(want): %v", err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("WriteJSON: got diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestBuildAccessReportCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	target := newFakeTarget()
+	target.put("project-a", &Policy{})
+	m := newTestManager(t, target)
+
+	if _, err := BuildAccessReport(ctx, m, []string{"project-a"}, 1); !errors.Is(err, context.Canceled) {
+		t.Errorf("BuildAccessReport: got %v, want context.Canceled", err)
+	}
+}
+
+func TestAccessReportRelativeExpiry(t *testing.T) {
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	report := &AccessReport{
+		Projects: []ProjectAccess{{
+			Project: "my-project",
+			Bindings: []*Binding{
+				{
+					Role:    "roles/viewer",
+					Members: []string{"user:alice@example.com"},
+					Condition: &cloudresourcemanager.Expr{
+						Title:      "temporary-access",
+						Expression: `request.time < timestamp("2020-06-04T16:30:00Z")`,
+					},
+				},
+				{
+					Role:    "roles/editor",
+					Members: []string{"user:bob@example.com"},
+					Condition: &cloudresourcemanager.Expr{
+						Title:      "prod-only",
+						Expression: `resource.name.startsWith("projects/_/buckets/prod")`,
+					},
+				},
+			},
+		}},
+		RelativeExpiry: func() time.Time { return now },
+	}
+
+	var buf bytes.Buffer
+	if err := report.WriteCSV(&buf); err != nil {
+		t.Fatalf("WriteCSV: %v", err)
+	}
+	want := "project,role,member,condition,error\n" +
+		"my-project,roles/viewer,user:alice@example.com,expires in 3d 4h,\n" +
+		`my-project,roles/editor,user:bob@example.com,"resource.name.startsWith(""projects/_/buckets/prod"")",` + "\n"
+	if diff := cmp.Diff(want, buf.String()); diff != "" {
+		t.Errorf("WriteCSV: got diff (-want +got):\n%s", diff)
+	}
+
+	for _, tc := range []struct {
+		expiry time.Time
+		want   string
+	}{
+		{now.Add(90 * time.Minute), "expires in 1h 30m"},
+		{now.Add(5 * time.Minute), "expires in 5m"},
+		{now.Add(-time.Hour), "expired"},
+	} {
+		expr := fmt.Sprintf("request.time < timestamp(%q)", tc.expiry.Format(time.RFC3339))
+		if got := RelativeExpiry(expr, now); got != tc.want {
+			t.Errorf("RelativeExpiry(%q): got %q, want %q", expr, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// MemberResolver translates an organization-specific identifier, such as an
+// alias or an HR system ID, into an IAM member such as
+// "user:alice@example.com".
+type MemberResolver interface {
+	Resolve(input string) (string, error)
+}
+
+// MemberResolverFunc adapts a function to a MemberResolver.
+type MemberResolverFunc func(input string) (string, error)
+
+// Resolve calls f(input).
+func (f MemberResolverFunc) Resolve(input string) (string, error) {
+	return f(input)
+}
+
+// identityResolver is the default MemberResolver, which returns its input
+// unchanged.
+type identityResolver struct{}
+
+func (identityResolver) Resolve(input string) (string, error) {
+	return input, nil
+}
+
+// commandResolver is a MemberResolver that runs an external program, such
+// as a script that looks up aliases in a directory service, with the input
+// as its only argument, and uses the first line it prints as the member.
+type commandResolver struct {
+	path string
+}
+
+func (r commandResolver) Resolve(input string) (string, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command(r.path, input)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("%s: %v: %s", r.path, err, strings.TrimSpace(stderr.String()))
+	}
+	member := strings.TrimSpace(strings.SplitN(string(out), "\n", 2)[0])
+	if member == "" {
+		return "", fmt.Errorf("%s printed no member for %q", r.path, input)
+	}
+	return member, nil
+}
+
+// WithMemberResolver resolves every member passed to the manager's grant
+// and revoke methods with r before it is canonicalized and written. By
+// default members are used as given.
+func WithMemberResolver(r MemberResolver) Option {
+	return func(m *PolicyManager) error {
+		if r == nil {
+			return fmt.Errorf("nil member resolver")
+		}
+		m.resolver = r
+		return nil
+	}
+}
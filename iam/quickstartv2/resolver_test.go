@@ -0,0 +1,61 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestWithMemberResolver(t *testing.T) {
+	ctx := context.Background()
+	target := newFakeTarget()
+	target.put("my-project", &Policy{})
+	aliases := map[string]string{"alice": "user:alice@example.com"}
+	resolver := MemberResolverFunc(func(input string) (string, error) {
+		if member, ok := aliases[input]; ok {
+			return member, nil
+		}
+		if ValidateMember(input) == nil {
+			return input, nil
+		}
+		return "", errors.New("unknown alias")
+	})
+	m := newTestManager(t, target, WithMemberResolver(resolver))
+
+	if _, err := m.AddBinding(ctx, "my-project", "alice", "roles/viewer"); err != nil {
+		t.Fatalf("AddBinding(alias): %v", err)
+	}
+	if _, err := m.AddBinding(ctx, "my-project", "user:bob@example.com", "roles/viewer"); err != nil {
+		t.Fatalf("AddBinding(member): %v", err)
+	}
+	if _, err := m.AddBinding(ctx, "my-project", "mallory", "roles/viewer"); err == nil {
+		t.Errorf("AddBinding(unknown alias): got nil error, want error")
+	}
+	want := []*Binding{{Role: "roles/viewer", Members: []string{"user:alice@example.com", "user:bob@example.com"}}}
+	if diff := cmp.Diff(want, target.policy("my-project").Bindings); diff != "" {
+		t.Errorf("AddBinding: got diff (-want +got):\n%s", diff)
+	}
+
+	if _, err := m.RemoveMember(ctx, "my-project", "alice", "roles/viewer"); err != nil {
+		t.Fatalf("RemoveMember(alias): %v", err)
+	}
+	if got := target.policy("my-project").Bindings[0].Members; len(got) != 1 {
+		t.Errorf("RemoveMember(alias): got members %v, want only bob", got)
+	}
+}
@@ -0,0 +1,169 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/iam/v1"
+)
+
+// ErrRoleNotFound is returned when a role does not exist.
+var ErrRoleNotFound = errors.New("role not found")
+
+// ErrRoleNotAllowed is returned when granting a role outside the allowlist
+// set with WithAllowedRoles.
+var ErrRoleNotAllowed = errors.New("role not allowed")
+
+// RoleService looks up role definitions.
+type RoleService interface {
+	GetRole(ctx context.Context, name string) (*iam.Role, error)
+}
+
+// iamRoleService is a RoleService backed by the IAM API.
+type iamRoleService struct {
+	svc *iam.Service
+}
+
+// NewIAMRoleService returns a RoleService that uses iamService.
+func NewIAMRoleService(iamService *iam.Service) RoleService {
+	return &iamRoleService{svc: iamService}
+}
+
+// GetRole gets a predefined or custom role by its full name.
+func (s *iamRoleService) GetRole(ctx context.Context, name string) (*iam.Role, error) {
+	var role *iam.Role
+	var err error
+	switch {
+	case strings.HasPrefix(name, "projects/"):
+		role, err = s.svc.Projects.Roles.Get(name).Context(ctx).Do()
+	case strings.HasPrefix(name, "organizations/"):
+		role, err = s.svc.Organizations.Roles.Get(name).Context(ctx).Do()
+	default:
+		role, err = s.svc.Roles.Get(name).Context(ctx).Do()
+	}
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound {
+		return nil, fmt.Errorf("%s: %w", name, ErrRoleNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Roles.Get(%s): %w", name, err)
+	}
+	return role, nil
+}
+
+// WithRoleService sets the service used to look up role definitions.
+func WithRoleService(rs RoleService) Option {
+	return func(m *PolicyManager) error {
+		m.roles = &roleCache{svc: rs}
+		return nil
+	}
+}
+
+// WithAllowedRoles restricts the roles the manager grants to roles; granting
+// any other role fails with ErrRoleNotAllowed. Revoking is not restricted.
+// Without this option every role may be granted.
+func WithAllowedRoles(roles ...string) Option {
+	return func(m *PolicyManager) error {
+		m.allowedRoles = make(map[string]bool)
+		for _, r := range roles {
+			m.allowedRoles[r] = true
+		}
+		return nil
+	}
+}
+
+// checkGrantable returns an error wrapping ErrRoleNotAllowed if cs grants a
+// role outside the allowlist set with WithAllowedRoles.
+func (m *PolicyManager) checkGrantable(cs ChangeSet) error {
+	if len(m.allowedRoles) == 0 {
+		return nil
+	}
+	for _, c := range cs.Changes {
+		if c.Op == OpAdd && !m.allowedRoles[c.Role] {
+			return fmt.Errorf("%s: %w", c.Role, ErrRoleNotAllowed)
+		}
+	}
+	return nil
+}
+
+// roleCache memoizes role lookups, including roles that don't exist.
+type roleCache struct {
+	svc RoleService
+
+	mu    sync.Mutex
+	roles map[string]*iam.Role
+}
+
+func (c *roleCache) get(ctx context.Context, name string) (*iam.Role, error) {
+	c.mu.Lock()
+	role, ok := c.roles[name]
+	c.mu.Unlock()
+	if ok {
+		if role == nil {
+			return nil, fmt.Errorf("%s: %w", name, ErrRoleNotFound)
+		}
+		return role, nil
+	}
+
+	role, err := c.svc.GetRole(ctx, name)
+	if err != nil && !errors.Is(err, ErrRoleNotFound) {
+		return nil, err
+	}
+	c.mu.Lock()
+	if c.roles == nil {
+		c.roles = make(map[string]*iam.Role)
+	}
+	c.roles[name] = role
+	c.mu.Unlock()
+	return role, err
+}
+
+// lookupRole returns the definition of the named role, using the cache.
+func (m *PolicyManager) lookupRole(ctx context.Context, name string) (*iam.Role, error) {
+	if m.roles == nil {
+		return nil, errors.New("no role service configured, see WithRoleService")
+	}
+	return m.roles.get(ctx, name)
+}
+
+// RolePermissions returns the permissions, sorted, that the named role
+// actually grants when bound in a policy. A custom role that is deleted or
+// in the DISABLED launch stage grants no permissions, even though its
+// definition still lists them.
+func (m *PolicyManager) RolePermissions(ctx context.Context, role string) ([]string, error) {
+	r, err := m.lookupRole(ctx, role)
+	if err != nil {
+		return nil, err
+	}
+	return effectivePermissions(r), nil
+}
+
+// effectivePermissions returns the distinct permissions granted by r, sorted.
+func effectivePermissions(r *iam.Role) []string {
+	if r.Deleted || r.Stage == "DISABLED" {
+		return nil
+	}
+	perms := dedupeStrings(append([]string(nil), r.IncludedPermissions...))
+	sort.Strings(perms)
+	return perms
+}
@@ -0,0 +1,107 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/api/iam/v1"
+)
+
+func TestRolePermissionsCustomRole(t *testing.T) {
+	ctx := context.Background()
+	roles := newFakeRoles(nil)
+	roles.roles["projects/my-project/roles/deployer"] = &iam.Role{
+		Name:  "projects/my-project/roles/deployer",
+		Stage: "GA",
+		IncludedPermissions: []string{
+			"run.services.update",
+			"iam.serviceAccounts.actAs",
+			"run.services.get",
+			"run.services.update",
+		},
+	}
+	roles.roles["projects/my-project/roles/retired"] = &iam.Role{
+		Name:                "projects/my-project/roles/retired",
+		Stage:               "DISABLED",
+		IncludedPermissions: []string{"storage.buckets.delete"},
+	}
+	roles.roles["projects/my-project/roles/removed"] = &iam.Role{
+		Name:                "projects/my-project/roles/removed",
+		Deleted:             true,
+		IncludedPermissions: []string{"storage.buckets.delete"},
+	}
+	m := newTestManager(t, newFakeTarget(), WithRoleService(roles))
+
+	tests := []struct {
+		role string
+		want []string
+	}{
+		{"projects/my-project/roles/deployer", []string{"iam.serviceAccounts.actAs", "run.services.get", "run.services.update"}},
+		{"projects/my-project/roles/retired", nil},
+		{"projects/my-project/roles/removed", nil},
+	}
+	for _, test := range tests {
+		got, err := m.RolePermissions(ctx, test.role)
+		if err != nil {
+			t.Errorf("RolePermissions(%s): %v", test.role, err)
+			continue
+		}
+		if diff := cmp.Diff(test.want, got); diff != "" {
+			t.Errorf("RolePermissions(%s): got diff (-want +got):\n%s", test.role, diff)
+		}
+	}
+	if got := roles.roles["projects/my-project/roles/deployer"].IncludedPermissions; len(got) != 4 {
+		t.Errorf("RolePermissions modified the cached role definition: got %v", got)
+	}
+}
+
+func TestAllowedRoles(t *testing.T) {
+	ctx := context.Background()
+	target := newFakeTarget()
+	target.put("my-project", &Policy{})
+	m := newTestManager(t, target, WithAllowedRoles("roles/viewer", "roles/logging.viewer"))
+
+	if _, err := m.AddBinding(ctx, "my-project", "user:alice@example.com", "roles/viewer"); err != nil {
+		t.Errorf("AddBinding(roles/viewer): %v", err)
+	}
+	if _, err := m.AddBinding(ctx, "my-project", "user:alice@example.com", "roles/owner"); !errors.Is(err, ErrRoleNotAllowed) {
+		t.Errorf("AddBinding(roles/owner): got %v, want ErrRoleNotAllowed", err)
+	}
+	_, err := m.AddRoles(ctx, "my-project", "user:bob@example.com", []string{"roles/logging.viewer", "roles/editor"})
+	if !errors.Is(err, ErrRoleNotAllowed) {
+		t.Errorf("AddRoles(roles/editor): got %v, want ErrRoleNotAllowed", err)
+	}
+	changes := ChangeSet{Changes: []Change{{Op: OpAdd, Role: "roles/owner", Member: "user:mallory@example.com"}}}
+	if _, err := ApplyChanges(ctx, m, "my-project", changes); !errors.Is(err, ErrRoleNotAllowed) {
+		t.Errorf("ApplyChanges(roles/owner): got %v, want ErrRoleNotAllowed", err)
+	}
+	err = m.ModifyPolicy(ctx, "my-project", func(policy *Policy) error {
+		addMember(policy, "user:mallory@example.com", "roles/owner", nil)
+		return nil
+	})
+	if !errors.Is(err, ErrRoleNotAllowed) {
+		t.Errorf("ModifyPolicy(roles/owner): got %v, want ErrRoleNotAllowed", err)
+	}
+	if _, err := m.RemoveMember(ctx, "my-project", "user:alice@example.com", "roles/viewer"); err != nil {
+		t.Errorf("RemoveMember(roles/viewer): %v", err)
+	}
+	if target.sets != 2 {
+		t.Errorf("got %d SetPolicy calls, want 2", target.sets)
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"google.golang.org/api/cloudresourcemanager/v1"
+)
+
+// GenerateMigrationScript writes to w a shell script of gcloud
+// add-iam-policy-binding and remove-iam-policy-binding commands that make
+// the changes in changes, in order, on changes.Project. Bindings without a
+// condition are written with --condition=None so that gcloud doesn't prompt
+// on policies that also have conditional bindings. Every argument is quoted
+// for a POSIX shell.
+func GenerateMigrationScript(changes ChangeSet, w io.Writer) error {
+	if changes.Project == "" {
+		return errors.New("change set has no project")
+	}
+	group, id := gcloudResource(changes.Project)
+
+	var b strings.Builder
+	b.WriteString("#!/bin/sh\n")
+	fmt.Fprintf(&b, "# IAM changes for %s.\n", changes.Project)
+	if changes.Reason != "" {
+		fmt.Fprintf(&b, "# Reason: %s\n", strings.Replace(changes.Reason, "\n", " ", -1))
+	}
+	b.WriteString("set -e\n")
+	if len(changes.Changes) == 0 {
+		b.WriteString("# No changes.\n")
+	}
+	for _, c := range changes.Changes {
+		verb := "add-iam-policy-binding"
+		if c.Op == OpRemove {
+			verb = "remove-iam-policy-binding"
+		}
+		args := append([]string{"gcloud"}, strings.Fields(group)...)
+		args = append(args, verb, id,
+			"--member="+c.Member,
+			"--role="+c.Role,
+			"--condition="+gcloudCondition(c.Condition),
+		)
+		for i, a := range args {
+			args[i] = shellQuote(a)
+		}
+		b.WriteString(strings.Join(args, " "))
+		b.WriteString("\n")
+	}
+	if _, err := io.WriteString(w, b.String()); err != nil {
+		return fmt.Errorf("Write: %v", err)
+	}
+	return nil
+}
+
+// gcloudResource returns the gcloud command group and resource ID for
+// resource, such as ("resource-manager folders", "123") for "folders/123".
+func gcloudResource(resource string) (string, string) {
+	switch {
+	case strings.HasPrefix(resource, "organizations/"):
+		return "organizations", strings.TrimPrefix(resource, "organizations/")
+	case strings.HasPrefix(resource, "folders/"):
+		return "resource-manager folders", strings.TrimPrefix(resource, "folders/")
+	}
+	return "projects", strings.TrimPrefix(resource, "projects/")
+}
+
+// gcloudCondition formats cond as the value of gcloud's --condition flag.
+// If a field contains a comma, the alternate delimiter syntax, such as
+// "^;^expression=...;title=...", is used.
+func gcloudCondition(cond *cloudresourcemanager.Expr) string {
+	if cond == nil {
+		return "None"
+	}
+	fields := []string{"expression=" + cond.Expression, "title=" + cond.Title}
+	if cond.Description != "" {
+		fields = append(fields, "description="+cond.Description)
+	}
+	all := strings.Join(fields, "")
+	if !strings.Contains(all, ",") {
+		return strings.Join(fields, ",")
+	}
+	for _, delim := range []string{";", "|", "#", "~", "@"} {
+		if !strings.Contains(all, delim) {
+			return "^" + delim + "^" + strings.Join(fields, delim)
+		}
+	}
+	return strings.Join(fields, ",")
+}
+
+// shellSafe matches words that need no quoting in a POSIX shell.
+var shellSafe = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
+// shellQuote returns s quoted for a POSIX shell, if it needs quoting.
+func shellQuote(s string) string {
+	if shellSafe.MatchString(s) {
+		return s
+	}
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
@@ -0,0 +1,68 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/api/cloudresourcemanager/v1"
+)
+
+func TestGenerateMigrationScript(t *testing.T) {
+	changes := ChangeSet{
+		Project: "my-project",
+		Reason:  "migrate to prod",
+		Changes: []Change{
+			{Op: OpAdd, Role: "roles/viewer", Member: "user:alice@example.com"},
+			{
+				Op:     OpAdd,
+				Role:   "roles/editor",
+				Member: "user:o'brien@example.com",
+				Condition: &cloudresourcemanager.Expr{
+					Title:       "temporary-access",
+					Description: "Until June, then review",
+					Expression:  `request.time < timestamp("2020-06-01T00:00:00Z")`,
+				},
+			},
+			{Op: OpRemove, Role: "roles/owner", Member: "user:legacy@example.com"},
+		},
+	}
+	var buf bytes.Buffer
+	if err := GenerateMigrationScript(changes, &buf); err != nil {
+		t.Fatalf("GenerateMigrationScript: %v", err)
+	}
+	want := "#!/bin/sh\n" +
+		"# IAM changes for my-project.\n" +
+		"# Reason: migrate to prod\n" +
+		"set -e\n" +
+		"gcloud projects add-iam-policy-binding my-project --member=user:alice@example.com --role=roles/viewer --condition=None\n" +
+		`gcloud projects add-iam-policy-binding my-project '--member=user:o'\''brien@example.com' --role=roles/editor ` +
+		`'--condition=^;^expression=request.time < timestamp("2020-06-01T00:00:00Z");title=temporary-access;description=Until June, then review'` + "\n" +
+		"gcloud projects remove-iam-policy-binding my-project --member=user:legacy@example.com --role=roles/owner --condition=None\n"
+	if diff := cmp.Diff(want, buf.String()); diff != "" {
+		t.Errorf("GenerateMigrationScript: got diff (-want +got):\n%s", diff)
+	}
+
+	buf.Reset()
+	folder := ChangeSet{Project: "folders/123", Changes: []Change{{Op: OpAdd, Role: "roles/viewer", Member: "group:sre@example.com"}}}
+	if err := GenerateMigrationScript(folder, &buf); err != nil {
+		t.Fatalf("GenerateMigrationScript(folder): %v", err)
+	}
+	if want := "gcloud resource-manager folders add-iam-policy-binding 123 --member=group:sre@example.com --role=roles/viewer --condition=None\n"; !bytes.HasSuffix(buf.Bytes(), []byte(want)) {
+		t.Errorf("GenerateMigrationScript(folder): got %q, want it to end with %q", buf.String(), want)
+	}
+}
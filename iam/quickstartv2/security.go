@@ -0,0 +1,241 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+)
+
+// Match is a member that holds a role.
+type Match struct {
+	Member string `json:"member"`
+	Role   string `json:"role"`
+}
+
+// iamAdminRoles are predefined roles that let their holder change IAM
+// policies or impersonate service accounts, and so escalate privileges.
+// They are used when no role service is configured to look up what a role
+// grants.
+var iamAdminRoles = map[string]bool{
+	"roles/owner":                           true,
+	"roles/iam.securityAdmin":               true,
+	"roles/resourcemanager.projectIamAdmin": true,
+	"roles/iam.serviceAccountAdmin":         true,
+	"roles/iam.serviceAccountKeyAdmin":      true,
+	"roles/iam.serviceAccountTokenCreator":  true,
+}
+
+// iamAdminPermissions are the permissions that let their holder change IAM
+// policies or act with a service account's credentials.
+var iamAdminPermissions = []string{
+	"resourcemanager.projects.setIamPolicy",
+	"iam.serviceAccounts.setIamPolicy",
+	"iam.serviceAccountKeys.create",
+	"iam.serviceAccounts.getAccessToken",
+	"iam.serviceAccounts.implicitDelegation",
+	"iam.serviceAccounts.signBlob",
+	"iam.serviceAccounts.signJwt",
+}
+
+// isIAMAdminRole reports whether role lets its holder manage IAM. With a
+// role service, a role qualifies if it grants any of iamAdminPermissions,
+// which also catches custom roles; roles the service doesn't know are not
+// IAM-managing. Without one, only iamAdminRoles qualify.
+func (m *PolicyManager) isIAMAdminRole(ctx context.Context, role string) (bool, error) {
+	if iamAdminRoles[role] {
+		return true, nil
+	}
+	if m.roles == nil || isPrimitiveRole(role) {
+		return false, nil
+	}
+	perms, err := m.RolePermissions(ctx, role)
+	if errors.Is(err, ErrRoleNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	for _, p := range iamAdminPermissions {
+		if containsString(perms, p) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// FindSelfGrants returns the service accounts of projectID that are granted
+// a role that can manage IAM on projectID itself, a common privilege
+// escalation vector. Each Match is one service account and one such role
+// it holds. Roles are judged as by isIAMAdminRole.
+//
+// A service account belongs to the project when its email is
+// NAME@PROJECT_ID.iam.gserviceaccount.com or
+// PROJECT_ID@appspot.gserviceaccount.com; accounts named by project number,
+// such as the Compute Engine default service account, are not recognized.
+func FindSelfGrants(ctx context.Context, svc *PolicyManager, projectID string) ([]Match, error) {
+	policy, err := svc.GetPolicy(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	var risky []Match
+	for _, b := range policy.Bindings {
+		admin, err := svc.isIAMAdminRole(ctx, b.Role)
+		if err != nil {
+			return nil, err
+		}
+		if !admin {
+			continue
+		}
+		for _, m := range b.Members {
+			if ownServiceAccount(m, projectID) {
+				risky = append(risky, Match{Member: m, Role: b.Role})
+			}
+		}
+	}
+	sortMatches(risky)
+	return risky, nil
+}
+
+// ownServiceAccount reports whether member is a service account created in
+// projectID.
+func ownServiceAccount(member, projectID string) bool {
+	email := strings.TrimPrefix(member, "serviceAccount:")
+	if email == member {
+		return false
+	}
+	return strings.HasSuffix(email, "@"+projectID+".iam.gserviceaccount.com") ||
+		email == projectID+"@appspot.gserviceaccount.com"
+}
+
+// sortMatches orders matches by member, then role.
+func sortMatches(matches []Match) {
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Member != matches[j].Member {
+			return matches[i].Member < matches[j].Member
+		}
+		return matches[i].Role < matches[j].Role
+	})
+}
+
+// setIamPolicyPermission lets its holder replace a project's IAM policy.
+const setIamPolicyPermission = "resourcemanager.projects.setIamPolicy"
+
+// ListPolicyAdmins returns the members of projectID, sorted, whose roles
+// include the permission to change the project's IAM policy. Role
+// permissions are resolved with the manager's role service.
+func ListPolicyAdmins(ctx context.Context, svc *PolicyManager, projectID string) ([]string, error) {
+	return PermissionToMembers(ctx, svc, projectID, setIamPolicyPermission)
+}
+
+// PermissionToMembers returns the members of projectID, sorted, granted a
+// role that includes permission, such as "storage.buckets.delete". Each
+// role's permissions are looked up once with the manager's role service and
+// cached for later calls.
+func PermissionToMembers(ctx context.Context, svc *PolicyManager, projectID, permission string) ([]string, error) {
+	policy, err := svc.GetPolicy(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	var members []string
+	for _, role := range policyRoles(policy) {
+		perms, err := svc.RolePermissions(ctx, role)
+		if err != nil {
+			return nil, err
+		}
+		if !containsString(perms, permission) {
+			continue
+		}
+		for _, m := range policyMembers(policy, role) {
+			if !seen[m] {
+				seen[m] = true
+				members = append(members, m)
+			}
+		}
+	}
+	sort.Strings(members)
+	return members, nil
+}
+
+// containsString reports whether list contains s.
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// ListExternalMembers returns the members of projectID, with their roles,
+// whose domain is not one of internalDomains. Domains are compared without
+// regard to case, and subdomains are not considered internal.
+//
+// user: and group: members are judged by their email domain and domain:
+// members by the domain itself. allUsers and allAuthenticatedUsers are always
+// reported, since they grant access beyond any organization. Service
+// accounts, federated principals and deleted members are never reported: a
+// service account's email domain names the project it lives in, and a
+// federated principal's identity pool, not the organization that controls
+// it.
+func ListExternalMembers(ctx context.Context, svc *PolicyManager, projectID string, internalDomains []string) ([]Match, error) {
+	policy, err := svc.GetPolicy(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	internal := make(map[string]bool)
+	for _, d := range internalDomains {
+		internal[strings.ToLower(d)] = true
+	}
+
+	seen := make(map[Match]bool)
+	var external []Match
+	for _, b := range policy.Bindings {
+		for _, m := range b.Members {
+			match := Match{Member: m, Role: b.Role}
+			if seen[match] || !isExternalMember(m, internal) {
+				continue
+			}
+			seen[match] = true
+			external = append(external, match)
+		}
+	}
+	sortMatches(external)
+	return external, nil
+}
+
+// isExternalMember reports whether member belongs outside the internal
+// domains, as described by ListExternalMembers.
+func isExternalMember(member string, internal map[string]bool) bool {
+	t, id, err := ParseMember(member)
+	if err != nil {
+		return false
+	}
+	switch t {
+	case MemberAllUsers, MemberAllAuthenticatedUsers:
+		return true
+	case MemberUser, MemberGroup:
+		return !internal[strings.ToLower(id[strings.LastIndex(id, "@")+1:])]
+	case MemberDomain:
+		return !internal[strings.ToLower(id)]
+	}
+	return false
+}
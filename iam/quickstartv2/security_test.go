@@ -0,0 +1,179 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFindSelfGrants(t *testing.T) {
+	ctx := context.Background()
+	target := newFakeTarget()
+	target.put("my-project", &Policy{Bindings: []*Binding{
+		{
+			Role: "roles/iam.securityAdmin",
+			Members: []string{
+				"serviceAccount:deployer@my-project.iam.gserviceaccount.com",
+				"serviceAccount:ci@other-project.iam.gserviceaccount.com",
+				"user:alice@example.com",
+			},
+		},
+		{
+			Role:    "roles/logging.logWriter",
+			Members: []string{"serviceAccount:app@my-project.iam.gserviceaccount.com"},
+		},
+	}})
+	m := newTestManager(t, target)
+
+	got, err := FindSelfGrants(ctx, m, "my-project")
+	if err != nil {
+		t.Fatalf("FindSelfGrants: %v", err)
+	}
+	want := []Match{
+		{Member: "serviceAccount:deployer@my-project.iam.gserviceaccount.com", Role: "roles/iam.securityAdmin"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("FindSelfGrants: got diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestListPolicyAdmins(t *testing.T) {
+	ctx := context.Background()
+	target := newFakeTarget()
+	target.put("my-project", &Policy{Bindings: []*Binding{
+		{Role: "roles/resourcemanager.projectIamAdmin", Members: []string{"user:alice@example.com"}},
+		{Role: "roles/viewer", Members: []string{"user:alice@example.com", "user:bob@example.com"}},
+	}})
+	roles := newFakeRoles(map[string][]string{
+		"roles/resourcemanager.projectIamAdmin": {"resourcemanager.projects.getIamPolicy", "resourcemanager.projects.setIamPolicy"},
+		"roles/viewer":                          {"resourcemanager.projects.get", "resourcemanager.projects.getIamPolicy"},
+	})
+	m := newTestManager(t, target, WithRoleService(roles))
+
+	got, err := ListPolicyAdmins(ctx, m, "my-project")
+	if err != nil {
+		t.Fatalf("ListPolicyAdmins: %v", err)
+	}
+	if diff := cmp.Diff([]string{"user:alice@example.com"}, got); diff != "" {
+		t.Errorf("ListPolicyAdmins: got diff (-want +got):\n%s", diff)
+	}
+
+	if _, err := ListPolicyAdmins(ctx, m, "my-project"); err != nil {
+		t.Fatalf("ListPolicyAdmins: %v", err)
+	}
+	if roles.calls != 2 {
+		t.Errorf("got %d role lookups over two calls, want 2 (cached)", roles.calls)
+	}
+}
+
+func TestListExternalMembers(t *testing.T) {
+	ctx := context.Background()
+	target := newFakeTarget()
+	target.put("my-project", &Policy{Bindings: []*Binding{
+		{
+			Role: "roles/viewer",
+			Members: []string{
+				"user:alice@example.com",
+				"user:bob@Partner.com",
+				"group:eng@example.com",
+				"domain:partner.com",
+				"serviceAccount:ci@other-project.iam.gserviceaccount.com",
+				"allUsers",
+			},
+		},
+		{Role: "roles/editor", Members: []string{"user:carol@sub.example.com", "group:ops@EXAMPLE.com"}},
+	}})
+	m := newTestManager(t, target)
+
+	got, err := ListExternalMembers(ctx, m, "my-project", []string{"Example.com"})
+	if err != nil {
+		t.Fatalf("ListExternalMembers: %v", err)
+	}
+	want := []Match{
+		{Member: "allUsers", Role: "roles/viewer"},
+		{Member: "domain:partner.com", Role: "roles/viewer"},
+		{Member: "user:bob@Partner.com", Role: "roles/viewer"},
+		{Member: "user:carol@sub.example.com", Role: "roles/editor"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("ListExternalMembers: got diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestPermissionToMembers(t *testing.T) {
+	ctx := context.Background()
+	target := newFakeTarget()
+	target.put("my-project", &Policy{Bindings: []*Binding{
+		{Role: "roles/storage.admin", Members: []string{"user:alice@example.com"}},
+		{Role: "roles/storage.objectViewer", Members: []string{"user:bob@example.com"}},
+		{Role: "projects/my-project/roles/cleaner", Members: []string{"group:ops@example.com", "user:alice@example.com"}},
+	}})
+	roles := newFakeRoles(map[string][]string{
+		"roles/storage.admin":               {"storage.buckets.create", "storage.buckets.delete"},
+		"roles/storage.objectViewer":        {"storage.objects.get", "storage.objects.list"},
+		"projects/my-project/roles/cleaner": {"storage.buckets.delete"},
+	})
+	m := newTestManager(t, target, WithRoleService(roles))
+
+	got, err := PermissionToMembers(ctx, m, "my-project", "storage.buckets.delete")
+	if err != nil {
+		t.Fatalf("PermissionToMembers: %v", err)
+	}
+	want := []string{"group:ops@example.com", "user:alice@example.com"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("PermissionToMembers: got diff (-want +got):\n%s", diff)
+	}
+
+	if _, err := PermissionToMembers(ctx, m, "my-project", "storage.objects.get"); err != nil {
+		t.Fatalf("PermissionToMembers: %v", err)
+	}
+	if roles.calls != 3 {
+		t.Errorf("got %d role lookups over two calls, want 3 (cached)", roles.calls)
+	}
+}
+
+func TestFindSelfGrantsCustomRole(t *testing.T) {
+	ctx := context.Background()
+	target := newFakeTarget()
+	target.put("my-project", &Policy{Bindings: []*Binding{
+		{
+			Role:    "projects/my-project/roles/policyEditor",
+			Members: []string{"serviceAccount:deployer@my-project.iam.gserviceaccount.com"},
+		},
+		{
+			Role:    "roles/logging.logWriter",
+			Members: []string{"serviceAccount:app@my-project.iam.gserviceaccount.com"},
+		},
+	}})
+	roles := newFakeRoles(map[string][]string{
+		"projects/my-project/roles/policyEditor": {"resourcemanager.projects.getIamPolicy", "resourcemanager.projects.setIamPolicy"},
+		"roles/logging.logWriter":                {"logging.logEntries.create"},
+	})
+	m := newTestManager(t, target, WithRoleService(roles))
+
+	got, err := FindSelfGrants(ctx, m, "my-project")
+	if err != nil {
+		t.Fatalf("FindSelfGrants: %v", err)
+	}
+	want := []Match{
+		{Member: "serviceAccount:deployer@my-project.iam.gserviceaccount.com", Role: "projects/my-project/roles/policyEditor"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("FindSelfGrants: got diff (-want +got):\n%s", diff)
+	}
+}
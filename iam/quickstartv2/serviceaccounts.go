@@ -0,0 +1,86 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"google.golang.org/api/iam/v1"
+)
+
+// ServiceAccountLister lists the service accounts of a project.
+type ServiceAccountLister interface {
+	ListServiceAccounts(ctx context.Context, projectID string) ([]*iam.ServiceAccount, error)
+}
+
+// iamServiceAccountLister is a ServiceAccountLister backed by the IAM API.
+type iamServiceAccountLister struct {
+	svc *iam.Service
+}
+
+// NewIAMServiceAccountLister returns a ServiceAccountLister that uses
+// iamService.
+func NewIAMServiceAccountLister(iamService *iam.Service) ServiceAccountLister {
+	return &iamServiceAccountLister{svc: iamService}
+}
+
+func (l *iamServiceAccountLister) ListServiceAccounts(ctx context.Context, projectID string) ([]*iam.ServiceAccount, error) {
+	var accounts []*iam.ServiceAccount
+	err := l.svc.Projects.ServiceAccounts.List("projects/"+projectID).Pages(ctx, func(resp *iam.ListServiceAccountsResponse) error {
+		accounts = append(accounts, resp.Accounts...)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("ServiceAccounts.List: %w", err)
+	}
+	return accounts, nil
+}
+
+// FindDisabledPrincipalsWithAccess returns the disabled service accounts of
+// projectID, as "serviceAccount:EMAIL" members, sorted, that are still
+// granted a role on projectID. Only the project's own service accounts, as
+// listed by iamSvc, are checked.
+func FindDisabledPrincipalsWithAccess(ctx context.Context, svc *PolicyManager, iamSvc ServiceAccountLister, projectID string) ([]string, error) {
+	projectID = resourceOrDefault(ctx, projectID)
+	policy, err := svc.GetPolicy(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	accounts, err := iamSvc.ListServiceAccounts(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	disabled := make(map[string]bool)
+	for _, a := range accounts {
+		if a.Disabled {
+			disabled["serviceAccount:"+a.Email] = true
+		}
+	}
+
+	seen := make(map[string]bool)
+	var found []string
+	for _, b := range policy.Bindings {
+		for _, m := range b.Members {
+			if disabled[m] && !seen[m] {
+				seen[m] = true
+				found = append(found, m)
+			}
+		}
+	}
+	sort.Strings(found)
+	return found, nil
+}
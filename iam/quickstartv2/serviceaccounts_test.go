@@ -0,0 +1,57 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/api/iam/v1"
+)
+
+// fakeAccounts is a ServiceAccountLister with fixed accounts per project.
+type fakeAccounts map[string][]*iam.ServiceAccount
+
+func (f fakeAccounts) ListServiceAccounts(ctx context.Context, projectID string) ([]*iam.ServiceAccount, error) {
+	return f[projectID], nil
+}
+
+func TestFindDisabledPrincipalsWithAccess(t *testing.T) {
+	ctx := context.Background()
+	target := newFakeTarget()
+	target.put("my-project", &Policy{Bindings: []*Binding{
+		{Role: "roles/storage.admin", Members: []string{
+			"serviceAccount:old@my-project.iam.gserviceaccount.com",
+			"serviceAccount:app@my-project.iam.gserviceaccount.com",
+		}},
+		{Role: "roles/viewer", Members: []string{"serviceAccount:old@my-project.iam.gserviceaccount.com"}},
+	}})
+	accounts := fakeAccounts{"my-project": {
+		{Email: "old@my-project.iam.gserviceaccount.com", Disabled: true},
+		{Email: "app@my-project.iam.gserviceaccount.com"},
+		{Email: "unused@my-project.iam.gserviceaccount.com", Disabled: true},
+	}}
+	m := newTestManager(t, target)
+
+	got, err := FindDisabledPrincipalsWithAccess(ctx, m, accounts, "my-project")
+	if err != nil {
+		t.Fatalf("FindDisabledPrincipalsWithAccess: %v", err)
+	}
+	want := []string{"serviceAccount:old@my-project.iam.gserviceaccount.com"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("FindDisabledPrincipalsWithAccess: got diff (-want +got):\n%s", diff)
+	}
+}
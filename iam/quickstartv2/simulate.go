@@ -0,0 +1,48 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import "google.golang.org/api/cloudresourcemanager/v1"
+
+// SimulateAddBinding returns a copy of policy with role granted to member,
+// and the changes that makes, without calling the API. policy is not
+// modified.
+func SimulateAddBinding(policy *Policy, member, role string) (*Policy, ChangeSet, error) {
+	return simulate(policy, member, role, addMember)
+}
+
+// SimulateRemoveMember returns a copy of policy with role revoked from
+// member, and the changes that makes, without calling the API. policy is
+// not modified.
+func SimulateRemoveMember(policy *Policy, member, role string) (*Policy, ChangeSet, error) {
+	return simulate(policy, member, role, deleteMember)
+}
+
+// simulate validates member and role and applies mutate to a copy of
+// policy.
+func simulate(policy *Policy, member, role string, mutate func(*Policy, string, string, *cloudresourcemanager.Expr) bool) (*Policy, ChangeSet, error) {
+	if err := ValidateMember(member); err != nil {
+		return nil, ChangeSet{}, err
+	}
+	if err := ValidateRole(role); err != nil {
+		return nil, ChangeSet{}, err
+	}
+	out := copyPolicy(policy)
+	if out == nil {
+		out = &Policy{}
+	}
+	mutate(out, member, role, nil)
+	return out, ChangeSet{Changes: diffPolicies(policy, out)}, nil
+}
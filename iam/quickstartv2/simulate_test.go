@@ -0,0 +1,71 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSimulateRemoveMember(t *testing.T) {
+	policy := &Policy{Etag: "etag-1", Bindings: []*Binding{
+		{Role: "roles/viewer", Members: []string{"user:alice@example.com", "user:bob@example.com"}},
+	}}
+	orig := copyPolicy(policy)
+
+	got, cs, err := SimulateRemoveMember(policy, "user:alice@example.com", "roles/viewer")
+	if err != nil {
+		t.Fatalf("SimulateRemoveMember: %v", err)
+	}
+	want := &Policy{Etag: "etag-1", Bindings: []*Binding{
+		{Role: "roles/viewer", Members: []string{"user:bob@example.com"}},
+	}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("SimulateRemoveMember: got diff (-want +got):\n%s", diff)
+	}
+	wantChanges := []Change{{Op: OpRemove, Role: "roles/viewer", Member: "user:alice@example.com"}}
+	if diff := cmp.Diff(wantChanges, cs.Changes); diff != "" {
+		t.Errorf("SimulateRemoveMember changes: got diff (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(orig, policy); diff != "" {
+		t.Errorf("SimulateRemoveMember modified its input: got diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestSimulateAddBinding(t *testing.T) {
+	policy := &Policy{Bindings: []*Binding{
+		{Role: "roles/viewer", Members: []string{"user:bob@example.com"}},
+	}}
+	orig := copyPolicy(policy)
+
+	got, cs, err := SimulateAddBinding(policy, "user:alice@example.com", "roles/viewer")
+	if err != nil {
+		t.Fatalf("SimulateAddBinding: %v", err)
+	}
+	if members := got.Bindings[0].Members; len(members) != 2 {
+		t.Errorf("SimulateAddBinding: got members %v, want two", members)
+	}
+	if len(cs.Changes) != 1 || cs.Changes[0].Op != OpAdd {
+		t.Errorf("SimulateAddBinding: got changes %+v, want one add", cs.Changes)
+	}
+	if diff := cmp.Diff(orig, policy); diff != "" {
+		t.Errorf("SimulateAddBinding modified its input: got diff (-want +got):\n%s", diff)
+	}
+
+	if _, _, err := SimulateAddBinding(policy, "alice@example.com", "roles/viewer"); err == nil {
+		t.Errorf("SimulateAddBinding(invalid member): got nil error, want error")
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"google.golang.org/api/cloudresourcemanager/v1"
+)
+
+// primitiveRoles are the basic roles that predate IAM and grant broad access
+// across every service in a project.
+var primitiveRoles = map[string]bool{
+	"roles/owner":  true,
+	"roles/editor": true,
+	"roles/viewer": true,
+}
+
+// isPrimitiveRole reports whether role is one of the basic (primitive) roles.
+func isPrimitiveRole(role string) bool {
+	return primitiveRoles[role]
+}
+
+// Stats summarizes the contents of an IAM policy.
+type Stats struct {
+	// Bindings is the total number of bindings in the policy.
+	Bindings int
+	// Members is the number of distinct members across all bindings.
+	Members int
+	// MembersPerRole maps each role to the number of members granted it.
+	// Members of several bindings for the same role (for example, with
+	// different conditions) are counted once per binding.
+	MembersPerRole map[string]int
+	// ConditionalBindings is the number of bindings that have a condition.
+	ConditionalBindings int
+	// PrimitiveRoleGrants is the number of members granted a primitive role.
+	PrimitiveRoleGrants int
+}
+
+// PolicyStats computes summary statistics for policy.
+func PolicyStats(policy *cloudresourcemanager.Policy) Stats {
+	stats := Stats{MembersPerRole: make(map[string]int)}
+	if policy == nil {
+		return stats
+	}
+
+	members := make(map[string]bool)
+	for _, b := range policy.Bindings {
+		stats.Bindings++
+		if b.Condition != nil {
+			stats.ConditionalBindings++
+		}
+		stats.MembersPerRole[b.Role] += len(b.Members)
+		if isPrimitiveRole(b.Role) {
+			stats.PrimitiveRoleGrants += len(b.Members)
+		}
+		for _, m := range b.Members {
+			members[m] = true
+		}
+	}
+	stats.Members = len(members)
+
+	return stats
+}
@@ -0,0 +1,60 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/api/cloudresourcemanager/v1"
+)
+
+func TestPolicyStats(t *testing.T) {
+	policy := &cloudresourcemanager.Policy{
+		Bindings: []*cloudresourcemanager.Binding{
+			{
+				Role:    "roles/owner",
+				Members: []string{"user:alice@example.com"},
+			},
+			{
+				Role:    "roles/logging.logWriter",
+				Members: []string{"user:alice@example.com", "serviceAccount:app@example.iam.gserviceaccount.com"},
+			},
+			{
+				Role:    "roles/logging.logWriter",
+				Members: []string{"user:bob@example.com"},
+				Condition: &cloudresourcemanager.Expr{
+					Title:      "temp-access",
+					Expression: `request.time < timestamp("2030-01-01T00:00:00Z")`,
+				},
+			},
+		},
+	}
+
+	got := PolicyStats(policy)
+	want := Stats{
+		Bindings: 3,
+		Members:  3,
+		MembersPerRole: map[string]int{
+			"roles/owner":             1,
+			"roles/logging.logWriter": 3,
+		},
+		ConditionalBindings: 1,
+		PrimitiveRoleGrants: 1,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("PolicyStats: got diff (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// UncoveredPermissionsError is returned by SuggestRoles when none of the
+// grantable roles grants some of the required permissions.
+type UncoveredPermissionsError struct {
+	Permissions []string
+}
+
+func (e *UncoveredPermissionsError) Error() string {
+	return fmt.Sprintf("no grantable role has %d permission(s): %s", len(e.Permissions), strings.Join(e.Permissions, ", "))
+}
+
+// SuggestRoles returns a small set of roles from grantableRoles whose
+// combined permissions cover requiredPermissions. Roles are chosen greedily:
+// each pick is the role granting the most still-uncovered permissions,
+// preferring the role that grants fewer permissions in total on a tie, so
+// the result is small but not necessarily minimal. Roles are returned in
+// the order they were picked. Deleted and disabled roles are never
+// suggested.
+//
+// If some permissions are not granted by any role, the roles covering the
+// rest are returned along with an *UncoveredPermissionsError listing them.
+func SuggestRoles(ctx context.Context, requiredPermissions []string, grantableRoles []Role) ([]string, error) {
+	uncovered := make(map[string]bool)
+	for _, p := range requiredPermissions {
+		uncovered[p] = true
+	}
+	candidates := make(map[string]map[string]bool)
+	for _, r := range grantableRoles {
+		if r.Deleted || r.Stage == "DISABLED" {
+			continue
+		}
+		perms := candidates[r.Name]
+		if perms == nil {
+			perms = make(map[string]bool)
+			candidates[r.Name] = perms
+		}
+		for _, p := range r.Permissions {
+			perms[p] = true
+		}
+	}
+	names := make([]string, 0, len(candidates))
+	for name := range candidates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var chosen []string
+	for len(uncovered) > 0 {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		best, bestCovers := "", 0
+		for _, name := range names {
+			covers := 0
+			for p := range uncovered {
+				if candidates[name][p] {
+					covers++
+				}
+			}
+			if covers > bestCovers || (covers == bestCovers && covers > 0 && len(candidates[name]) < len(candidates[best])) {
+				best, bestCovers = name, covers
+			}
+		}
+		if bestCovers == 0 {
+			break
+		}
+		chosen = append(chosen, best)
+		for p := range candidates[best] {
+			delete(uncovered, p)
+		}
+		delete(candidates, best)
+	}
+
+	if len(uncovered) > 0 {
+		missing := make([]string, 0, len(uncovered))
+		for p := range uncovered {
+			missing = append(missing, p)
+		}
+		sort.Strings(missing)
+		return chosen, &UncoveredPermissionsError{Permissions: missing}
+	}
+	return chosen, nil
+}
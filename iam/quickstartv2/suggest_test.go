@@ -0,0 +1,55 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSuggestRoles(t *testing.T) {
+	ctx := context.Background()
+	roles := []Role{
+		{Name: "roles/storage.admin", Permissions: []string{"storage.buckets.create", "storage.buckets.delete", "storage.objects.create", "storage.objects.get", "storage.objects.list"}},
+		{Name: "roles/storage.objectViewer", Permissions: []string{"storage.objects.get", "storage.objects.list"}},
+		{Name: "roles/storage.objectCreator", Permissions: []string{"storage.objects.create"}},
+		{Name: "roles/logging.logWriter", Permissions: []string{"logging.logEntries.create"}},
+		{Name: "roles/logging.admin", Deleted: true, Permissions: []string{"logging.logEntries.create", "logging.logs.delete"}},
+	}
+
+	got, err := SuggestRoles(ctx, []string{"storage.objects.get", "storage.objects.list", "logging.logEntries.create"}, roles)
+	if err != nil {
+		t.Fatalf("SuggestRoles: %v", err)
+	}
+	want := []string{"roles/storage.objectViewer", "roles/logging.logWriter"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("SuggestRoles: got diff (-want +got):\n%s", diff)
+	}
+
+	got, err = SuggestRoles(ctx, []string{"storage.objects.create", "logging.logs.delete", "storage.objects.get"}, roles)
+	var uncovered *UncoveredPermissionsError
+	if !errors.As(err, &uncovered) {
+		t.Fatalf("SuggestRoles(uncovered): got %v, want *UncoveredPermissionsError", err)
+	}
+	if diff := cmp.Diff([]string{"logging.logs.delete"}, uncovered.Permissions); diff != "" {
+		t.Errorf("SuggestRoles(uncovered): got permissions diff (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"roles/storage.admin"}, got); diff != "" {
+		t.Errorf("SuggestRoles(uncovered): got roles diff (-want +got):\n%s", diff)
+	}
+}
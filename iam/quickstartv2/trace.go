@@ -0,0 +1,90 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+
+	"google.golang.org/api/cloudresourcemanager/v1"
+)
+
+// WithDecisionTrace calls trace with each step the manager takes while
+// changing a policy, such as "found binding for role roles/viewer" or
+// "wrote policy (etag BwWKmjvelug=→BwWKmjw2Kxk=)", to explain no-ops and
+// retries. Every change written is traced, whichever method made it.
+// resource is the project or other resource being changed. trace may be
+// called from several goroutines at once.
+func WithDecisionTrace(trace func(resource, step string)) Option {
+	return func(m *PolicyManager) error {
+		m.trace = trace
+		return nil
+	}
+}
+
+// tracef records a decision step for resource if tracing is enabled.
+func (m *PolicyManager) tracef(resource, format string, args ...interface{}) {
+	if m.trace != nil {
+		m.trace(resource, fmt.Sprintf(format, args...))
+	}
+}
+
+// addMemberTraced is addMember, tracing why it adds nothing or a new
+// binding. The member added is traced by modifyPolicy with the other
+// changes.
+func (m *PolicyManager) addMemberTraced(resource string, policy *Policy, member, role string, condition *cloudresourcemanager.Expr) {
+	if m.trace != nil {
+		desc := bindingDescription(role, condition)
+		switch b := GetConditionalBinding(policy, role, condition); {
+		case b == nil:
+			m.tracef(resource, "no binding for %s, creating one", desc)
+		case containsString(b.Members, member):
+			m.tracef(resource, "found binding for %s", desc)
+			m.tracef(resource, "member %s already present, skipping", member)
+		default:
+			m.tracef(resource, "found binding for %s", desc)
+		}
+	}
+	addMember(policy, member, role, condition)
+}
+
+// deleteMemberTraced is deleteMember, tracing why it removes nothing or a
+// whole binding. The member removed is traced by modifyPolicy with the
+// other changes.
+func (m *PolicyManager) deleteMemberTraced(resource string, policy *Policy, member, role string, condition *cloudresourcemanager.Expr) {
+	if m.trace != nil {
+		desc := bindingDescription(role, condition)
+		switch b := GetConditionalBinding(policy, role, condition); {
+		case b == nil:
+			m.tracef(resource, "no binding for %s, skipping", desc)
+		case !containsString(b.Members, member):
+			m.tracef(resource, "found binding for %s", desc)
+			m.tracef(resource, "member %s not present, skipping", member)
+		case len(b.Members) == 1:
+			m.tracef(resource, "found binding for %s", desc)
+			m.tracef(resource, "member %s is its last member, dropping the binding", member)
+		default:
+			m.tracef(resource, "found binding for %s", desc)
+		}
+	}
+	deleteMember(policy, member, role, condition)
+}
+
+// bindingDescription names the binding of role with condition in traces.
+func bindingDescription(role string, condition *cloudresourcemanager.Expr) string {
+	if condition == nil {
+		return "role " + role
+	}
+	return fmt.Sprintf("role %s with condition %q", role, condition.Title)
+}
@@ -0,0 +1,81 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestWithDecisionTraceNoOp(t *testing.T) {
+	ctx := context.Background()
+	target := newFakeTarget()
+	target.put("my-project", &Policy{Bindings: []*Binding{
+		{Role: "roles/viewer", Members: []string{"user:alice@example.com"}},
+	}})
+	var got []string
+	trace := func(resource, step string) { got = append(got, resource+": "+step) }
+	m := newTestManager(t, target, WithDecisionTrace(trace))
+
+	if _, err := m.AddBinding(ctx, "my-project", "user:alice@example.com", "roles/viewer"); err != nil {
+		t.Fatalf("AddBinding: %v", err)
+	}
+	want := []string{
+		"my-project: read policy (etag etag-1)",
+		"my-project: found binding for role roles/viewer",
+		"my-project: member user:alice@example.com already present, skipping",
+		"my-project: no changes, policy not written",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("AddBinding: got trace diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestWithDecisionTraceChanges(t *testing.T) {
+	ctx := WithResource(context.Background(), "my-project")
+	target := newFakeTarget()
+	target.put("my-project", &Policy{Bindings: []*Binding{
+		{Role: "roles/viewer", Members: []string{"user:alice@example.com"}},
+	}})
+	var got []string
+	trace := func(resource, step string) { got = append(got, resource+": "+step) }
+	m := newTestManager(t, target, WithDecisionTrace(trace))
+
+	if _, err := m.RemoveMember(ctx, "", "user:alice@example.com", "roles/viewer"); err != nil {
+		t.Fatalf("RemoveMember: %v", err)
+	}
+	err := m.ModifyPolicy(ctx, "", func(policy *Policy) error {
+		policy.Bindings = append(policy.Bindings, &Binding{Role: "roles/editor", Members: []string{"user:bob@example.com"}})
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ModifyPolicy: %v", err)
+	}
+	want := []string{
+		"my-project: read policy (etag etag-1)",
+		"my-project: found binding for role roles/viewer",
+		"my-project: member user:alice@example.com is its last member, dropping the binding",
+		"my-project: removed member user:alice@example.com from role roles/viewer",
+		"my-project: wrote policy (etag etag-1→etag-2)",
+		"my-project: read policy (etag etag-2)",
+		"my-project: added member user:bob@example.com to role roles/editor",
+		"my-project: wrote policy (etag etag-2→etag-3)",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("trace: got diff (-want +got):\n%s", diff)
+	}
+}
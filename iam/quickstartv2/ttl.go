@@ -0,0 +1,163 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// TTLGrant is a temporary grant tracked in a local registry file.
+type TTLGrant struct {
+	Resource string    `json:"resource"`
+	Role     string    `json:"role"`
+	Member   string    `json:"member"`
+	Expiry   time.Time `json:"expiry"`
+}
+
+// ttlRegistry is the on-disk format of the local TTL registry.
+type ttlRegistry struct {
+	Grants []TTLGrant `json:"grants"`
+}
+
+// GrantWithLocalTTL grants role to member on resource and records the grant
+// in the registry file at registryPath, so that SweepExpiredLocal can revoke
+// it once ttl has elapsed. Unlike a conditional grant, the expiry is only
+// enforced when the sweep runs. If member already holds role, nothing is
+// recorded, so that the sweep never revokes a standing grant.
+func GrantWithLocalTTL(ctx context.Context, svc *PolicyManager, registryPath, resource, member, role string, ttl time.Duration) error {
+	if ttl <= 0 {
+		return fmt.Errorf("ttl must be positive, got %v", ttl)
+	}
+	reg, err := readTTLRegistry(registryPath)
+	if err != nil {
+		return err
+	}
+	cs, err := svc.AddBinding(ctx, resource, member, role)
+	if err != nil {
+		return err
+	}
+	if cs.Empty() {
+		return nil
+	}
+	reg.Grants = append(reg.Grants, TTLGrant{
+		Resource: resource,
+		Role:     role,
+		Member:   member,
+		Expiry:   svc.now().Add(ttl),
+	})
+	return writeTTLRegistry(registryPath, reg)
+}
+
+// SweepExpiredLocal revokes every grant in the registry at registryPath whose
+// expiry is not after now, and drops it from the registry. Grants that fail
+// to be revoked stay in the registry for the next sweep. It returns the
+// grants that were revoked.
+func SweepExpiredLocal(ctx context.Context, svc *PolicyManager, registryPath string, now time.Time) ([]TTLGrant, error) {
+	reg, err := readTTLRegistry(registryPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var swept, kept []TTLGrant
+	var sweepErr error
+	for _, g := range reg.Grants {
+		if g.Expiry.After(now) {
+			kept = append(kept, g)
+			continue
+		}
+		if _, err := svc.RemoveMember(ctx, g.Resource, g.Member, g.Role); err != nil {
+			if sweepErr == nil {
+				sweepErr = fmt.Errorf("revoking %s from %s on %s: %v", g.Role, g.Member, g.Resource, err)
+			}
+			kept = append(kept, g)
+			continue
+		}
+		swept = append(swept, g)
+	}
+
+	reg.Grants = kept
+	if err := writeTTLRegistry(registryPath, reg); err != nil {
+		return swept, err
+	}
+	return swept, sweepErr
+}
+
+// readTTLRegistry reads the registry at path. A missing file is an empty
+// registry.
+func readTTLRegistry(path string) (*ttlRegistry, error) {
+	reg := &ttlRegistry{}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return reg, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ioutil.ReadFile: %v", err)
+	}
+	if err := json.Unmarshal(data, reg); err != nil {
+		return nil, fmt.Errorf("invalid TTL registry %s: %v", path, err)
+	}
+	return reg, nil
+}
+
+// writeTTLRegistry replaces the registry at path.
+func writeTTLRegistry(path string, reg *ttlRegistry) error {
+	data, err := json.MarshalIndent(reg, "", "  ")
+	if err != nil {
+		return fmt.Errorf("json.MarshalIndent: %v", err)
+	}
+	return writeFileAtomic(path, data)
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames
+// it into place, so readers never see a partially written file. The data is
+// synced before the rename so a crash can't leave an empty file at path,
+// and the file gets mode 0644 like ioutil.WriteFile rather than the 0600 of
+// ioutil.TempFile.
+func writeFileAtomic(path string, data []byte) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return fmt.Errorf("ioutil.TempFile: %v", err)
+	}
+	if err := f.Chmod(0644); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return fmt.Errorf("Chmod: %v", err)
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return fmt.Errorf("Write: %v", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return fmt.Errorf("Sync: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return fmt.Errorf("Close: %v", err)
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		os.Remove(f.Name())
+		return fmt.Errorf("os.Rename: %v", err)
+	}
+	return nil
+}
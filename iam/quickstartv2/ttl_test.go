@@ -0,0 +1,95 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSweepExpiredLocal(t *testing.T) {
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "ttl")
+	if err != nil {
+		t.Fatalf("ioutil.TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	registry := filepath.Join(dir, "registry.json")
+
+	target := newFakeTarget()
+	target.put("my-project", &Policy{})
+	m := newTestManager(t, target)
+	start := m.now()
+
+	if err := GrantWithLocalTTL(ctx, m, registry, "my-project", "user:alice@example.com", "roles/viewer", time.Hour); err != nil {
+		t.Fatalf("GrantWithLocalTTL: %v", err)
+	}
+	if err := GrantWithLocalTTL(ctx, m, registry, "my-project", "user:bob@example.com", "roles/viewer", 3*time.Hour); err != nil {
+		t.Fatalf("GrantWithLocalTTL: %v", err)
+	}
+
+	swept, err := SweepExpiredLocal(ctx, m, registry, start.Add(2*time.Hour))
+	if err != nil {
+		t.Fatalf("SweepExpiredLocal: %v", err)
+	}
+	if len(swept) != 1 || swept[0].Member != "user:alice@example.com" {
+		t.Errorf("SweepExpiredLocal: got swept %+v, want only alice", swept)
+	}
+
+	b := GetBinding(target.policy("my-project"), "roles/viewer")
+	if b == nil || len(b.Members) != 1 || b.Members[0] != "user:bob@example.com" {
+		t.Errorf("after sweep: got binding %+v, want only bob", b)
+	}
+
+	reg, err := readTTLRegistry(registry)
+	if err != nil {
+		t.Fatalf("readTTLRegistry: %v", err)
+	}
+	if len(reg.Grants) != 1 || reg.Grants[0].Member != "user:bob@example.com" {
+		t.Errorf("after sweep: got registry %+v, want only bob", reg.Grants)
+	}
+}
+
+func TestGrantWithLocalTTLExistingGrant(t *testing.T) {
+	ctx := context.Background()
+	registry := filepath.Join(t.TempDir(), "registry.json")
+	target := newFakeTarget()
+	target.put("my-project", &Policy{Bindings: []*Binding{
+		{Role: "roles/viewer", Members: []string{"user:alice@example.com"}},
+	}})
+	m := newTestManager(t, target)
+
+	if err := GrantWithLocalTTL(ctx, m, registry, "my-project", "user:alice@example.com", "roles/viewer", time.Hour); err != nil {
+		t.Fatalf("GrantWithLocalTTL: %v", err)
+	}
+	reg, err := readTTLRegistry(registry)
+	if err != nil {
+		t.Fatalf("readTTLRegistry: %v", err)
+	}
+	if len(reg.Grants) != 0 {
+		t.Errorf("GrantWithLocalTTL(existing grant): got registry %+v, want empty", reg.Grants)
+	}
+
+	if _, err := SweepExpiredLocal(ctx, m, registry, m.now().Add(2*time.Hour)); err != nil {
+		t.Fatalf("SweepExpiredLocal: %v", err)
+	}
+	if !policyHasRole(target.policy("my-project"), "user:alice@example.com", "roles/viewer") {
+		t.Errorf("SweepExpiredLocal revoked a standing grant")
+	}
+}
@@ -0,0 +1,184 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// udiffContext is the number of unchanged lines shown around each change in
+// a unified diff.
+const udiffContext = 3
+
+// UnifiedDiff renders cs as a unified diff between the canonical JSON of
+// before and of before with cs applied. Policies are canonicalized as by
+// ExportPolicy with Clean set, and etags are left out. It returns an empty
+// string if cs changes nothing.
+func (cs ChangeSet) UnifiedDiff(before *Policy) (string, error) {
+	after := copyPolicy(before)
+	if after == nil {
+		after = &Policy{}
+	}
+	for _, c := range cs.Changes {
+		applyChange(after, c)
+	}
+	a, err := canonicalLines(before)
+	if err != nil {
+		return "", err
+	}
+	b, err := canonicalLines(after)
+	if err != nil {
+		return "", err
+	}
+	name := cs.Project
+	if name == "" {
+		name = "policy"
+	}
+	return unifiedDiff(name+" (before)", name+" (after)", a, b), nil
+}
+
+// canonicalLines returns the lines of the canonical JSON of policy.
+func canonicalLines(policy *Policy) ([]string, error) {
+	p := canonicalPolicy(policy)
+	if p == nil {
+		p = &Policy{}
+	}
+	p.Etag = ""
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("json.MarshalIndent: %v", err)
+	}
+	return strings.Split(string(data), "\n"), nil
+}
+
+// diffLine is one line of an edit script: ' ' kept, '-' removed or '+'
+// added.
+type diffLine struct {
+	op   byte
+	text string
+}
+
+// diffLines returns an edit script turning a into b, computed from their
+// longest common subsequence.
+func diffLines(a, b []string) []diffLine {
+	// lcs[i][j] is the length of the LCS of a[i:] and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			switch {
+			case a[i] == b[j]:
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var script []diffLine
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			script = append(script, diffLine{' ', a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			script = append(script, diffLine{'-', a[i]})
+			i++
+		default:
+			script = append(script, diffLine{'+', b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		script = append(script, diffLine{'-', a[i]})
+	}
+	for ; j < len(b); j++ {
+		script = append(script, diffLine{'+', b[j]})
+	}
+	return script
+}
+
+// unifiedDiff formats the differences between a and b as a unified diff
+// with headers naming them from and to, or returns "" if they are equal.
+func unifiedDiff(from, to string, a, b []string) string {
+	script := diffLines(a, b)
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", from, to)
+	changed := false
+	for start := 0; start < len(script); {
+		// Find the next change.
+		for start < len(script) && script[start].op == ' ' {
+			start++
+		}
+		if start == len(script) {
+			break
+		}
+		changed = true
+		// Extend the hunk while changes are within 2*udiffContext lines of
+		// each other.
+		end := start
+		for k := start; k < len(script); k++ {
+			if script[k].op != ' ' {
+				end = k + 1
+			} else if k-end >= 2*udiffContext {
+				break
+			}
+		}
+		lo, hi := start-udiffContext, end+udiffContext
+		if lo < 0 {
+			lo = 0
+		}
+		if hi > len(script) {
+			hi = len(script)
+		}
+
+		// Line numbers are 1-based positions of the hunk in a and b.
+		aStart, bStart := 1, 1
+		for _, l := range script[:lo] {
+			if l.op != '+' {
+				aStart++
+			}
+			if l.op != '-' {
+				bStart++
+			}
+		}
+		var aLen, bLen int
+		for _, l := range script[lo:hi] {
+			if l.op != '+' {
+				aLen++
+			}
+			if l.op != '-' {
+				bLen++
+			}
+		}
+		fmt.Fprintf(&out, "@@ -%d,%d +%d,%d @@\n", aStart, aLen, bStart, bLen)
+		for _, l := range script[lo:hi] {
+			fmt.Fprintf(&out, "%c%s\n", l.op, l.text)
+		}
+		start = hi
+	}
+	if !changed {
+		return ""
+	}
+	return out.String()
+}
@@ -0,0 +1,56 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestChangeSetUnifiedDiff(t *testing.T) {
+	before := &Policy{Etag: "etag-1", Version: 1, Bindings: []*Binding{
+		{Role: "roles/viewer", Members: []string{"user:bob@example.com"}},
+	}}
+	cs := ChangeSet{Project: "my-project", Changes: []Change{
+		{Op: OpAdd, Role: "roles/viewer", Member: "user:alice@example.com"},
+	}}
+
+	got, err := cs.UnifiedDiff(before)
+	if err != nil {
+		t.Fatalf("UnifiedDiff: %v", err)
+	}
+	want := strings.Join([]string{
+		"--- my-project (before)",
+		"+++ my-project (after)",
+		"@@ -2,6 +2,7 @@",
+		`   "bindings": [`,
+		"     {",
+		`       "members": [`,
+		`+        "user:alice@example.com",`,
+		`         "user:bob@example.com"`,
+		"       ],",
+		`       "role": "roles/viewer"`,
+		"",
+	}, "\n")
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("UnifiedDiff: got diff (-want +got):\n%s", diff)
+	}
+
+	if got, err := (ChangeSet{}).UnifiedDiff(before); err != nil || got != "" {
+		t.Errorf("UnifiedDiff(no changes): got (%q, %v), want empty", got, err)
+	}
+}
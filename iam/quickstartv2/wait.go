@@ -0,0 +1,50 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// maxPollInterval caps the wait between polls in WaitForRole.
+const maxPollInterval = 10 * time.Second
+
+// WaitForRole polls projectID until member's possession of role matches
+// want, to ride out IAM propagation delays after a change. Polls back off
+// exponentially and always bypass the manager's cache. It returns
+// context.DeadlineExceeded if the role doesn't match within timeout.
+func WaitForRole(ctx context.Context, svc *PolicyManager, projectID, member, role string, want bool, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	for attempt := 0; ; attempt++ {
+		policy, err := svc.readPolicy(ctx, resourceOrDefault(ctx, projectID))
+		if err != nil && ctx.Err() == nil {
+			return err
+		}
+		if err == nil && policyHasRole(policy, member, role) == want {
+			return nil
+		}
+		wait := svc.backoff(attempt)
+		if wait > maxPollInterval {
+			wait = maxPollInterval
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
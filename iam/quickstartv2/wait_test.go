@@ -0,0 +1,55 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestWaitForRole(t *testing.T) {
+	ctx := context.Background()
+	target := newFakeTarget()
+	target.put("my-project", &Policy{})
+	// The grant becomes visible on the third read.
+	target.onGet = func(resource string, gets int) {
+		if gets == 3 {
+			p := target.policies[resource]
+			p.Bindings = append(p.Bindings, &Binding{Role: "roles/viewer", Members: []string{"user:alice@example.com"}})
+		}
+	}
+	m := newTestManager(t, target)
+
+	if err := WaitForRole(ctx, m, "my-project", "user:alice@example.com", "roles/viewer", true, time.Minute); err != nil {
+		t.Fatalf("WaitForRole: %v", err)
+	}
+	if target.gets != 3 {
+		t.Errorf("WaitForRole: got %d reads, want 3", target.gets)
+	}
+}
+
+func TestWaitForRoleTimeout(t *testing.T) {
+	ctx := context.Background()
+	target := newFakeTarget()
+	target.put("my-project", &Policy{})
+	m := newTestManager(t, target)
+	m.backoff = func(int) time.Duration { return time.Millisecond }
+
+	err := WaitForRole(ctx, m, "my-project", "user:alice@example.com", "roles/viewer", true, 20*time.Millisecond)
+	if err != context.DeadlineExceeded {
+		t.Errorf("WaitForRole: got %v, want context.DeadlineExceeded", err)
+	}
+}
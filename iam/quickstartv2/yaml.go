@@ -0,0 +1,139 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+
+	"google.golang.org/api/cloudresourcemanager/v1"
+	"gopkg.in/yaml.v2"
+)
+
+// This file reads and writes policies in the YAML shape printed by
+// "gcloud projects get-iam-policy". The yaml* types mirror the API types
+// with the API's own field names; their fields are in alphabetical order
+// because gcloud sorts keys and yaml.v2 writes struct fields in order.
+
+type yamlPolicy struct {
+	AuditConfigs []*yamlAuditConfig `yaml:"auditConfigs,omitempty"`
+	Bindings     []*yamlBinding     `yaml:"bindings,omitempty"`
+	Etag         string             `yaml:"etag,omitempty"`
+	Version      int64              `yaml:"version,omitempty"`
+}
+
+type yamlAuditConfig struct {
+	AuditLogConfigs []*yamlAuditLogConfig `yaml:"auditLogConfigs,omitempty"`
+	Service         string                `yaml:"service,omitempty"`
+}
+
+type yamlAuditLogConfig struct {
+	ExemptedMembers []string `yaml:"exemptedMembers,omitempty"`
+	LogType         string   `yaml:"logType,omitempty"`
+}
+
+type yamlBinding struct {
+	Condition *yamlExpr `yaml:"condition,omitempty"`
+	Members   []string  `yaml:"members,omitempty"`
+	Role      string    `yaml:"role,omitempty"`
+}
+
+type yamlExpr struct {
+	Description string `yaml:"description,omitempty"`
+	Expression  string `yaml:"expression,omitempty"`
+	Location    string `yaml:"location,omitempty"`
+	Title       string `yaml:"title,omitempty"`
+}
+
+// MarshalPolicyYAML returns policy in the gcloud YAML shape.
+func MarshalPolicyYAML(policy *Policy) ([]byte, error) {
+	y := &yamlPolicy{Etag: policy.Etag, Version: policy.Version}
+	for _, ac := range policy.AuditConfigs {
+		yac := &yamlAuditConfig{Service: ac.Service}
+		for _, lc := range ac.AuditLogConfigs {
+			yac.AuditLogConfigs = append(yac.AuditLogConfigs, &yamlAuditLogConfig{
+				ExemptedMembers: lc.ExemptedMembers,
+				LogType:         lc.LogType,
+			})
+		}
+		y.AuditConfigs = append(y.AuditConfigs, yac)
+	}
+	for _, b := range policy.Bindings {
+		yb := &yamlBinding{Members: b.Members, Role: b.Role}
+		if c := b.Condition; c != nil {
+			yb.Condition = &yamlExpr{
+				Description: c.Description,
+				Expression:  c.Expression,
+				Location:    c.Location,
+				Title:       c.Title,
+			}
+		}
+		y.Bindings = append(y.Bindings, yb)
+	}
+	data, err := yaml.Marshal(y)
+	if err != nil {
+		return nil, fmt.Errorf("yaml.Marshal: %v", err)
+	}
+	return data, nil
+}
+
+// UnmarshalPolicyYAML parses a policy in the gcloud YAML shape.
+func UnmarshalPolicyYAML(data []byte) (*Policy, error) {
+	var y yamlPolicy
+	if err := yaml.Unmarshal(data, &y); err != nil {
+		return nil, fmt.Errorf("yaml.Unmarshal: %v", err)
+	}
+	policy := &Policy{Etag: y.Etag, Version: y.Version}
+	if y.AuditConfigs != nil {
+		policy.AuditConfigs = make([]*cloudresourcemanager.AuditConfig, len(y.AuditConfigs))
+	}
+	for i, yac := range y.AuditConfigs {
+		if yac == nil {
+			return nil, fmt.Errorf("yaml: auditConfigs[%d] is empty", i)
+		}
+		ac := &cloudresourcemanager.AuditConfig{Service: yac.Service}
+		if yac.AuditLogConfigs != nil {
+			ac.AuditLogConfigs = make([]*cloudresourcemanager.AuditLogConfig, len(yac.AuditLogConfigs))
+		}
+		for j, ylc := range yac.AuditLogConfigs {
+			if ylc == nil {
+				return nil, fmt.Errorf("yaml: auditConfigs[%d].auditLogConfigs[%d] is empty", i, j)
+			}
+			ac.AuditLogConfigs[j] = &cloudresourcemanager.AuditLogConfig{
+				ExemptedMembers: ylc.ExemptedMembers,
+				LogType:         ylc.LogType,
+			}
+		}
+		policy.AuditConfigs[i] = ac
+	}
+	if y.Bindings != nil {
+		policy.Bindings = make([]*Binding, len(y.Bindings))
+	}
+	for i, yb := range y.Bindings {
+		if yb == nil {
+			return nil, fmt.Errorf("yaml: bindings[%d] is empty", i)
+		}
+		b := &Binding{Members: yb.Members, Role: yb.Role}
+		if c := yb.Condition; c != nil {
+			b.Condition = &cloudresourcemanager.Expr{
+				Description: c.Description,
+				Expression:  c.Expression,
+				Location:    c.Location,
+				Title:       c.Title,
+			}
+		}
+		policy.Bindings[i] = b
+	}
+	return policy, nil
+}
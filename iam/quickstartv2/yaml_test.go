@@ -0,0 +1,145 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/api/cloudresourcemanager/v1"
+)
+
+// The fixture is written in the layout of "gcloud projects get-iam-policy"
+// output, with example identities in place of a real project's.
+func TestGcloudYAMLCompatibility(t *testing.T) {
+	const path = "testdata/gcloud_policy.yaml"
+	policy, err := LoadPolicyFile(path)
+	if err != nil {
+		t.Fatalf("LoadPolicyFile: %v", err)
+	}
+	if policy.Etag != "BwWKmjvelug=" || policy.Version != 3 {
+		t.Errorf("LoadPolicyFile: got etag %q and version %d, want BwWKmjvelug= and 3", policy.Etag, policy.Version)
+	}
+	wantBinding := &Binding{
+		Role:    "roles/editor",
+		Members: []string{"user:bob@example.com"},
+		Condition: &cloudresourcemanager.Expr{
+			Title:       "temporary-access",
+			Description: "Temporary access for user:bob@example.com",
+			Expression:  `request.time < timestamp("2020-06-01T00:00:00Z")`,
+		},
+	}
+	if len(policy.Bindings) != 3 {
+		t.Fatalf("LoadPolicyFile: got %d bindings, want 3", len(policy.Bindings))
+	}
+	if diff := cmp.Diff(wantBinding, policy.Bindings[0]); diff != "" {
+		t.Errorf("LoadPolicyFile: got bindings[0] diff (-want +got):\n%s", diff)
+	}
+	if got := policy.AuditConfigs[0].AuditLogConfigs[1].ExemptedMembers; len(got) != 1 {
+		t.Errorf("LoadPolicyFile: got exempted members %v, want one", got)
+	}
+
+	want, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ioutil.ReadFile: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := ExportPolicy(&buf, policy, ExportOptions{YAML: true}); err != nil {
+		t.Fatalf("ExportPolicy: %v", err)
+	}
+	if diff := cmp.Diff(string(want), buf.String()); diff != "" {
+		t.Errorf("ExportPolicy: got diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestPolicyYAMLRoundTrip(t *testing.T) {
+	policy := &Policy{
+		Etag:    "12345",
+		Version: 1,
+		Bindings: []*Binding{{
+			Role:    "roles/viewer",
+			Members: []string{"user:alice@example.com"},
+			Condition: &cloudresourcemanager.Expr{
+				Title:       "true",
+				Description: "it's: tricky #1\nsecond line",
+				Expression:  `- resource.name == "x"`,
+			},
+		}},
+	}
+	data, err := MarshalPolicyYAML(policy)
+	if err != nil {
+		t.Fatalf("MarshalPolicyYAML: %v", err)
+	}
+	got, err := UnmarshalPolicyYAML(data)
+	if err != nil {
+		t.Fatalf("UnmarshalPolicyYAML(%s): %v", data, err)
+	}
+	if diff := cmp.Diff(policy, got); diff != "" {
+		t.Errorf("UnmarshalPolicyYAML: got diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestUnmarshalPolicyYAML(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		in   string
+		want *Policy
+	}{
+		{
+			name: "block scalar and comments",
+			in: "---\n# exported by hand\nbindings:\n  - members:\n      - user:alice@example.com\n    role: roles/viewer\n" +
+				"    condition:\n      title: office-hours\n      expression: >-\n        request.time.getHours(\"Europe/Berlin\") >= 9 &&\n" +
+				"        request.time.getHours(\"Europe/Berlin\") < 17\n",
+			want: &Policy{Bindings: []*Binding{{
+				Role:    "roles/viewer",
+				Members: []string{"user:alice@example.com"},
+				Condition: &cloudresourcemanager.Expr{
+					Title:      "office-hours",
+					Expression: `request.time.getHours("Europe/Berlin") >= 9 && request.time.getHours("Europe/Berlin") < 17`,
+				},
+			}}},
+		},
+		{
+			name: "flow style",
+			in:   "bindings: [{role: roles/viewer, members: [user:alice@example.com]}]\n",
+			want: &Policy{Bindings: []*Binding{{
+				Role:    "roles/viewer",
+				Members: []string{"user:alice@example.com"},
+			}}},
+		},
+		{
+			name: "empty",
+			in:   "bindings: []\netag: ACAB\n",
+			want: &Policy{Bindings: []*Binding{}, Etag: "ACAB"},
+		},
+	} {
+		got, err := UnmarshalPolicyYAML([]byte(tc.in))
+		if err != nil {
+			t.Errorf("UnmarshalPolicyYAML(%s): %v", tc.name, err)
+			continue
+		}
+		if diff := cmp.Diff(tc.want, got); diff != "" {
+			t.Errorf("UnmarshalPolicyYAML(%s): got diff (-want +got):\n%s", tc.name, diff)
+		}
+	}
+
+	for _, in := range []string{"bindings: roles/viewer\n", "bindings:\n- ~\n", "- not a mapping\n", "etag: 'open\n"} {
+		if _, err := UnmarshalPolicyYAML([]byte(in)); err == nil {
+			t.Errorf("UnmarshalPolicyYAML(%q): got nil error, want error", in)
+		}
+	}
+}